@@ -1,11 +1,139 @@
 package main
 
 import (
+	"errors"
+	"flag"
+	"log"
+	"log/slog"
+	"os"
+
 	"github.com/d1mk9/tgChanPost/configs"
+	"github.com/d1mk9/tgChanPost/internal/api"
+	"github.com/d1mk9/tgChanPost/internal/authors"
 	"github.com/d1mk9/tgChanPost/internal/bot"
+	"github.com/d1mk9/tgChanPost/internal/logging"
+	"github.com/d1mk9/tgChanPost/internal/quotes"
+	"github.com/d1mk9/tgChanPost/internal/storage"
+	"github.com/d1mk9/tgChanPost/internal/telegram"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
 )
 
 func main() {
-	configs.LoadConfig()
-	bot.StartBot()
+	cfg, err := configs.Load(os.Args[1:])
+	if errors.Is(err, flag.ErrHelp) {
+		return
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if err := logging.Setup(os.Stderr, cfg.LogLevel, cfg.LogFormat, cfg.BotToken, cfg.YandexAPIKey, cfg.ImageAPIKey, cfg.IAMToken); err != nil {
+		log.Fatal(err)
+	}
+
+	// Ошибки библиотеки содержат URL с токеном, поэтому пишем их через slog с редактированием
+	if err := tgbotapi.SetLogger(logging.StdLogger(slog.LevelWarn)); err != nil {
+		fatal("Ошибка настройки логгера Telegram", err)
+	}
+
+	tg, err := telegram.New(cfg.BotToken)
+	if err != nil {
+		fatal("Ошибка авторизации в Telegram", err)
+	}
+	slog.Info("Аккаунт авторизован", "username", tg.Self.UserName)
+
+	drafts, err := storage.NewDraftStore("drafts.json")
+	if err != nil {
+		fatal("Ошибка загрузки черновиков", err)
+	}
+
+	arts, err := storage.NewArtStore("art")
+	if err != nil {
+		fatal("Ошибка инициализации хранилища изображений", err)
+	}
+
+	usage, err := storage.NewUsageStore("usage.json")
+	if err != nil {
+		fatal("Ошибка загрузки расхода", err)
+	}
+
+	queue, err := storage.NewQueueStore("queue.json")
+	if err != nil {
+		fatal("Ошибка загрузки очереди публикации", err)
+	}
+
+	posts, err := storage.NewPostStore("posts.json")
+	if err != nil {
+		fatal("Ошибка загрузки реестра постов", err)
+	}
+
+	registry, err := authors.LoadRegistry(cfg.AuthorsFile)
+	if err != nil {
+		fatal("Ошибка загрузки реестра авторов", err)
+	}
+
+	corpus, err := quotes.LoadCorpus(cfg.QuotesFile, registry)
+	if err != nil {
+		fatal("Ошибка загрузки корпуса цитат", err)
+	}
+	slog.Info("Загружен корпус цитат", "quotes", corpus.Len())
+
+	auth, artAuth, err := yandexAuth(cfg)
+	if err != nil {
+		fatal("Ошибка настройки аутентификации Yandex Cloud", err)
+	}
+	slog.Info("Аутентификация Yandex Cloud", "mode", cfg.YandexAuth)
+
+	yandex := api.NewClient(api.ClientOptions{
+		Auth:      auth,
+		ArtAuth:   artAuth,
+		CatalogID: cfg.CatalogID,
+		BaseURL:   cfg.YandexURL,
+	})
+
+	b, err := bot.New(cfg, bot.Deps{
+		Telegram: tg,
+		Text:     yandex,
+		Images:   yandex,
+		Drafts:   drafts,
+		Arts:     arts,
+		Usage:    usage,
+		Queue:    queue,
+		Posts:    posts,
+		Authors:  registry,
+		Corpus:   corpus,
+	})
+	if err != nil {
+		fatal("Ошибка создания бота", err)
+	}
+
+	b.Run()
+}
+
+// yandexAuth создает аутентификацию для YandexGPT и YandexART по yandex_auth.
+// IAM-токен и сервисный аккаунт общие для обеих моделей.
+func yandexAuth(cfg configs.Config) (api.Authenticator, api.Authenticator, error) {
+	switch cfg.YandexAuth {
+	case configs.AuthIAMToken:
+		return api.IAMToken(cfg.IAMToken), nil, nil
+	case configs.AuthServiceAccount:
+		key, err := api.LoadServiceAccountKey(cfg.SAKeyFile)
+		if err != nil {
+			return nil, nil, err
+		}
+		sa, err := api.NewServiceAccount(key, cfg.TokenURL)
+		if err != nil {
+			return nil, nil, err
+		}
+		return sa, nil, nil
+	default:
+		return api.APIKey(cfg.YandexAPIKey), api.APIKey(cfg.ImageAPIKey), nil
+	}
+}
+
+// fatal пишет ошибку запуска и завершает процесс
+func fatal(msg string, err error) {
+	slog.Error(msg, "err", err)
+	os.Exit(1)
 }
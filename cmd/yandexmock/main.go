@@ -0,0 +1,46 @@
+// Команда yandexmock запускает локальный мок Yandex Foundation Models,
+// чтобы бот работал без облака:
+//
+//	go run ./cmd/yandexmock -addr :8090
+//	YANDEX_BASE_URL=http://localhost:8090 go run ./cmd/bot
+package main
+
+import (
+	"flag"
+	"log/slog"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/d1mk9/tgChanPost/internal/api/yandextest"
+)
+
+func main() {
+	addr := flag.String("addr", ":8090", "адрес мока")
+	completion := flag.String("completion", yandextest.DefaultCompletion, "ответ модели")
+	imageFile := flag.String("image", "", "JPEG, который вернет генерация изображения")
+	pending := flag.Int("pending", 1, "сколько опросов операция остается незавершенной")
+	streamParts := flag.Int("stream-parts", 4, "на сколько частей делится потоковый ответ")
+	streamDelay := flag.Duration("stream-delay", 700*time.Millisecond, "пауза между частями потокового ответа")
+	flag.Parse()
+
+	server := yandextest.NewServer()
+	server.SetCompletion(*completion)
+	server.SetPendingPolls(*pending)
+	server.SetStream(*streamParts, *streamDelay)
+
+	if *imageFile != "" {
+		data, err := os.ReadFile(*imageFile)
+		if err != nil {
+			slog.Error("Ошибка чтения изображения", "err", err)
+			os.Exit(1)
+		}
+		server.SetImage(data)
+	}
+
+	slog.Info("Мок Yandex Foundation Models запущен", "addr", *addr)
+	if err := http.ListenAndServe(*addr, server); err != nil {
+		slog.Error("Ошибка сервера", "err", err)
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,268 @@
+package configs
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/d1mk9/tgChanPost/internal/lang"
+)
+
+// Способы оформления поста
+const (
+	PostStyleCaption = "caption" // Цитата в подписи к изображению
+	PostStyleOverlay = "overlay" // Цитата наложена на изображение
+)
+
+// Режимы разметки подписи
+const (
+	ParseModeMarkdownV2 = "MarkdownV2"
+	ParseModeHTML       = "HTML"
+)
+
+// Channel настройки канала для публикации
+type Channel struct {
+	ID        string           `json:"id" yaml:"id"`               // @username или числовой ID канала
+	Signature string           `json:"signature" yaml:"signature"` // Текст ссылки на канал под цитатой
+	Link      string           `json:"link" yaml:"link"`           // Ссылка на канал
+	PostStyle string           `json:"post_style" yaml:"post_style"`
+	ParseMode string           `json:"parse_mode" yaml:"parse_mode"`             // MarkdownV2 или HTML
+	Template  string           `json:"caption_template" yaml:"caption_template"` // Шаблон подписи, пусто - по умолчанию
+	Overlay   *OverlayConfig   `json:"overlay,omitempty" yaml:"overlay,omitempty"`
+	Watermark *WatermarkConfig `json:"watermark,omitempty" yaml:"watermark,omitempty"` // Логотип или подпись канала на изображении
+	Slots     []string         `json:"slots,omitempty" yaml:"slots,omitempty"`         // Время публикации из очереди, например 09:00, по местному времени
+	Topics    *TopicsConfig    `json:"topics,omitempty" yaml:"topics,omitempty"`       // Ротация тем, /topics
+	Language  string           `json:"language,omitempty" yaml:"language,omitempty"`   // Язык цитат и подписей (ISO 639-1), по умолчанию ru
+	// ShowOriginal цитата запрашивается на языке оригинала; если он отличается
+	// от языка канала, цитата переводится, а оригинал показывается рядом с переводом
+	ShowOriginal bool `json:"show_original,omitempty" yaml:"show_original,omitempty"`
+}
+
+// OverlayConfig параметры наложения цитаты на изображение
+type OverlayConfig struct {
+	QuoteFontFile  string   `json:"quote_font_file" yaml:"quote_font_file"`   // Пусто - встроенный шрифт с кириллицей
+	AuthorFontFile string   `json:"author_font_file" yaml:"author_font_file"` // Пусто - встроенный курсив
+	FontScale      float64  `json:"font_scale" yaml:"font_scale"`             // Размер шрифта относительно ширины изображения
+	Position       string   `json:"position" yaml:"position"`                 // top, center или bottom
+	Darkness       *float64 `json:"darkness" yaml:"darkness"`                 // Непрозрачность градиента от 0 до 1
+}
+
+// WatermarkConfig параметры водяного знака канала
+type WatermarkConfig struct {
+	ImageFile string   `json:"image_file" yaml:"image_file"` // PNG с логотипом
+	Text      string   `json:"text" yaml:"text"`             // Подпись, если логотип не задан, например @offthepages
+	FontFile  string   `json:"font_file" yaml:"font_file"`   // Шрифт подписи, пусто - встроенный
+	Scale     float64  `json:"scale" yaml:"scale"`           // Размер относительно ширины изображения
+	Opacity   *float64 `json:"opacity" yaml:"opacity"`       // Непрозрачность от 0 до 1
+	Corner    string   `json:"corner" yaml:"corner"`         // top-left, top-right, bottom-left или bottom-right
+	Margin    *int     `json:"margin" yaml:"margin"`         // Отступ от краев в пикселях
+}
+
+// slotLayout формат слота публикации
+const slotLayout = "15:04"
+
+// Значения по умолчанию для оформления изображений
+const (
+	DefaultDarkness         = 0.7
+	DefaultWatermarkOpacity = 0.6
+	DefaultWatermarkMargin  = 24
+)
+
+// defaultChannels используется, если файл с каналами не указан
+var defaultChannels = []Channel{
+	{
+		ID:        "@offthepages",
+		Signature: "Мысли, сошедшие со страниц",
+		Link:      "https://t.me/offthepages",
+		PostStyle: PostStyleCaption,
+		ParseMode: ParseModeMarkdownV2,
+	},
+}
+
+// LoadChannels читает список каналов из JSON-файла.
+// Если путь пустой, возвращается копия канала по умолчанию.
+func LoadChannels(path string) ([]Channel, error) {
+	if path == "" {
+		channels := append([]Channel(nil), defaultChannels...)
+		if err := validateChannels(channels); err != nil {
+			return nil, err
+		}
+		return channels, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка чтения файла каналов: %w", err)
+	}
+
+	var channels []Channel
+	if err := json.Unmarshal(data, &channels); err != nil {
+		return nil, fmt.Errorf("ошибка декодирования файла каналов: %w", err)
+	}
+	if len(channels) == 0 {
+		return nil, fmt.Errorf("в файле %s не указано ни одного канала", path)
+	}
+	if err := validateChannels(channels); err != nil {
+		return nil, err
+	}
+
+	return channels, nil
+}
+
+// validateChannels проверяет каналы, сообщая обо всех ошибках сразу,
+// и подставляет значения по умолчанию
+func validateChannels(channels []Channel) error {
+	var errs []error
+	seen := make(map[string]bool)
+
+	for i := range channels {
+		ch := &channels[i]
+		if ch.ID == "" {
+			errs = append(errs, fmt.Errorf("канал #%d: не указан id", i+1))
+		} else if seen[ch.ID] {
+			errs = append(errs, fmt.Errorf("канал %s указан дважды", ch.ID))
+		}
+		seen[ch.ID] = true
+
+		switch ch.PostStyle {
+		case "":
+			ch.PostStyle = PostStyleCaption
+		case PostStyleCaption, PostStyleOverlay:
+		default:
+			errs = append(errs, fmt.Errorf("канал %s: неизвестный post_style %q", ch.ID, ch.PostStyle))
+		}
+		switch ch.ParseMode {
+		case "":
+			ch.ParseMode = ParseModeMarkdownV2
+		case ParseModeMarkdownV2, ParseModeHTML:
+		default:
+			errs = append(errs, fmt.Errorf("канал %s: неизвестный parse_mode %q", ch.ID, ch.ParseMode))
+		}
+		if ch.Language == "" {
+			ch.Language = lang.Default
+		} else if _, ok := lang.Get(ch.Language); !ok {
+			errs = append(errs, fmt.Errorf("канал %s: неподдерживаемый язык %q, доступны: %s", ch.ID, ch.Language, strings.Join(lang.Codes(), ", ")))
+		}
+		slots := make(map[string]bool)
+		for _, slot := range ch.Slots {
+			if _, err := time.Parse(slotLayout, slot); err != nil {
+				errs = append(errs, fmt.Errorf("канал %s: некорректный слот %q, ожидается ЧЧ:ММ", ch.ID, slot))
+			} else if slots[slot] {
+				errs = append(errs, fmt.Errorf("канал %s: слот %s указан дважды", ch.ID, slot))
+			}
+			slots[slot] = true
+		}
+		if ch.Topics != nil {
+			errs = append(errs, validateTopics(ch.ID, ch.Topics)...)
+			if ch.Topics.AutofillChat != 0 && len(ch.Slots) == 0 {
+				errs = append(errs, fmt.Errorf("канал %s: topics.autofill_chat требует слотов публикации (slots)", ch.ID))
+			}
+		}
+		if ch.PostStyle == PostStyleOverlay && ch.Overlay == nil {
+			ch.Overlay = &OverlayConfig{}
+		}
+		errs = append(errs, validateImaging(ch)...)
+	}
+
+	return errors.Join(errs...)
+}
+
+// validateImaging проверяет оформление изображений канала: наложение цитаты
+// и водяной знак. Файлы только проверяются на наличие, разбираются они при запуске бота.
+func validateImaging(ch *Channel) []error {
+	var errs []error
+	fail := func(format string, args ...any) {
+		errs = append(errs, fmt.Errorf("канал %s: "+format, append([]any{ch.ID}, args...)...))
+	}
+	checkFile := func(field, path string) {
+		if path == "" {
+			return
+		}
+		if _, err := os.Stat(path); err != nil {
+			fail("%s: файл %s недоступен: %v", field, path, err)
+		}
+	}
+
+	if o := ch.Overlay; o != nil && ch.PostStyle == PostStyleOverlay {
+		switch o.Position {
+		case "", "top", "center", "bottom":
+		default:
+			fail("overlay.position %q, ожидается top, center или bottom", o.Position)
+		}
+		if o.Darkness != nil && (*o.Darkness < 0 || *o.Darkness > 1) {
+			fail("overlay.darkness должно быть от 0 до 1: %v", *o.Darkness)
+		}
+		if o.FontScale < 0 {
+			fail("overlay.font_scale не может быть отрицательным: %v", o.FontScale)
+		}
+		checkFile("overlay.quote_font_file", o.QuoteFontFile)
+		checkFile("overlay.author_font_file", o.AuthorFontFile)
+	}
+
+	if w := ch.Watermark; w != nil {
+		switch w.Corner {
+		case "", "top-left", "top-right", "bottom-left", "bottom-right":
+		default:
+			fail("watermark.corner %q, ожидается top-left, top-right, bottom-left или bottom-right", w.Corner)
+		}
+		if w.Opacity != nil && (*w.Opacity <= 0 || *w.Opacity > 1) {
+			fail("watermark.opacity должно быть больше 0 и не больше 1: %v", *w.Opacity)
+		}
+		if w.Margin != nil && *w.Margin < 0 {
+			fail("watermark.margin не может быть отрицательным: %d", *w.Margin)
+		}
+		if w.Scale < 0 {
+			fail("watermark.scale не может быть отрицательным: %v", w.Scale)
+		}
+		if w.ImageFile == "" && w.Text == "" {
+			fail("для watermark нужно указать image_file или text")
+		}
+		checkFile("watermark.image_file", w.ImageFile)
+		checkFile("watermark.font_file", w.FontFile)
+	}
+	return errs
+}
+
+// Channel возвращает настройки канала по ID
+func (c *Config) Channel(id string) (Channel, bool) {
+	for _, ch := range c.Channels {
+		if ch.ID == id {
+			return ch, true
+		}
+	}
+	return Channel{}, false
+}
+
+// SlotTimes возвращает n ближайших слотов публикации канала строго после after
+// в часовом поясе after. Без слотов возвращается nil.
+func (ch Channel) SlotTimes(after time.Time, n int) []time.Time {
+	var offsets []time.Duration
+	for _, slot := range ch.Slots {
+		t, err := time.Parse(slotLayout, slot)
+		if err != nil {
+			continue
+		}
+		offsets = append(offsets, time.Duration(t.Hour())*time.Hour+time.Duration(t.Minute())*time.Minute)
+	}
+	if len(offsets) == 0 || n <= 0 {
+		return nil
+	}
+	sort.Slice(offsets, func(i, j int) bool { return offsets[i] < offsets[j] })
+
+	times := make([]time.Time, 0, n)
+	y, m, d := after.Date()
+	for day := 0; len(times) < n; day++ {
+		for _, offset := range offsets {
+			// Слот задается по часам, поэтому при переходе на летнее время он не сдвигается
+			t := time.Date(y, m, d+day, int(offset/time.Hour), int(offset%time.Hour/time.Minute), 0, 0, after.Location())
+			if t.After(after) && len(times) < n {
+				times = append(times, t)
+			}
+		}
+	}
+	return times
+}
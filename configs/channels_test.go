@@ -0,0 +1,58 @@
+package configs
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestValidateChannelsImaging(t *testing.T) {
+	darkness, opacity, margin := 1.5, 0.0, -4
+
+	channels := []Channel{
+		{
+			ID:        "@overlay",
+			PostStyle: PostStyleOverlay,
+			Overlay:   &OverlayConfig{Position: "left", Darkness: &darkness, QuoteFontFile: "нет/такого.ttf"},
+		},
+		{
+			ID:        "@watermark",
+			Watermark: &WatermarkConfig{Corner: "middle", Opacity: &opacity, Margin: &margin},
+		},
+	}
+
+	err := validateChannels(channels)
+	if err == nil {
+		t.Fatal("ожидались ошибки оформления")
+	}
+
+	// Все ошибки сообщаются сразу, а не по одной при запуске бота
+	for _, want := range []string{
+		"overlay.position",
+		"overlay.darkness",
+		"overlay.quote_font_file",
+		"watermark.corner",
+		"watermark.opacity",
+		"watermark.margin",
+		"image_file или text",
+	} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("нет ошибки %q в %v", want, err)
+		}
+	}
+}
+
+func TestValidateChannelsImagingDefaults(t *testing.T) {
+	channels := []Channel{
+		{ID: "@overlay", PostStyle: PostStyleOverlay},
+		{ID: "@watermark", Watermark: &WatermarkConfig{Text: "@watermark"}},
+		// Наложение без post_style: overlay не используется и не проверяется
+		{ID: "@caption", Overlay: &OverlayConfig{Position: "left"}},
+	}
+
+	if err := validateChannels(channels); err != nil {
+		t.Fatal(err)
+	}
+	if channels[0].Overlay == nil {
+		t.Fatal("для post_style overlay должны подставляться параметры по умолчанию")
+	}
+}
@@ -1,45 +1,302 @@
 package configs
 
 import (
-	"log"
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/url"
 	"os"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Способы аутентификации в Yandex Cloud (yandex_auth)
+const (
+	AuthAPIKey         = "api_key"         // Статические API-ключи yandex_api_key и image_api_key
+	AuthIAMToken       = "iam_token"       // Готовый IAM-токен yandex_iam_token
+	AuthServiceAccount = "service_account" // Ключ сервисного аккаунта, IAM-токен выпускается и обновляется ботом
 )
 
-// Config содержит все глобальные конфигурационные параметры
+// Config содержит все конфигурационные параметры
 type Config struct {
-	BotToken     string
-	YandexAPIKey string
-	CatalogID    string
-	ImageAPIKey  string
+	BotToken     string       `yaml:"bot_token"`
+	YandexAPIKey string       `yaml:"yandex_api_key"`
+	CatalogID    string       `yaml:"catalog_id"`
+	ImageAPIKey  string       `yaml:"image_api_key"`
+	YandexAuth   string       `yaml:"yandex_auth"`        // api_key, iam_token или service_account
+	IAMToken     string       `yaml:"yandex_iam_token"`   // IAM-токен для yandex_auth: iam_token
+	SAKeyFile    string       `yaml:"yandex_sa_key_file"` // Авторизованный ключ сервисного аккаунта для yandex_auth: service_account
+	TokenURL     string       `yaml:"yandex_token_url"`   // Адрес обмена JWT на IAM-токен, пусто - облако; для локального мока
+	YandexURL    string       `yaml:"yandex_base_url"`    // Адрес Foundation Models, пусто - облако; для локального мока
+	ChannelsFile string       `yaml:"channels_file"`      // JSON-файл с каналами, если они не указаны в channels
+	Channels     []Channel    `yaml:"channels"`           // Каналы для публикации, первый используется по умолчанию
+	Usage        UsageConfig  `yaml:"usage"`              // Цены и бюджеты для учета расходов
+	Limits       LimitsConfig `yaml:"limits"`             // Ограничения частоты генерации
+	AuthorsFile  string       `yaml:"authors_file"`       // JSON-реестр авторов с каноническими именами, необязательный
+	QuotesFile   string       `yaml:"quotes_file"`        // JSON-корпус известных цитат для проверки атрибуции
+	MetricsAddr  string       `yaml:"metrics_addr"`       // Адрес HTTP-сервера с /metrics, пусто - метрики отключены
+	AdminAddr    string       `yaml:"admin_addr"`         // Адрес HTTP-сервера с /healthz и /readyz, пусто - отключен
+	LogLevel     string       `yaml:"log_level"`          // debug, info, warn или error
+	LogFormat    string       `yaml:"log_format"`         // text или json
+
+	path string   // YAML-файл, из которого загружена конфигурация
+	args []string // Аргументы командной строки для повторной загрузки
+}
+
+// fileConfig содержимое YAML-файла: параметры и пути к файлам с секретами
+type fileConfig struct {
+	Config           `yaml:",inline"`
+	BotTokenFile     string `yaml:"bot_token_file"`
+	YandexAPIKeyFile string `yaml:"yandex_api_key_file"`
+	ImageAPIKeyFile  string `yaml:"image_api_key_file"`
+	IAMTokenFile     string `yaml:"yandex_iam_token_file"`
+}
+
+// field параметр, который можно задать в файле, окружении и флагом.
+// Секреты не принимаются флагами, чтобы не попадать в список процессов,
+// зато читаются из файла по переменной <ENV>_FILE (Docker secrets).
+type field struct {
+	key    string // Ключ в YAML, флаг получается заменой _ на -
+	env    string
+	dst    *string
+	secret bool
+}
+
+func (c *Config) fields() []field {
+	return []field{
+		{"bot_token", "TELEGRAM_APITOKEN2", &c.BotToken, true},
+		{"yandex_api_key", "YANDEX_API_KEY", &c.YandexAPIKey, true},
+		{"catalog_id", "YANDEX_CATALOG_ID", &c.CatalogID, false},
+		{"image_api_key", "YANDEX_API_ART_KEY", &c.ImageAPIKey, true},
+		{"yandex_auth", "YANDEX_AUTH", &c.YandexAuth, false},
+		{"yandex_iam_token", "YANDEX_IAM_TOKEN", &c.IAMToken, true},
+		{"yandex_sa_key_file", "YANDEX_SA_KEY_FILE", &c.SAKeyFile, false},
+		{"yandex_token_url", "YANDEX_TOKEN_URL", &c.TokenURL, false},
+		{"yandex_base_url", "YANDEX_BASE_URL", &c.YandexURL, false},
+		{"channels_file", "CHANNELS_FILE", &c.ChannelsFile, false},
+		{"authors_file", "AUTHORS_FILE", &c.AuthorsFile, false},
+		{"quotes_file", "QUOTES_FILE", &c.QuotesFile, false},
+		{"metrics_addr", "METRICS_ADDR", &c.MetricsAddr, false},
+		{"admin_addr", "ADMIN_ADDR", &c.AdminAddr, false},
+		{"log_level", "LOG_LEVEL", &c.LogLevel, false},
+		{"log_format", "LOG_FORMAT", &c.LogFormat, false},
+	}
+}
+
+// Load собирает конфигурацию по слоям: значения по умолчанию, YAML-файл
+// (флаг -config или CONFIG_FILE), переменные окружения и флаги командной строки.
+// Каждый следующий слой перекрывает предыдущий. Ошибки проверки возвращаются
+// все сразу, а не по одной.
+func Load(args []string) (Config, error) {
+	cfg := Config{
+		YandexAuth: AuthAPIKey,
+		QuotesFile: "quotes.json",
+		LogLevel:   "info",
+		LogFormat:  "text",
+	}
+
+	fs := flag.NewFlagSet("tgchanpost", flag.ContinueOnError)
+	path := fs.String("config", os.Getenv("CONFIG_FILE"), "YAML-файл конфигурации")
+	flagKeys := make(map[string]string)
+	for _, f := range cfg.fields() {
+		if f.secret {
+			continue
+		}
+		name := strings.ReplaceAll(f.key, "_", "-")
+		fs.String(name, "", "перекрывает "+f.key+" и "+f.env)
+		flagKeys[name] = f.key
+	}
+	if err := fs.Parse(args); err != nil {
+		return Config{}, err
+	}
+
+	var errs []error
+	secretFiles := make(map[string]string)
+
+	if *path != "" {
+		fc, err := readFile(*path, cfg)
+		if err != nil {
+			return Config{}, err
+		}
+		cfg = fc.Config
+		cfg.path = *path
+		secretFiles["bot_token"] = fc.BotTokenFile
+		secretFiles["yandex_api_key"] = fc.YandexAPIKeyFile
+		secretFiles["image_api_key"] = fc.ImageAPIKeyFile
+		secretFiles["yandex_iam_token"] = fc.IAMTokenFile
+	}
+
+	fields := make(map[string]field)
+	for _, f := range cfg.fields() {
+		fields[f.key] = f
+		if f.secret {
+			if file := os.Getenv(f.env + "_FILE"); file != "" {
+				secretFiles[f.key] = file
+			}
+		}
+		if v := os.Getenv(f.env); v != "" {
+			*f.dst = v
+			delete(secretFiles, f.key)
+		}
+		if file := secretFiles[f.key]; file != "" {
+			v, err := readSecret(file)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("%s: %w", f.key, err))
+				continue
+			}
+			*f.dst = v
+		}
+	}
+
+	fs.Visit(func(fl *flag.Flag) {
+		if key, ok := flagKeys[fl.Name]; ok {
+			*fields[key].dst = fl.Value.String()
+		}
+	})
+
+	if err := cfg.loadChannels(); err != nil {
+		errs = append(errs, err)
+	}
+	if err := cfg.Validate(); err != nil {
+		errs = append(errs, err)
+	}
+	if len(errs) > 0 {
+		return Config{}, fmt.Errorf("ошибка конфигурации:\n%w", errors.Join(errs...))
+	}
+
+	cfg.args = args
+	return cfg, nil
 }
 
-// GlobalConfig - глобальная переменная для хранения конфигурации
-var GlobalConfig Config
+// readFile читает YAML поверх значений по умолчанию; неизвестные ключи считаются ошибкой,
+// чтобы опечатка в имени параметра не проходила молча
+func readFile(path string, defaults Config) (fileConfig, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fileConfig{}, fmt.Errorf("ошибка чтения файла конфигурации: %w", err)
+	}
 
-func LoadConfig() {
-	/*err := godotenv.Load()
+	fc := fileConfig{Config: defaults}
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	if err := dec.Decode(&fc); err != nil {
+		return fileConfig{}, fmt.Errorf("ошибка разбора файла конфигурации %s: %w", path, err)
+	}
+	return fc, nil
+}
+
+// readSecret читает секрет из файла без завершающего перевода строки
+func readSecret(path string) (string, error) {
+	data, err := os.ReadFile(path)
 	if err != nil {
-		log.Fatal("Ошибка загрузки файла .env")
-	}*/
+		return "", fmt.Errorf("ошибка чтения секрета: %w", err)
+	}
+	secret := strings.TrimSpace(string(data))
+	if secret == "" {
+		return "", fmt.Errorf("файл %s пустой", path)
+	}
+	return secret, nil
+}
 
-	GlobalConfig.BotToken = os.Getenv("TELEGRAM_APITOKEN2")
-	if GlobalConfig.BotToken == "" {
-		log.Fatal("Переменная окружения TELEGRAM_APITOKEN2 не установлена")
+// loadChannels берет каналы из YAML или из channels_file и применяет значения по умолчанию
+func (c *Config) loadChannels() error {
+	if len(c.Channels) > 0 {
+		if c.ChannelsFile != "" {
+			return errors.New("каналы указаны и в channels, и в channels_file: оставьте что-то одно")
+		}
+		return validateChannels(c.Channels)
 	}
 
-	GlobalConfig.YandexAPIKey = os.Getenv("YANDEX_API_KEY")
-	if GlobalConfig.YandexAPIKey == "" {
-		log.Fatal("Переменная окружения YANDEX_API_KEY не установлена")
+	channels, err := LoadChannels(c.ChannelsFile)
+	if err != nil {
+		return err
+	}
+	c.Channels = channels
+	return nil
+}
+
+// Validate проверяет обязательные параметры и форматы значений
+func (c *Config) Validate() error {
+	var errs []error
+
+	if c.BotToken == "" {
+		errs = append(errs, errors.New("не задан bot_token (TELEGRAM_APITOKEN2 или TELEGRAM_APITOKEN2_FILE)"))
 	}
+	if err := c.Credentials(); err != nil {
+		errs = append(errs, err)
+	}
+
+	if err := validateUsage(&c.Usage); err != nil {
+		errs = append(errs, err)
+	}
+	if err := validateLimits(&c.Limits); err != nil {
+		errs = append(errs, err)
+	}
+
+	var lvl slog.Level
+	if err := lvl.UnmarshalText([]byte(c.LogLevel)); err != nil {
+		errs = append(errs, fmt.Errorf("неизвестный log_level %q", c.LogLevel))
+	}
+	switch strings.ToLower(c.LogFormat) {
+	case "", "text", "json":
+	default:
+		errs = append(errs, fmt.Errorf("неизвестный log_format %q", c.LogFormat))
+	}
+
+	for key, v := range map[string]string{"yandex_base_url": c.YandexURL, "yandex_token_url": c.TokenURL} {
+		if v == "" {
+			continue
+		}
+		if u, err := url.Parse(v); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			errs = append(errs, fmt.Errorf("некорректный %s %q: ожидается http(s)://host", key, v))
+		}
+	}
+
+	for _, f := range c.fields() {
+		if !strings.HasSuffix(f.key, "_addr") || *f.dst == "" {
+			continue
+		}
+		if _, _, err := net.SplitHostPort(*f.dst); err != nil {
+			errs = append(errs, fmt.Errorf("некорректный %s %q: %v", f.key, *f.dst, err))
+		}
+	}
+
+	return errors.Join(errs...)
+}
 
-	GlobalConfig.CatalogID = os.Getenv("YANDEX_CATALOG_ID")
-	if GlobalConfig.CatalogID == "" {
-		log.Fatal("Переменная окружения YANDEX_CATALOG_ID не установлена")
+// Credentials проверяет, что для выбранного yandex_auth заданы учетные данные
+// и каталог. Используется при загрузке и в проверке готовности.
+func (c *Config) Credentials() error {
+	var errs []error
+	if c.CatalogID == "" {
+		errs = append(errs, errors.New("не задан catalog_id (YANDEX_CATALOG_ID)"))
 	}
 
-	GlobalConfig.ImageAPIKey = os.Getenv("YANDEX_API_ART_KEY")
-	if GlobalConfig.ImageAPIKey == "" {
-		log.Fatal("Переменная окружения YANDEX_API_ART_KEY не установлена")
+	switch c.YandexAuth {
+	case "", AuthAPIKey:
+		if c.YandexAPIKey == "" {
+			errs = append(errs, errors.New("не задан yandex_api_key (YANDEX_API_KEY или YANDEX_API_KEY_FILE)"))
+		}
+		if c.ImageAPIKey == "" {
+			errs = append(errs, errors.New("не задан image_api_key (YANDEX_API_ART_KEY или YANDEX_API_ART_KEY_FILE)"))
+		}
+	case AuthIAMToken:
+		if c.IAMToken == "" {
+			errs = append(errs, errors.New("не задан yandex_iam_token (YANDEX_IAM_TOKEN или YANDEX_IAM_TOKEN_FILE)"))
+		}
+	case AuthServiceAccount:
+		if c.SAKeyFile == "" {
+			errs = append(errs, errors.New("не задан yandex_sa_key_file (YANDEX_SA_KEY_FILE)"))
+		} else if _, err := os.Stat(c.SAKeyFile); err != nil {
+			errs = append(errs, fmt.Errorf("yandex_sa_key_file: %w", err))
+		}
+	default:
+		errs = append(errs, fmt.Errorf("неизвестный yandex_auth %q: ожидается %s, %s или %s", c.YandexAuth, AuthAPIKey, AuthIAMToken, AuthServiceAccount))
 	}
 
+	return errors.Join(errs...)
 }
@@ -0,0 +1,101 @@
+package configs
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Роли пользователей для ограничений частоты
+const (
+	RoleUser  = "user"
+	RoleAdmin = "admin"
+)
+
+// DefaultImageConcurrency одновременных генераций изображений по умолчанию
+const DefaultImageConcurrency = 2
+
+// RateLimit скорость пополнения и емкость корзины запросов
+type RateLimit struct {
+	PerMinute float64 `yaml:"per_minute"` // Запросов в минуту в среднем, 0 - без ограничений
+	Burst     int     `yaml:"burst"`      // Сколько запросов можно сделать подряд
+}
+
+// RoleLimits ограничения генерации для роли
+type RoleLimits struct {
+	User RateLimit `yaml:"user"` // На пользователя
+	Chat RateLimit `yaml:"chat"` // На чат, в котором пользователь делает запрос
+}
+
+// LimitsConfig ограничения частоты генерации и одновременных генераций изображений.
+// Применяется без перезапуска, как и каналы.
+type LimitsConfig struct {
+	ImageConcurrency *int                  `yaml:"image_concurrency"` // Одновременных генераций YandexART: не задано - 2, 0 - без ограничений
+	Admins           []int64               `yaml:"admins"`            // Telegram ID пользователей с ролью admin
+	Roles            map[string]RoleLimits `yaml:"roles"`             // Лимиты по ролям user и admin
+}
+
+// defaultRoles ограничения, если роль не описана в конфигурации.
+// Администраторы по умолчанию не ограничены.
+var defaultRoles = map[string]RoleLimits{
+	RoleUser: {
+		User: RateLimit{PerMinute: 3, Burst: 3},
+		Chat: RateLimit{PerMinute: 10, Burst: 5},
+	},
+	RoleAdmin: {},
+}
+
+// RoleOf возвращает роль пользователя
+func (l LimitsConfig) RoleOf(userID int64) string {
+	for _, id := range l.Admins {
+		if id == userID {
+			return RoleAdmin
+		}
+	}
+	return RoleUser
+}
+
+// ImageSlots возвращает число одновременных генераций изображений; 0 - без ограничений,
+// как у ratelimit.Semaphore
+func (l LimitsConfig) ImageSlots() int {
+	if l.ImageConcurrency == nil {
+		return DefaultImageConcurrency
+	}
+	return *l.ImageConcurrency
+}
+
+// For возвращает ограничения роли
+func (l LimitsConfig) For(role string) RoleLimits {
+	return l.Roles[role]
+}
+
+// validateLimits проверяет ограничения и подставляет значения по умолчанию
+func validateLimits(l *LimitsConfig) error {
+	if l.ImageConcurrency == nil {
+		n := DefaultImageConcurrency
+		l.ImageConcurrency = &n
+	}
+	roles := make(map[string]RoleLimits, len(defaultRoles))
+	for role, limits := range defaultRoles {
+		roles[role] = limits
+	}
+
+	var errs []error
+	if *l.ImageConcurrency < 0 {
+		errs = append(errs, fmt.Errorf("limits.image_concurrency не может быть отрицательным: %d", *l.ImageConcurrency))
+	}
+	for role, limits := range l.Roles {
+		if _, ok := defaultRoles[role]; !ok {
+			errs = append(errs, fmt.Errorf("limits.roles: неизвестная роль %q, ожидается %s или %s", role, RoleUser, RoleAdmin))
+			continue
+		}
+		for scope, rl := range map[string]RateLimit{"user": limits.User, "chat": limits.Chat} {
+			if rl.PerMinute < 0 || rl.Burst < 0 {
+				errs = append(errs, fmt.Errorf("limits.roles.%s.%s: значения не могут быть отрицательными", role, scope))
+			}
+		}
+		roles[role] = limits
+	}
+	l.Roles = roles
+
+	return errors.Join(errs...)
+}
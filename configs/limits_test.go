@@ -0,0 +1,103 @@
+package configs
+
+import (
+	"testing"
+)
+
+func TestRoleLimits(t *testing.T) {
+	custom := RoleLimits{User: RateLimit{PerMinute: 1, Burst: 1}}
+
+	tests := []struct {
+		name   string
+		limits LimitsConfig
+		userID int64
+		role   string
+		want   RoleLimits
+	}{
+		{
+			name:   "пользователь по умолчанию",
+			userID: 1,
+			role:   RoleUser,
+			want:   defaultRoles[RoleUser],
+		},
+		{
+			name:   "администратор не ограничен",
+			limits: LimitsConfig{Admins: []int64{7}},
+			userID: 7,
+			role:   RoleAdmin,
+			want:   RoleLimits{},
+		},
+		{
+			name:   "не администратор",
+			limits: LimitsConfig{Admins: []int64{7}},
+			userID: 8,
+			role:   RoleUser,
+			want:   defaultRoles[RoleUser],
+		},
+		{
+			name:   "роль из конфигурации заменяет значения целиком",
+			limits: LimitsConfig{Roles: map[string]RoleLimits{RoleUser: custom}},
+			userID: 1,
+			role:   RoleUser,
+			want:   custom,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			limits := tt.limits
+			if err := validateLimits(&limits); err != nil {
+				t.Fatal(err)
+			}
+			role := limits.RoleOf(tt.userID)
+			if role != tt.role {
+				t.Fatalf("роль %q, ожидалась %q", role, tt.role)
+			}
+			if got := limits.For(role); got != tt.want {
+				t.Fatalf("лимиты %+v, ожидались %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestImageSlots(t *testing.T) {
+	zero, four, negative := 0, 4, -1
+
+	tests := []struct {
+		name    string
+		value   *int
+		want    int
+		wantErr bool
+	}{
+		{name: "не задано", value: nil, want: DefaultImageConcurrency},
+		{name: "без ограничений", value: &zero, want: 0},
+		{name: "задано", value: &four, want: 4},
+		{name: "отрицательное", value: &negative, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			limits := LimitsConfig{ImageConcurrency: tt.value}
+			err := validateLimits(&limits)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ошибка %v, ожидалась ошибка: %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && limits.ImageSlots() != tt.want {
+				t.Fatalf("слотов %d, ожидалось %d", limits.ImageSlots(), tt.want)
+			}
+		})
+	}
+}
+
+func TestValidateLimitsErrors(t *testing.T) {
+	tests := map[string]LimitsConfig{
+		"неизвестная роль":      {Roles: map[string]RoleLimits{"guest": {}}},
+		"отрицательная частота": {Roles: map[string]RoleLimits{RoleUser: {Chat: RateLimit{PerMinute: -1}}}},
+		"отрицательный burst":   {Roles: map[string]RoleLimits{RoleAdmin: {User: RateLimit{Burst: -2}}}},
+	}
+	for name, limits := range tests {
+		if err := validateLimits(&limits); err == nil {
+			t.Errorf("%s: ожидалась ошибка", name)
+		}
+	}
+}
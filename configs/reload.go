@@ -0,0 +1,92 @@
+package configs
+
+import (
+	"os"
+	"time"
+)
+
+// reloadableKeys параметры, которые применяются без перезапуска.
+// Каналы (channels), цены и бюджеты (usage) и ограничения (limits)
+// из YAML перечитываются всегда.
+var reloadableKeys = map[string]bool{
+	"channels_file": true,
+}
+
+// Reload повторно загружает конфигурацию из тех же источников.
+// Секреты, адреса серверов, логирование и файлы хранилищ применяются
+// только при запуске: для них сохраняются текущие значения, а имена
+// измененных параметров возвращаются, чтобы предупредить о необходимости
+// перезапуска.
+func (c Config) Reload() (Config, []string, error) {
+	next, err := Load(c.args)
+	if err != nil {
+		return c, nil, err
+	}
+
+	var restartOnly []string
+	cur := c.fields()
+	for i, f := range next.fields() {
+		if reloadableKeys[f.key] || *f.dst == *cur[i].dst {
+			continue
+		}
+		restartOnly = append(restartOnly, f.key)
+		*f.dst = *cur[i].dst
+	}
+
+	return next, restartOnly, nil
+}
+
+// WatchedFiles возвращает файлы, изменение которых требует перезагрузки конфигурации
+func (c Config) WatchedFiles() []string {
+	var files []string
+	for _, path := range []string{c.path, c.ChannelsFile} {
+		if path != "" {
+			files = append(files, path)
+		}
+	}
+	return files
+}
+
+// Watcher обнаруживает изменения файлов по времени изменения и размеру.
+// Опрос не зависит от платформы и переживает замену файла через rename,
+// как это делают редакторы и Kubernetes при обновлении ConfigMap.
+type Watcher struct {
+	stamps map[string]fileStamp
+}
+
+type fileStamp struct {
+	modTime time.Time
+	size    int64
+	missing bool
+}
+
+// NewWatcher запоминает текущее состояние файлов
+func NewWatcher(files []string) *Watcher {
+	w := &Watcher{}
+	w.Changed(files)
+	return w
+}
+
+// Changed сообщает, изменился ли какой-либо из файлов с прошлой проверки
+func (w *Watcher) Changed(files []string) bool {
+	stamps := make(map[string]fileStamp, len(files))
+	for _, path := range files {
+		var st fileStamp
+		if info, err := os.Stat(path); err != nil {
+			st.missing = true
+		} else {
+			st.modTime = info.ModTime()
+			st.size = info.Size()
+		}
+		stamps[path] = st
+	}
+
+	changed := w.stamps != nil && len(stamps) != len(w.stamps)
+	for path, st := range stamps {
+		if prev, ok := w.stamps[path]; w.stamps != nil && (!ok || prev != st) {
+			changed = true
+		}
+	}
+	w.stamps = stamps
+	return changed
+}
@@ -0,0 +1,109 @@
+package configs
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Перерывы ротации тем по умолчанию
+const (
+	DefaultTopicCooldown  = 24 * time.Hour
+	DefaultAuthorCooldown = 72 * time.Hour
+)
+
+// TopicsConfig ротация тем канала: каталог с весами и перерывами
+// между постами одной темы и одного автора
+type TopicsConfig struct {
+	PromptTemplate string        `json:"prompt_template" yaml:"prompt_template"` // Шаблон запроса к модели: {{.Topic}}, {{.Prompt}}, {{.AvoidAuthors}}
+	Cooldown       string        `json:"cooldown" yaml:"cooldown"`               // Перерыв темы по умолчанию, например 24h или 2d
+	AuthorCooldown string        `json:"author_cooldown" yaml:"author_cooldown"` // Перерыв автора, 0 - без ограничения
+	Catalog        []TopicConfig `json:"catalog" yaml:"catalog"`
+	AutofillChat   int64         `json:"autofill_chat,omitempty" yaml:"autofill_chat,omitempty"` // Чат превью для автозаполнения очереди, 0 - ротация только через /topics
+}
+
+// TopicConfig тема каталога
+type TopicConfig struct {
+	Name     string  `json:"name" yaml:"name"`
+	Prompt   string  `json:"prompt" yaml:"prompt"`     // Запрос к модели, пусто - название темы
+	Weight   float64 `json:"weight" yaml:"weight"`     // Относительная частота выбора, по умолчанию 1
+	Cooldown string  `json:"cooldown" yaml:"cooldown"` // Перерыв темы, пусто - общий
+}
+
+// TopicCooldown возвращает перерыв темы
+func (t TopicsConfig) TopicCooldown(topic TopicConfig) time.Duration {
+	if topic.Cooldown != "" {
+		d, _ := parseCooldown(topic.Cooldown)
+		return d
+	}
+	if t.Cooldown != "" {
+		d, _ := parseCooldown(t.Cooldown)
+		return d
+	}
+	return DefaultTopicCooldown
+}
+
+// AuthorCooldownDuration возвращает перерыв между постами одного автора
+func (t TopicsConfig) AuthorCooldownDuration() time.Duration {
+	if t.AuthorCooldown == "" {
+		return DefaultAuthorCooldown
+	}
+	d, _ := parseCooldown(t.AuthorCooldown)
+	return d
+}
+
+// parseCooldown разбирает длительность в формате Go или в днях (3d)
+func parseCooldown(s string) (time.Duration, error) {
+	var d time.Duration
+	var err error
+	if days, ok := strings.CutSuffix(s, "d"); ok {
+		var n int
+		n, err = strconv.Atoi(days)
+		d = time.Duration(n) * 24 * time.Hour
+	} else {
+		d, err = time.ParseDuration(s)
+	}
+	if err != nil || d < 0 {
+		return 0, fmt.Errorf("некорректная длительность %q, ожидается например 36h или 3d", s)
+	}
+	return d, nil
+}
+
+// validateTopics проверяет ротацию тем канала и подставляет вес по умолчанию
+func validateTopics(channelID string, t *TopicsConfig) []error {
+	var errs []error
+	for _, s := range []string{t.Cooldown, t.AuthorCooldown} {
+		if s == "" {
+			continue
+		}
+		if _, err := parseCooldown(s); err != nil {
+			errs = append(errs, fmt.Errorf("канал %s: topics: %w", channelID, err))
+		}
+	}
+
+	seen := make(map[string]bool)
+	for i := range t.Catalog {
+		topic := &t.Catalog[i]
+		key := strings.ToLower(strings.TrimSpace(topic.Name))
+		switch {
+		case key == "":
+			errs = append(errs, fmt.Errorf("канал %s: тема #%d без названия", channelID, i+1))
+		case seen[key]:
+			errs = append(errs, fmt.Errorf("канал %s: тема %q указана дважды", channelID, topic.Name))
+		}
+		seen[key] = true
+
+		if topic.Weight == 0 {
+			topic.Weight = 1
+		} else if topic.Weight < 0 {
+			errs = append(errs, fmt.Errorf("канал %s: тема %q: отрицательный вес", channelID, topic.Name))
+		}
+		if topic.Cooldown != "" {
+			if _, err := parseCooldown(topic.Cooldown); err != nil {
+				errs = append(errs, fmt.Errorf("канал %s: тема %q: %w", channelID, topic.Name, err))
+			}
+		}
+	}
+	return errs
+}
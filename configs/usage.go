@@ -0,0 +1,61 @@
+package configs
+
+import (
+	"errors"
+	"fmt"
+)
+
+// DefaultCurrency валюта цен, если она не указана
+const DefaultCurrency = "₽"
+
+// UsageConfig цены вызовов Yandex Cloud и месячные бюджеты пользователей.
+// Применяется без перезапуска, как и каналы.
+type UsageConfig struct {
+	Currency      string            `yaml:"currency"`           // Обозначение валюты в /usage
+	TextPer1K     float64           `yaml:"text_per_1k_tokens"` // Цена 1000 токенов YandexGPT (запрос и ответ)
+	ImagePrice    float64           `yaml:"image_price"`        // Цена одной генерации YandexART
+	MonthlyBudget float64           `yaml:"monthly_budget"`     // Бюджет пользователя на месяц, 0 - без ограничений
+	UserBudgets   map[int64]float64 `yaml:"user_budgets"`       // Бюджеты по Telegram ID пользователя, перекрывают monthly_budget
+}
+
+// TextCost стоимость генерации текста по количеству токенов
+func (u UsageConfig) TextCost(tokens int64) float64 {
+	return float64(tokens) / 1000 * u.TextPer1K
+}
+
+// ImageCost стоимость генерации изображений
+func (u UsageConfig) ImageCost(images int64) float64 {
+	return float64(images) * u.ImagePrice
+}
+
+// BudgetFor месячный бюджет пользователя; 0 - без ограничений
+func (u UsageConfig) BudgetFor(userID int64) float64 {
+	if budget, ok := u.UserBudgets[userID]; ok {
+		return budget
+	}
+	return u.MonthlyBudget
+}
+
+// validateUsage проверяет цены и бюджеты и подставляет валюту по умолчанию
+func validateUsage(u *UsageConfig) error {
+	if u.Currency == "" {
+		u.Currency = DefaultCurrency
+	}
+
+	var errs []error
+	if u.TextPer1K < 0 {
+		errs = append(errs, fmt.Errorf("usage.text_per_1k_tokens не может быть отрицательным: %v", u.TextPer1K))
+	}
+	if u.ImagePrice < 0 {
+		errs = append(errs, fmt.Errorf("usage.image_price не может быть отрицательным: %v", u.ImagePrice))
+	}
+	if u.MonthlyBudget < 0 {
+		errs = append(errs, fmt.Errorf("usage.monthly_budget не может быть отрицательным: %v", u.MonthlyBudget))
+	}
+	for userID, budget := range u.UserBudgets {
+		if budget < 0 {
+			errs = append(errs, fmt.Errorf("usage.user_budgets[%d] не может быть отрицательным: %v", userID, budget))
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,252 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultTokenURL адрес обмена JWT сервисного аккаунта на IAM-токен
+const DefaultTokenURL = "https://iam.api.cloud.yandex.net/iam/v1/tokens"
+
+const (
+	jwtLifetime     = time.Hour       // Максимальный срок жизни JWT для обмена
+	tokenRefreshMax = time.Hour       // IAM-токен обновляется не реже раза в час
+	tokenRetryDelay = 5 * time.Second // Пауза перед повторным обменом после ошибки
+)
+
+// Authenticator выдает значение заголовка Authorization для запросов к Yandex Cloud
+type Authenticator interface {
+	Authorization(ctx context.Context) (string, error)
+}
+
+// APIKey аутентификация по статическому API-ключу
+type APIKey string
+
+// Authorization возвращает заголовок Api-Key
+func (k APIKey) Authorization(context.Context) (string, error) {
+	return "Api-Key " + string(k), nil
+}
+
+// IAMToken аутентификация по IAM-токену, полученному вне бота,
+// например из метаданных виртуальной машины
+type IAMToken string
+
+// Authorization возвращает заголовок Bearer
+func (t IAMToken) Authorization(context.Context) (string, error) {
+	return "Bearer " + string(t), nil
+}
+
+// ServiceAccountKey авторизованный ключ сервисного аккаунта
+// в формате, который выгружает yc iam key create
+type ServiceAccountKey struct {
+	ID               string `json:"id"`
+	ServiceAccountID string `json:"service_account_id"`
+	PrivateKey       string `json:"private_key"`
+}
+
+// LoadServiceAccountKey читает авторизованный ключ из JSON-файла
+func LoadServiceAccountKey(path string) (ServiceAccountKey, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return ServiceAccountKey{}, fmt.Errorf("ошибка чтения ключа сервисного аккаунта: %w", err)
+	}
+
+	var key ServiceAccountKey
+	if err := json.Unmarshal(data, &key); err != nil {
+		return ServiceAccountKey{}, fmt.Errorf("ошибка декодирования ключа сервисного аккаунта: %w", err)
+	}
+	if key.ID == "" || key.ServiceAccountID == "" || key.PrivateKey == "" {
+		return ServiceAccountKey{}, fmt.Errorf("в ключе %s нет id, service_account_id или private_key", path)
+	}
+	return key, nil
+}
+
+// ServiceAccount аутентификация сервисным аккаунтом: подписанный PS256 JWT
+// обменивается на IAM-токен, который кэшируется и обновляется до истечения срока
+type ServiceAccount struct {
+	key      ServiceAccountKey
+	signer   *rsa.PrivateKey
+	tokenURL string
+	http     *http.Client
+
+	mu        sync.Mutex
+	token     string
+	refreshAt time.Time
+	expiresAt time.Time
+	retryAt   time.Time
+	lastErr   error
+}
+
+// NewServiceAccount создает аутентификацию по ключу. Пустой tokenURL - DefaultTokenURL,
+// другой адрес нужен для локальной замены IAM.
+func NewServiceAccount(key ServiceAccountKey, tokenURL string) (*ServiceAccount, error) {
+	signer, err := parsePrivateKey(key.PrivateKey)
+	if err != nil {
+		return nil, err
+	}
+	if tokenURL == "" {
+		tokenURL = DefaultTokenURL
+	}
+
+	return &ServiceAccount{
+		key:      key,
+		signer:   signer,
+		tokenURL: tokenURL,
+		http:     &http.Client{Timeout: 10 * time.Second},
+	}, nil
+}
+
+// Authorization возвращает заголовок Bearer с действующим IAM-токеном.
+// Токен обновляется заранее; если обновить не удалось, а старый еще действует,
+// используется старый.
+func (sa *ServiceAccount) Authorization(ctx context.Context) (string, error) {
+	sa.mu.Lock()
+	defer sa.mu.Unlock()
+
+	now := time.Now()
+	if sa.token != "" && now.Before(sa.refreshAt) {
+		return "Bearer " + sa.token, nil
+	}
+
+	if now.Before(sa.retryAt) {
+		if sa.token != "" && now.Before(sa.expiresAt) {
+			return "Bearer " + sa.token, nil
+		}
+		return "", sa.lastErr
+	}
+
+	token, expiresAt, err := sa.exchange(ctx, now)
+	if err != nil {
+		sa.lastErr = err
+		sa.retryAt = now.Add(tokenRetryDelay)
+		if sa.token != "" && now.Before(sa.expiresAt) {
+			return "Bearer " + sa.token, nil
+		}
+		return "", err
+	}
+
+	lifetime := expiresAt.Sub(now)
+	refresh := lifetime / 10 * 9
+	if refresh > tokenRefreshMax {
+		refresh = tokenRefreshMax
+	}
+
+	sa.token = token
+	sa.expiresAt = expiresAt
+	sa.refreshAt = now.Add(refresh)
+	sa.lastErr = nil
+	return "Bearer " + token, nil
+}
+
+// exchange подписывает JWT и обменивает его на IAM-токен
+func (sa *ServiceAccount) exchange(ctx context.Context, now time.Time) (string, time.Time, error) {
+	jwt, err := sa.signJWT(now)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+
+	body, err := json.Marshal(map[string]string{"jwt": jwt})
+	if err != nil {
+		return "", time.Time{}, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, sa.tokenURL, bytes.NewReader(body))
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := sa.http.Do(req)
+	if err != nil {
+		return "", time.Time{}, fmt.Errorf("IAM token exchange: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		data, _ := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+		return "", time.Time{}, fmt.Errorf("IAM token exchange: %w", parseAPIError(resp.StatusCode, data))
+	}
+
+	var token struct {
+		IAMToken  string    `json:"iamToken"`
+		ExpiresAt time.Time `json:"expiresAt"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&token); err != nil {
+		return "", time.Time{}, fmt.Errorf("IAM token exchange: decode response: %w", err)
+	}
+	if token.IAMToken == "" || !token.ExpiresAt.After(now) {
+		return "", time.Time{}, fmt.Errorf("IAM token exchange: empty or expired token in response")
+	}
+
+	return token.IAMToken, token.ExpiresAt, nil
+}
+
+// signJWT создает JWT для обмена: заголовок с kid ключа, подпись PS256
+func (sa *ServiceAccount) signJWT(now time.Time) (string, error) {
+	header, err := json.Marshal(map[string]string{"typ": "JWT", "alg": "PS256", "kid": sa.key.ID})
+	if err != nil {
+		return "", err
+	}
+	claims, err := json.Marshal(map[string]any{
+		"iss": sa.key.ServiceAccountID,
+		"aud": DefaultTokenURL,
+		"iat": now.Unix(),
+		"exp": now.Add(jwtLifetime).Unix(),
+	})
+	if err != nil {
+		return "", err
+	}
+
+	enc := base64.RawURLEncoding
+	unsigned := enc.EncodeToString(header) + "." + enc.EncodeToString(claims)
+
+	digest := sha256.Sum256([]byte(unsigned))
+	sig, err := rsa.SignPSS(rand.Reader, sa.signer, crypto.SHA256, digest[:], &rsa.PSSOptions{SaltLength: rsa.PSSSaltLengthEqualsHash})
+	if err != nil {
+		return "", fmt.Errorf("ошибка подписи JWT: %w", err)
+	}
+
+	return unsigned + "." + enc.EncodeToString(sig), nil
+}
+
+// parsePrivateKey разбирает PEM из ключа. Yandex Cloud добавляет перед ним
+// строку-предупреждение, поэтому ищется первый PEM-блок.
+func parsePrivateKey(text string) (*rsa.PrivateKey, error) {
+	start := strings.Index(text, "-----BEGIN")
+	if start < 0 {
+		return nil, fmt.Errorf("в private_key нет PEM-блока")
+	}
+	block, _ := pem.Decode([]byte(text[start:]))
+	if block == nil {
+		return nil, fmt.Errorf("некорректный PEM в private_key")
+	}
+
+	if key, err := x509.ParsePKCS8PrivateKey(block.Bytes); err == nil {
+		rsaKey, ok := key.(*rsa.PrivateKey)
+		if !ok {
+			return nil, fmt.Errorf("ключ сервисного аккаунта должен быть RSA")
+		}
+		return rsaKey, nil
+	}
+
+	key, err := x509.ParsePKCS1PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка разбора private_key: %w", err)
+	}
+	return key, nil
+}
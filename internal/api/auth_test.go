@@ -0,0 +1,210 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/d1mk9/tgChanPost/internal/api/yandextest"
+)
+
+// newTestServiceAccount создает ключ в моке и аутентификацию через его IAM
+func newTestServiceAccount(t *testing.T) (*ServiceAccount, *yandextest.Server, string) {
+	t.Helper()
+
+	mock := yandextest.NewServer()
+	srv := mock.Start()
+	t.Cleanup(srv.Close)
+
+	data, err := mock.NewServiceAccountKey("sa-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "key.json")
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	key, err := LoadServiceAccountKey(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sa, err := NewServiceAccount(key, srv.URL+string(yandextest.IAM))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return sa, mock, srv.URL
+}
+
+// authorization вызывает Authorization и прерывает тест при ошибке
+func authorization(t *testing.T, sa *ServiceAccount) string {
+	t.Helper()
+	header, err := sa.Authorization(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return header
+}
+
+// expire сдвигает сроки токена так, будто время обновления наступило
+func expire(sa *ServiceAccount, refreshAt, expiresAt time.Time) {
+	sa.mu.Lock()
+	defer sa.mu.Unlock()
+	sa.refreshAt = refreshAt
+	sa.expiresAt = expiresAt
+	sa.retryAt = time.Time{}
+}
+
+func TestServiceAccountExchange(t *testing.T) {
+	sa, mock, baseURL := newTestServiceAccount(t)
+
+	// Мок проверяет подпись PS256, kid, iss, aud и срок жизни JWT
+	header := authorization(t, sa)
+	if header != "Bearer mock-iam-1" {
+		t.Fatalf("заголовок %q", header)
+	}
+
+	// Токен принимают эндпоинты моделей
+	client := NewClient(ClientOptions{Auth: sa, CatalogID: "catalog", BaseURL: baseURL})
+	if _, err := client.GenerateMessage(context.Background(), "стойкость"); err != nil {
+		t.Fatal(err)
+	}
+	if got := requestsTo(mock, yandextest.Completion)[0].Authorization; got != header {
+		t.Fatalf("запрос к модели с заголовком %q, ожидался %q", got, header)
+	}
+}
+
+func TestServiceAccountCachesToken(t *testing.T) {
+	sa, mock, _ := newTestServiceAccount(t)
+
+	first := authorization(t, sa)
+	for i := 0; i < 3; i++ {
+		if got := authorization(t, sa); got != first {
+			t.Fatalf("токен сменился без необходимости: %q → %q", first, got)
+		}
+	}
+	if n := mock.TokensIssued(); n != 1 {
+		t.Fatalf("выдано токенов %d, ожидался 1", n)
+	}
+}
+
+func TestServiceAccountRefreshSchedule(t *testing.T) {
+	tests := []struct {
+		ttl  time.Duration
+		want time.Duration
+	}{
+		{ttl: 10 * time.Minute, want: 9 * time.Minute}, // За десятую часть срока до истечения
+		{ttl: 12 * time.Hour, want: tokenRefreshMax},   // Не реже раза в час
+	}
+
+	for _, tt := range tests {
+		sa, mock, _ := newTestServiceAccount(t)
+		mock.SetTokenTTL(tt.ttl)
+
+		start := time.Now()
+		authorization(t, sa)
+
+		sa.mu.Lock()
+		refresh := sa.refreshAt.Sub(start)
+		sa.mu.Unlock()
+		if refresh < tt.want-time.Second || refresh > tt.want+time.Second {
+			t.Errorf("токен на %s обновляется через %s, ожидалось %s", tt.ttl, refresh, tt.want)
+		}
+	}
+}
+
+func TestServiceAccountRefreshesBeforeExpiry(t *testing.T) {
+	sa, mock, _ := newTestServiceAccount(t)
+
+	first := authorization(t, sa)
+	expire(sa, time.Now().Add(-time.Second), time.Now().Add(time.Minute))
+
+	second := authorization(t, sa)
+	if second == first {
+		t.Fatalf("токен не обновлен: %q", second)
+	}
+	if n := mock.TokensIssued(); n != 2 {
+		t.Fatalf("выдано токенов %d, ожидалось 2", n)
+	}
+}
+
+func TestServiceAccountFallsBackToStaleToken(t *testing.T) {
+	sa, mock, _ := newTestServiceAccount(t)
+
+	first := authorization(t, sa)
+	expire(sa, time.Now().Add(-time.Second), time.Now().Add(time.Minute))
+	mock.Fail(yandextest.IAM, yandextest.FaultHTTP)
+
+	if got := authorization(t, sa); got != first {
+		t.Fatalf("при сбое IAM ожидался прежний токен %q, получено %q", first, got)
+	}
+
+	// До паузы повтора IAM не опрашивается
+	before := len(requestsTo(mock, yandextest.IAM))
+	if got := authorization(t, sa); got != first {
+		t.Fatalf("ожидался прежний токен %q, получено %q", first, got)
+	}
+	if after := len(requestsTo(mock, yandextest.IAM)); after != before {
+		t.Fatalf("обмен повторен раньше паузы: запросов %d → %d", before, after)
+	}
+}
+
+func TestServiceAccountExpiredTokenFails(t *testing.T) {
+	sa, mock, _ := newTestServiceAccount(t)
+
+	authorization(t, sa)
+	expire(sa, time.Now().Add(-time.Minute), time.Now().Add(-time.Second))
+	mock.Fail(yandextest.IAM, yandextest.FaultHTTP)
+
+	_, err := sa.Authorization(context.Background())
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusTooManyRequests {
+		t.Fatalf("ожидалась ошибка обмена 429, получено %v", err)
+	}
+
+	// Та же ошибка возвращается до паузы повтора
+	if _, again := sa.Authorization(context.Background()); !errors.Is(again, err) {
+		t.Fatalf("до паузы повтора ожидалась прежняя ошибка, получено %v", again)
+	}
+}
+
+func TestServiceAccountUnknownKey(t *testing.T) {
+	sa, _, _ := newTestServiceAccount(t)
+
+	// Ключ, которого нет в другом моке, IAM отклоняет
+	other := yandextest.NewServer()
+	srv := other.Start()
+	defer srv.Close()
+	sa.tokenURL = srv.URL + string(yandextest.IAM)
+
+	_, err := sa.Authorization(context.Background())
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusUnauthorized {
+		t.Fatalf("ожидалась ошибка 401, получено %v", err)
+	}
+}
+
+func TestLoadServiceAccountKeyInvalid(t *testing.T) {
+	dir := t.TempDir()
+	tests := map[string]string{
+		"empty.json":  `{}`,
+		"broken.json": `{"id": `,
+	}
+	for name, body := range tests {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(body), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := LoadServiceAccountKey(path); err == nil {
+			t.Errorf("%s: ожидалась ошибка", name)
+		}
+	}
+
+	if _, err := NewServiceAccount(ServiceAccountKey{ID: "k", ServiceAccountID: "sa", PrivateKey: "not a key"}, ""); err == nil {
+		t.Error("ключ без PEM должен отклоняться")
+	}
+}
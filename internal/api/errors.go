@@ -0,0 +1,64 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// maxErrorBody сколько байт тела неразобранной ошибки попадает в текст
+const maxErrorBody = 512
+
+// APIError ошибка Yandex Cloud, разобранная из тела ответа
+type APIError struct {
+	StatusCode int    // HTTP-код ответа
+	GRPCCode   int    // Код gRPC, например 8 - превышена квота, 16 - неверный ключ
+	Message    string // Сообщение Yandex Cloud или начало тела, если его не удалось разобрать
+}
+
+func (e *APIError) Error() string {
+	if e.GRPCCode != 0 {
+		return fmt.Sprintf("API error: %d %s (grpc %d): %s", e.StatusCode, http.StatusText(e.StatusCode), e.GRPCCode, e.Message)
+	}
+	return fmt.Sprintf("API error: %d %s: %s", e.StatusCode, http.StatusText(e.StatusCode), e.Message)
+}
+
+// Temporary сообщает, имеет ли смысл повторить запрос позже
+func (e *APIError) Temporary() bool {
+	return e.StatusCode == http.StatusTooManyRequests || e.StatusCode >= http.StatusInternalServerError
+}
+
+// parseAPIError разбирает тело ошибки. Yandex Cloud отвечает
+// {"error": {"grpcCode", "httpCode", "message"}}, а шлюз gRPC - {"code", "message"}.
+func parseAPIError(status int, body []byte) *APIError {
+	e := &APIError{StatusCode: status}
+
+	var wrapped struct {
+		Error *struct {
+			GRPCCode int    `json:"grpcCode"`
+			Message  string `json:"message"`
+		} `json:"error"`
+		Code    int    `json:"code"`
+		Message string `json:"message"`
+	}
+	if err := json.Unmarshal(body, &wrapped); err == nil {
+		switch {
+		case wrapped.Error != nil:
+			e.GRPCCode = wrapped.Error.GRPCCode
+			e.Message = wrapped.Error.Message
+		case wrapped.Message != "":
+			e.GRPCCode = wrapped.Code
+			e.Message = wrapped.Message
+		}
+	}
+
+	if e.Message == "" {
+		text := strings.TrimSpace(string(body))
+		if len(text) > maxErrorBody {
+			text = text[:maxErrorBody] + "…"
+		}
+		e.Message = text
+	}
+	return e
+}
@@ -0,0 +1,125 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestParseAPIError(t *testing.T) {
+	long := strings.Repeat("x", maxErrorBody+100)
+
+	tests := []struct {
+		name     string
+		status   int
+		body     string
+		grpcCode int
+		message  string
+	}{
+		{
+			name:     "Yandex Cloud",
+			status:   http.StatusTooManyRequests,
+			body:     `{"error": {"grpcCode": 8, "httpCode": 429, "message": "quota limit exceed", "httpStatus": "Too Many Requests", "details": []}}`,
+			grpcCode: 8,
+			message:  "quota limit exceed",
+		},
+		{
+			name:     "шлюз gRPC",
+			status:   http.StatusUnauthorized,
+			body:     `{"code": 16, "message": "Unknown api key", "details": []}`,
+			grpcCode: 16,
+			message:  "Unknown api key",
+		},
+		{
+			name:    "не JSON",
+			status:  http.StatusBadGateway,
+			body:    "  <html>Bad Gateway</html>\n",
+			message: "<html>Bad Gateway</html>",
+		},
+		{
+			name:    "JSON без сообщения",
+			status:  http.StatusInternalServerError,
+			body:    `{"status": "failed"}`,
+			message: `{"status": "failed"}`,
+		},
+		{
+			name:   "пустое тело",
+			status: http.StatusServiceUnavailable,
+		},
+		{
+			name:    "длинное тело обрезается",
+			status:  http.StatusBadRequest,
+			body:    long,
+			message: long[:maxErrorBody] + "…",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := parseAPIError(tt.status, []byte(tt.body))
+			if err.StatusCode != tt.status || err.GRPCCode != tt.grpcCode || err.Message != tt.message {
+				t.Fatalf("получено {%d %d %q}, ожидалось {%d %d %q}",
+					err.StatusCode, err.GRPCCode, err.Message, tt.status, tt.grpcCode, tt.message)
+			}
+		})
+	}
+}
+
+func TestAPIErrorText(t *testing.T) {
+	tests := []struct {
+		err       *APIError
+		text      string
+		temporary bool
+	}{
+		{&APIError{StatusCode: 429, GRPCCode: 8, Message: "quota"}, "API error: 429 Too Many Requests (grpc 8): quota", true},
+		{&APIError{StatusCode: 401, Message: "bad key"}, "API error: 401 Unauthorized: bad key", false},
+		{&APIError{StatusCode: 503, Message: ""}, "API error: 503 Service Unavailable: ", true},
+	}
+
+	for _, tt := range tests {
+		if got := tt.err.Error(); got != tt.text {
+			t.Errorf("Error() = %q, ожидалось %q", got, tt.text)
+		}
+		if got := tt.err.Temporary(); got != tt.temporary {
+			t.Errorf("%q: Temporary() = %v, ожидалось %v", tt.text, got, tt.temporary)
+		}
+	}
+}
+
+func TestInt64(t *testing.T) {
+	tests := []struct {
+		json    string
+		want    Int64
+		wantErr bool
+	}{
+		{json: `"42"`, want: 42},
+		{json: `42`, want: 42},
+		{json: `"-7"`, want: -7},
+		{json: `"9223372036854775807"`, want: 9223372036854775807},
+		{json: `null`, want: 0},
+		{json: `""`, want: 0},
+		{json: `"12abc"`, wantErr: true},
+		{json: `1.5`, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		n := Int64(100)
+		err := json.Unmarshal([]byte(tt.json), &n)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s: ошибка %v, ожидалась ошибка: %v", tt.json, err, tt.wantErr)
+			continue
+		}
+		if !tt.wantErr && n != tt.want {
+			t.Errorf("%s: получено %d, ожидалось %d", tt.json, n, tt.want)
+		}
+	}
+
+	data, err := json.Marshal(Usage{InputTextTokens: 12, CompletionTokens: 3, TotalTokens: 15})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := `{"inputTextTokens":"12","completionTokens":"3","totalTokens":"15"}`; string(data) != want {
+		t.Fatalf("кодирование %s, ожидалось %s", data, want)
+	}
+}
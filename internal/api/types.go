@@ -0,0 +1,142 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"strconv"
+	"time"
+)
+
+// Message сообщение диалога с моделью
+type Message struct {
+	Role string `json:"role"` // system, user или assistant
+	Text string `json:"text"`
+}
+
+// CompletionOptions параметры генерации текста
+type CompletionOptions struct {
+	Stream      bool    `json:"stream"`
+	Temperature float64 `json:"temperature"`
+	MaxTokens   Int64   `json:"maxTokens"`
+}
+
+// CompletionRequest запрос к YandexGPT
+type CompletionRequest struct {
+	ModelURI          string            `json:"modelUri"`
+	CompletionOptions CompletionOptions `json:"completionOptions"`
+	Messages          []Message         `json:"messages"`
+}
+
+// Alternative вариант ответа модели
+type Alternative struct {
+	Message Message `json:"message"`
+	Status  string  `json:"status"` // Например ALTERNATIVE_STATUS_FINAL
+}
+
+// Usage расход токенов на запрос
+type Usage struct {
+	InputTextTokens  Int64 `json:"inputTextTokens"`
+	CompletionTokens Int64 `json:"completionTokens"`
+	TotalTokens      Int64 `json:"totalTokens"`
+}
+
+// CompletionResult результат генерации текста
+type CompletionResult struct {
+	Alternatives []Alternative `json:"alternatives"`
+	Usage        Usage         `json:"usage"`
+	ModelVersion string        `json:"modelVersion"`
+}
+
+// CompletionResponse ответ YandexGPT
+type CompletionResponse struct {
+	Result CompletionResult `json:"result"`
+}
+
+// CompletionChunk часть потокового ответа YandexGPT.
+// При сбое посреди генерации вместо result приходит error.
+type CompletionChunk struct {
+	Result CompletionResult `json:"result"`
+	Error  *StreamError     `json:"error,omitempty"`
+}
+
+// StreamError ошибка внутри потокового ответа
+type StreamError struct {
+	GRPCCode int    `json:"grpcCode"`
+	HTTPCode int    `json:"httpCode"`
+	Message  string `json:"message"`
+}
+
+// AspectRatio соотношение сторон изображения
+type AspectRatio struct {
+	WidthRatio  Int64 `json:"widthRatio"`
+	HeightRatio Int64 `json:"heightRatio"`
+}
+
+// GenerationOptions параметры генерации изображения
+type GenerationOptions struct {
+	Seed        Int64       `json:"seed"`
+	AspectRatio AspectRatio `json:"aspectRatio"`
+}
+
+// ArtMessage часть описания изображения с весом
+type ArtMessage struct {
+	Weight string `json:"weight"`
+	Text   string `json:"text"`
+}
+
+// ArtRequest запрос к YandexART
+type ArtRequest struct {
+	ModelURI          string            `json:"modelUri"`
+	GenerationOptions GenerationOptions `json:"generationOptions"`
+	Messages          []ArtMessage      `json:"messages"`
+}
+
+// ImageResponse результат завершенной операции генерации изображения
+type ImageResponse struct {
+	Image        []byte `json:"image"` // JPEG, в JSON передается в base64
+	ModelVersion string `json:"modelVersion"`
+}
+
+// OperationError ошибка, с которой завершилась операция
+type OperationError struct {
+	Code    int    `json:"code"` // Код gRPC
+	Message string `json:"message"`
+}
+
+func (e *OperationError) Error() string {
+	return "operation failed: " + e.Message + " (code " + strconv.Itoa(e.Code) + ")"
+}
+
+// Operation асинхронная операция Yandex Cloud
+type Operation struct {
+	ID          string          `json:"id"`
+	Description string          `json:"description"`
+	CreatedAt   time.Time       `json:"createdAt"`
+	CreatedBy   string          `json:"createdBy"`
+	ModifiedAt  time.Time       `json:"modifiedAt"`
+	Done        bool            `json:"done"`
+	Error       *OperationError `json:"error,omitempty"`
+	Response    *ImageResponse  `json:"response,omitempty"`
+}
+
+// Int64 целое, которое Yandex Cloud передает строкой (int64 в proto3 JSON).
+// При разборе принимается и строка, и число.
+type Int64 int64
+
+func (n Int64) MarshalJSON() ([]byte, error) {
+	return json.Marshal(strconv.FormatInt(int64(n), 10))
+}
+
+func (n *Int64) UnmarshalJSON(data []byte) error {
+	data = bytes.Trim(data, `"`)
+	if len(data) == 0 || string(data) == "null" {
+		*n = 0
+		return nil
+	}
+	v, err := strconv.ParseInt(string(data), 10, 64)
+	if err != nil {
+		return err
+	}
+	*n = Int64(v)
+	return nil
+}
@@ -2,215 +2,291 @@ package api
 
 import (
 	"bytes"
-	"encoding/base64"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
-	"log"
+	"log/slog"
 	"net/http"
-	"os"
 	"strconv"
+	"strings"
 	"time"
 
+	"github.com/d1mk9/tgChanPost/internal/metrics"
 	"github.com/d1mk9/tgChanPost/internal/models"
 )
 
+// DefaultBaseURL адрес Yandex Foundation Models
+const DefaultBaseURL = "https://llm.api.cloud.yandex.net"
+
+// Пути API относительно базового адреса
 const (
-	yandexAPIURL          = "https://llm.api.cloud.yandex.net/foundationModels/v1/completion"
-	yandexArtAPIURL       = "https://llm.api.cloud.yandex.net/foundationModels/v1/imageGenerationAsync"
-	yandexArtOperationURL = "https://llm.api.cloud.yandex.net/operations/"
+	completionPath   = "/foundationModels/v1/completion"
+	artPath          = "/foundationModels/v1/imageGenerationAsync"
+	operationsPath   = "/operations/"
+	defaultPollDelay = 10 * time.Second
 )
 
-// GenerateMessage генерирует сообщение с использованием YandexGPT
-func GenerateMessage(apiKey, catalogID, userMessage string) (models.FormattedResponse, error) {
-	requestBody, err := json.Marshal(map[string]interface{}{
-		"modelUri": fmt.Sprintf("gpt://%s/yandexgpt/latest", catalogID),
-		"completionOptions": map[string]interface{}{
-			"stream":      false,
-			"temperature": 0.6,
-			"maxTokens":   2000,
-		},
-		"messages": []map[string]string{
-			{"role": "system", "text": "Ты умный ассистент"},
-			{"role": "user", "text": userMessage},
-		},
-	})
-	if err != nil {
-		return models.FormattedResponse{}, err
+// ClientOptions параметры клиента Yandex Foundation Models
+type ClientOptions struct {
+	Auth          Authenticator // Аутентификация YandexGPT
+	ArtAuth       Authenticator // Аутентификация YandexART, nil - как для YandexGPT
+	CatalogID     string        // Каталог Yandex Cloud
+	BaseURL       string        // Пусто - DefaultBaseURL; для локального мока, например http://localhost:8090
+	OperationsURL string        // Адрес API операций, пусто - BaseURL
+	PollInterval  time.Duration // Интервал опроса операции генерации, 0 - 10 секунд
+}
+
+// Client клиент Yandex Foundation Models: YandexGPT и YandexART
+type Client struct {
+	auth          Authenticator
+	artAuth       Authenticator
+	catalogID     string
+	baseURL       string
+	operationsURL string
+	pollInterval  time.Duration
+	llmHTTP       *http.Client
+	streamHTTP    *http.Client // Таймаут больше: ответ приходит частями по мере генерации
+	artHTTP       *http.Client
+}
+
+// NewClient создает клиент с ключами API и каталогом Yandex Cloud
+func NewClient(opts ClientOptions) *Client {
+	c := &Client{
+		auth:          opts.Auth,
+		artAuth:       opts.ArtAuth,
+		catalogID:     opts.CatalogID,
+		baseURL:       strings.TrimSuffix(opts.BaseURL, "/"),
+		operationsURL: strings.TrimSuffix(opts.OperationsURL, "/"),
+		pollInterval:  opts.PollInterval,
+		llmHTTP:       &http.Client{Timeout: 10 * time.Second},
+		streamHTTP:    &http.Client{Timeout: 60 * time.Second},
+		artHTTP:       &http.Client{Timeout: 40 * time.Second},
+	}
+	if c.artAuth == nil {
+		c.artAuth = c.auth
 	}
+	if c.baseURL == "" {
+		c.baseURL = DefaultBaseURL
+	}
+	if c.operationsURL == "" {
+		c.operationsURL = c.baseURL
+	}
+	if c.pollInterval <= 0 {
+		c.pollInterval = defaultPollDelay
+	}
+	return c
+}
 
-	req, err := http.NewRequest("POST", yandexAPIURL, bytes.NewBuffer(requestBody))
-	if err != nil {
+// GenerateMessage генерирует сообщение с использованием YandexGPT
+func (c *Client) GenerateMessage(ctx context.Context, userMessage string) (models.FormattedResponse, error) {
+	start := time.Now()
+	defer metrics.ObserveProvider(metrics.ProviderLLM, start)
+
+	var response CompletionResponse
+	if err := c.do(ctx, metrics.ProviderLLM, c.llmHTTP, http.MethodPost, c.baseURL+completionPath, c.auth, c.completionRequest(userMessage, false), &response); err != nil {
 		return models.FormattedResponse{}, err
 	}
 
-	req.Header.Set("Authorization", "Api-Key "+apiKey)
-	req.Header.Set("Content-Type", "application/json")
+	return formatCompletion(response.Result)
+}
 
-	client := &http.Client{Timeout: 10 * time.Second}
-	resp, err := client.Do(req)
+// GenerateMessageStream генерирует сообщение в потоковом режиме. onText вызывается
+// на каждую часть ответа с накопленным к этому моменту текстом.
+func (c *Client) GenerateMessageStream(ctx context.Context, userMessage string, onText func(text string)) (models.FormattedResponse, error) {
+	start := time.Now()
+	defer metrics.ObserveProvider(metrics.ProviderLLM, start)
+
+	resp, err := c.send(ctx, metrics.ProviderLLM, c.streamHTTP, http.MethodPost, c.baseURL+completionPath, c.auth, c.completionRequest(userMessage, true))
 	if err != nil {
 		return models.FormattedResponse{}, err
 	}
 	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
-		bodyBytes, _ := io.ReadAll(resp.Body)
-		return models.FormattedResponse{}, fmt.Errorf("API error: %d %s, response: %s", resp.StatusCode, http.StatusText(resp.StatusCode), string(bodyBytes))
+	// Ответ - последовательность JSON-объектов, каждый с полным текстом на текущий момент
+	var result CompletionResult
+	dec := json.NewDecoder(resp.Body)
+	for {
+		var chunk CompletionChunk
+		if err := dec.Decode(&chunk); err == io.EOF {
+			break
+		} else if err != nil {
+			providerError(metrics.ProviderLLM, "decode")
+			return models.FormattedResponse{}, fmt.Errorf("decode stream: %w", err)
+		}
+
+		if chunk.Error != nil {
+			providerError(metrics.ProviderLLM, "stream")
+			return models.FormattedResponse{}, &APIError{StatusCode: chunk.Error.HTTPCode, GRPCCode: chunk.Error.GRPCCode, Message: chunk.Error.Message}
+		}
+
+		result = chunk.Result
+		if len(result.Alternatives) > 0 && onText != nil {
+			onText(result.Alternatives[0].Message.Text)
+		}
 	}
 
-	var response map[string]interface{}
-	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
-		return models.FormattedResponse{}, err
+	return formatCompletion(result)
+}
+
+// completionRequest собирает запрос к YandexGPT
+func (c *Client) completionRequest(userMessage string, stream bool) CompletionRequest {
+	return CompletionRequest{
+		ModelURI: fmt.Sprintf("gpt://%s/yandexgpt/latest", c.catalogID),
+		CompletionOptions: CompletionOptions{
+			Stream:      stream,
+			Temperature: 0.6,
+			MaxTokens:   2000,
+		},
+		Messages: []Message{
+			{Role: "system", Text: "Ты умный ассистент"},
+			{Role: "user", Text: userMessage},
+		},
 	}
+}
 
-	if result, ok := response["result"].(map[string]interface{}); ok {
-		if alternatives, ok := result["alternatives"].([]interface{}); ok && len(alternatives) > 0 {
-			if message, ok := alternatives[0].(map[string]interface{})["message"].(map[string]interface{}); ok {
-				if text, ok := message["text"].(string); ok {
-					return models.FormattedResponse{
-						Response: text,
-						Status:   "success",
-					}, nil
-				}
-			}
-		}
+// formatCompletion проверяет результат генерации и переводит его в ответ для бота
+func formatCompletion(result CompletionResult) (models.FormattedResponse, error) {
+	if len(result.Alternatives) == 0 || result.Alternatives[0].Message.Text == "" {
+		providerError(metrics.ProviderLLM, "empty")
+		return models.FormattedResponse{}, fmt.Errorf("empty completion: no alternatives in response")
 	}
 
 	return models.FormattedResponse{
-		Response: "Не удалось извлечь текст из ответа",
-		Status:   "error",
+		Response: result.Alternatives[0].Message.Text,
+		Status:   "success",
+		Usage: models.Usage{
+			InputTokens:      int64(result.Usage.InputTextTokens),
+			CompletionTokens: int64(result.Usage.CompletionTokens),
+			TotalTokens:      int64(result.Usage.TotalTokens),
+		},
+		ModelVersion: result.ModelVersion,
 	}, nil
 }
 
 // GenerateArtImage генерирует изображение с использованием Yandex Art API
-func GenerateArtImage(apiKey, catalogID, prompt string, seed int64, wArt, hArt int) (string, error) {
-	// Подготовка запроса
-	requestBody := map[string]interface{}{
-		"modelUri": fmt.Sprintf("art://%s/yandex-art/latest", catalogID),
-		"generationOptions": map[string]interface{}{
-			"seed": seed,
-			"aspectRatio": map[string]string{
-				"widthRatio":  strconv.Itoa(wArt),
-				"heightRatio": strconv.Itoa(hArt),
-			},
+// и возвращает его в виде байтов JPEG
+func (c *Client) GenerateArtImage(ctx context.Context, prompt string, seed int64, wArt, hArt int) ([]byte, error) {
+	start := time.Now()
+	defer metrics.ObserveProvider(metrics.ProviderArt, start)
+
+	request := ArtRequest{
+		ModelURI: fmt.Sprintf("art://%s/yandex-art/latest", c.catalogID),
+		GenerationOptions: GenerationOptions{
+			Seed:        Int64(seed),
+			AspectRatio: AspectRatio{WidthRatio: Int64(wArt), HeightRatio: Int64(hArt)},
 		},
-		"messages": []map[string]interface{}{
-			{
-				"weight": "1",
-				"text":   "профессиональное фото, 4k, высокое разрешение, высокая детализация" + prompt,
-			},
+		Messages: []ArtMessage{
+			{Weight: "1", Text: "профессиональное фото, 4k, высокое разрешение, высокая детализация" + prompt},
 		},
 	}
 
-	// Преобразование тела запроса в JSON
-	body, err := json.Marshal(requestBody)
-	if err != nil {
-		return "", err
+	// Отправка запроса на создание изображения
+	var op Operation
+	if err := c.do(ctx, metrics.ProviderArt, c.artHTTP, http.MethodPost, c.baseURL+artPath, c.artAuth, request, &op); err != nil {
+		return nil, err
 	}
-
-	// Создание нового запроса
-	req, err := http.NewRequest("POST", yandexArtAPIURL, bytes.NewBuffer(body))
-	if err != nil {
-		return "", err
+	if op.ID == "" {
+		providerError(metrics.ProviderArt, "empty")
+		return nil, fmt.Errorf("operation id not found in response")
 	}
+	slog.InfoContext(ctx, "Art operation created", "operation_id", op.ID)
 
-	// Установка заголовков
-	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("Authorization", "Api-Key "+apiKey)
+	// Ожидание завершения генерации
+	for !op.Done {
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(c.pollInterval):
+		}
 
-	// Отправка запроса на создание изображения
-	client := &http.Client{Timeout: 40 * time.Second}
-	resp, err := client.Do(req)
-	if err != nil {
-		return "", err
+		slog.DebugContext(ctx, "Checking art operation status", "operation_id", op.ID)
+		id := op.ID
+		op = Operation{}
+		if err := c.do(ctx, metrics.ProviderArt, c.artHTTP, http.MethodGet, c.operationsURL+operationsPath+id, c.artAuth, nil, &op); err != nil {
+			return nil, err
+		}
+		if op.ID == "" {
+			op.ID = id
+		}
 	}
-	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
-		bodyBytes, err := io.ReadAll(resp.Body)
-		if err != nil {
-			return "", err
-		}
-		return "", fmt.Errorf("API error: %d %s, response: %s", resp.StatusCode, http.StatusText(resp.StatusCode), string(bodyBytes))
+	if op.Error != nil {
+		providerError(metrics.ProviderArt, "operation_failed")
+		return nil, op.Error
+	}
+	if op.Response == nil || len(op.Response.Image) == 0 {
+		providerError(metrics.ProviderArt, "missing_image")
+		return nil, fmt.Errorf("operation %s finished without image", op.ID)
 	}
 
-	var createResponse map[string]interface{}
-	if err := json.NewDecoder(resp.Body).Decode(&createResponse); err != nil {
-		return "", err
+	slog.InfoContext(ctx, "Image received",
+		"operation_id", op.ID,
+		"bytes", len(op.Response.Image),
+		"model_version", op.Response.ModelVersion,
+	)
+	return op.Response.Image, nil
+}
+
+// do отправляет запрос с JSON-телом (если in не nil) и разбирает ответ в out.
+// Ответы с кодом, отличным от 200, возвращаются как *APIError.
+func (c *Client) do(ctx context.Context, provider string, client *http.Client, method, url string, auth Authenticator, in, out any) error {
+	resp, err := c.send(ctx, provider, client, method, url, auth, in)
+	if err != nil {
+		return err
 	}
+	defer resp.Body.Close()
 
-	var operationID string
-	if id, exists := createResponse["id"]; exists {
-		operationID = fmt.Sprintf("%v", id)
-		log.Printf("Operation ID: %s", operationID)
-	} else {
-		return "", fmt.Errorf("ID field not found in response: %v", createResponse)
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		providerError(provider, "decode")
+		return fmt.Errorf("decode response: %w", err)
 	}
+	return nil
+}
 
-	// Ожидание завершения генерации
-	for {
-		time.Sleep(10 * time.Second)
+// send отправляет запрос и возвращает ответ с кодом 200; тело закрывает вызывающий.
+// Остальные ответы возвращаются как *APIError.
+func (c *Client) send(ctx context.Context, provider string, client *http.Client, method, url string, auth Authenticator, in any) (*http.Response, error) {
+	authorization, err := auth.Authorization(ctx)
+	if err != nil {
+		providerError(provider, "auth")
+		return nil, err
+	}
 
-		log.Printf("Checking status for operation ID: %s", operationID)
-		doneResp, err := http.NewRequest("GET", fmt.Sprintf("https://llm.api.cloud.yandex.net:443/operations/%s", operationID), nil)
-		if err != nil {
-			return "", err
-		}
-		doneResp.Header.Set("Authorization", "Api-Key "+apiKey) // Установка заголовка авторизации
-		resp, err := client.Do(doneResp)                        // Отправка запроса
+	var body io.Reader
+	if in != nil {
+		data, err := json.Marshal(in)
 		if err != nil {
-			return "", err
-		}
-		defer resp.Body.Close()
-
-		if resp.StatusCode != http.StatusOK {
-			bodyBytes, err := io.ReadAll(resp.Body)
-			if err != nil {
-				return "", err
-			}
-			return "", fmt.Errorf("API error: %d %s, response: %s", resp.StatusCode, http.StatusText(resp.StatusCode), string(bodyBytes))
+			return nil, err
 		}
+		body = bytes.NewReader(data)
+	}
 
-		var doneResponse map[string]interface{}
-		if err := json.NewDecoder(resp.Body).Decode(&doneResponse); err != nil {
-			return "", err
-		}
+	req, err := http.NewRequestWithContext(ctx, method, url, body)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", authorization)
+	if in != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
 
-		done, exists := doneResponse["done"].(bool)
-		if !exists {
-			return "", fmt.Errorf("missing 'done' field in response: %v", doneResponse)
-		}
+	resp, err := client.Do(req)
+	if err != nil {
+		providerError(provider, "network")
+		return nil, err
+	}
 
-		if done {
-			if errMsg, exists := doneResponse["error"]; exists {
-				return "", fmt.Errorf("operation failed: %v", errMsg)
-			}
-
-			imageData, ok := doneResponse["response"].(map[string]interface{})["image"].(string)
-			if !ok {
-				return "", fmt.Errorf("failed to get image data from response: %v", doneResponse)
-			}
-
-			imageBytes, err := base64.StdEncoding.DecodeString(imageData)
-			if err != nil {
-				return "", err
-			}
-
-			dataFile := time.Now().Format("2006-01-02_15-04-05")
-			imageFileName := "/mount/dir/" + dataFile + ".jpeg"
-			imageFile, err := os.Create(imageFileName)
-			if err != nil {
-				return "", err
-			}
-			defer imageFile.Close()
-
-			if _, err := imageFile.Write(imageBytes); err != nil {
-				return "", err
-			}
-
-			log.Printf("Image saved to %s", imageFileName)
-			return imageFileName, nil
-		}
+	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+		providerError(provider, strconv.Itoa(resp.StatusCode))
+		data, _ := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+		return nil, parseAPIError(resp.StatusCode, data)
 	}
+	return resp, nil
+}
+
+// providerError учитывает ошибку вызова провайдера в метриках
+func providerError(provider, code string) {
+	metrics.ProviderErrors.WithLabelValues(provider, code).Inc()
 }
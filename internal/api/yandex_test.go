@@ -0,0 +1,239 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/d1mk9/tgChanPost/internal/api/yandextest"
+)
+
+// newTestClient запускает мок и создает клиент, который ходит в него
+func newTestClient(t *testing.T) (*Client, *yandextest.Server) {
+	t.Helper()
+
+	mock := yandextest.NewServer()
+	srv := mock.Start()
+	t.Cleanup(srv.Close)
+
+	client := NewClient(ClientOptions{
+		Auth:         APIKey("text-key"),
+		ArtAuth:      APIKey("art-key"),
+		CatalogID:    "catalog",
+		BaseURL:      srv.URL + "/",
+		PollInterval: 10 * time.Millisecond,
+	})
+	return client, mock
+}
+
+// requestsTo возвращает запросы мока к эндпоинту
+func requestsTo(mock *yandextest.Server, endpoint yandextest.Endpoint) []yandextest.Request {
+	var out []yandextest.Request
+	for _, r := range mock.Requests() {
+		if r.Endpoint == endpoint {
+			out = append(out, r)
+		}
+	}
+	return out
+}
+
+func TestGenerateMessage(t *testing.T) {
+	client, mock := newTestClient(t)
+	mock.SetCompletion("«Дорогу осилит идущий» — Сенека")
+
+	resp, err := client.GenerateMessage(context.Background(), "стойкость")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Response != "«Дорогу осилит идущий» — Сенека" {
+		t.Fatalf("ответ %q", resp.Response)
+	}
+	// Мок передает расход строками, как настоящий API
+	if resp.Usage.TotalTokens == 0 || resp.Usage.TotalTokens != resp.Usage.InputTokens+resp.Usage.CompletionTokens {
+		t.Fatalf("расход разобран неверно: %+v", resp.Usage)
+	}
+
+	reqs := requestsTo(mock, yandextest.Completion)
+	if len(reqs) != 1 {
+		t.Fatalf("запросов к completion %d, ожидался 1", len(reqs))
+	}
+	if reqs[0].Authorization != "Api-Key text-key" {
+		t.Fatalf("заголовок Authorization %q", reqs[0].Authorization)
+	}
+	if !strings.Contains(string(reqs[0].Body), `"maxTokens":"2000"`) {
+		t.Fatalf("maxTokens должен передаваться строкой: %s", reqs[0].Body)
+	}
+}
+
+func TestGenerateMessageStream(t *testing.T) {
+	client, mock := newTestClient(t)
+	mock.SetCompletion("«Рукописи не горят» — Михаил Булгаков")
+	mock.SetStream(3, 0)
+
+	var parts []string
+	resp, err := client.GenerateMessageStream(context.Background(), "книги", func(text string) {
+		parts = append(parts, text)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(parts) != 3 || parts[2] != resp.Response {
+		t.Fatalf("части ответа %q, итог %q", parts, resp.Response)
+	}
+}
+
+func TestGenerateArtImagePolling(t *testing.T) {
+	client, mock := newTestClient(t)
+	image := []byte("jpeg")
+	mock.SetImage(image)
+	mock.SetPendingPolls(2)
+
+	got, err := client.GenerateArtImage(context.Background(), "море", 1, 1, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, image) {
+		t.Fatalf("изображение %q, ожидалось %q", got, image)
+	}
+
+	polls := requestsTo(mock, yandextest.Operations)
+	if len(polls) != 3 {
+		t.Fatalf("опросов операции %d, ожидалось 3", len(polls))
+	}
+	for _, r := range append(requestsTo(mock, yandextest.Art), polls...) {
+		if r.Authorization != "Api-Key art-key" {
+			t.Fatalf("YandexART должен использовать свой ключ, получено %q", r.Authorization)
+		}
+	}
+}
+
+func TestClientFaults(t *testing.T) {
+	tests := []struct {
+		name     string
+		endpoint yandextest.Endpoint
+		fault    yandextest.Fault
+		check    func(t *testing.T, err error)
+	}{
+		{
+			name:     "квота",
+			endpoint: yandextest.Completion,
+			fault:    yandextest.FaultHTTP,
+			check: func(t *testing.T, err error) {
+				var apiErr *APIError
+				if !errors.As(err, &apiErr) {
+					t.Fatalf("ожидался *APIError, получено %T: %v", err, err)
+				}
+				if apiErr.StatusCode != http.StatusTooManyRequests || apiErr.GRPCCode != 8 || !apiErr.Temporary() {
+					t.Fatalf("ошибка разобрана неверно: %+v", apiErr)
+				}
+			},
+		},
+		{
+			name:     "неверный ключ",
+			endpoint: yandextest.Art,
+			fault:    yandextest.FaultUnauthorized,
+			check: func(t *testing.T, err error) {
+				var apiErr *APIError
+				if !errors.As(err, &apiErr) {
+					t.Fatalf("ожидался *APIError, получено %T: %v", err, err)
+				}
+				if apiErr.StatusCode != http.StatusUnauthorized || apiErr.GRPCCode != 16 || apiErr.Temporary() {
+					t.Fatalf("ошибка разобрана неверно: %+v", apiErr)
+				}
+			},
+		},
+		{
+			name:     "некорректное тело",
+			endpoint: yandextest.Completion,
+			fault:    yandextest.FaultMalformed,
+			check: func(t *testing.T, err error) {
+				var apiErr *APIError
+				if errors.As(err, &apiErr) || !strings.Contains(err.Error(), "decode response") {
+					t.Fatalf("ожидалась ошибка разбора, получено %T: %v", err, err)
+				}
+			},
+		},
+		{
+			name:     "пустой ответ модели",
+			endpoint: yandextest.Completion,
+			fault:    yandextest.FaultEmpty,
+			check: func(t *testing.T, err error) {
+				if !strings.Contains(err.Error(), "empty completion") {
+					t.Fatalf("ожидалась ошибка пустого ответа, получено %v", err)
+				}
+			},
+		},
+		{
+			name:     "ошибка операции",
+			endpoint: yandextest.Operations,
+			fault:    yandextest.FaultOperationError,
+			check: func(t *testing.T, err error) {
+				var opErr *OperationError
+				if !errors.As(err, &opErr) || opErr.Code != 3 {
+					t.Fatalf("ожидался *OperationError с кодом 3, получено %T: %v", err, err)
+				}
+			},
+		},
+		{
+			name:     "нет изображения",
+			endpoint: yandextest.Operations,
+			fault:    yandextest.FaultMissingImage,
+			check: func(t *testing.T, err error) {
+				if !strings.Contains(err.Error(), "finished without image") {
+					t.Fatalf("ожидалась ошибка отсутствия изображения, получено %v", err)
+				}
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client, mock := newTestClient(t)
+			mock.SetPendingPolls(1)
+			mock.Fail(tt.endpoint, tt.fault)
+
+			var err error
+			if tt.endpoint == yandextest.Completion {
+				_, err = client.GenerateMessage(context.Background(), "стойкость")
+			} else {
+				_, err = client.GenerateArtImage(context.Background(), "море", 1, 1, 1)
+			}
+			if err == nil {
+				t.Fatal("ожидалась ошибка")
+			}
+			tt.check(t, err)
+		})
+	}
+}
+
+func TestGenerateMessageStreamInterrupted(t *testing.T) {
+	client, mock := newTestClient(t)
+	mock.Fail(yandextest.Completion, yandextest.FaultStreamInterrupted)
+
+	var parts int
+	_, err := client.GenerateMessageStream(context.Background(), "книги", func(string) { parts++ })
+
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusInternalServerError || apiErr.GRPCCode != 13 {
+		t.Fatalf("ожидался *APIError 500, получено %T: %v", err, err)
+	}
+	if parts != 1 {
+		t.Fatalf("до обрыва получено частей %d, ожидалась 1", parts)
+	}
+}
+
+func TestGenerateArtImageCanceled(t *testing.T) {
+	client, mock := newTestClient(t)
+	mock.SetPendingPolls(1000)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	if _, err := client.GenerateArtImage(ctx, "море", 1, 1, 1); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("ожидалась отмена по контексту, получено %v", err)
+	}
+}
@@ -0,0 +1,185 @@
+package yandextest
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// IAM эндпоинт обмена JWT на IAM-токен
+const IAM Endpoint = "/iam/v1/tokens"
+
+// tokenAudience aud, который IAM ожидает в JWT
+const tokenAudience = "https://iam.api.cloud.yandex.net/iam/v1/tokens"
+
+// defaultTokenTTL срок жизни токенов мока, как у настоящего IAM
+const defaultTokenTTL = 12 * time.Hour
+
+// NewServiceAccountKey создает авторизованный ключ сервисного аккаунта
+// в формате yc iam key create и регистрирует его открытую часть в моке
+func (s *Server) NewServiceAccountKey(serviceAccountID string) ([]byte, error) {
+	priv, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		return nil, err
+	}
+	der, err := x509.MarshalPKCS8PrivateKey(priv)
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	s.nextKey++
+	keyID := fmt.Sprintf("mock-key-%d", s.nextKey)
+	s.keys[keyID] = trustedKey{serviceAccountID: serviceAccountID, public: &priv.PublicKey}
+	s.mu.Unlock()
+
+	pemKey := pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})
+	return json.MarshalIndent(map[string]string{
+		"id":                 keyID,
+		"service_account_id": serviceAccountID,
+		"created_at":         time.Now().UTC().Format(time.RFC3339),
+		"key_algorithm":      "RSA_2048",
+		"private_key":        "PLEASE DO NOT REMOVE THIS LINE! Yandex.Cloud SA Key ID <" + keyID + ">\n" + string(pemKey),
+	}, "", "  ")
+}
+
+// SetTokenTTL задает срок жизни выдаваемых IAM-токенов
+func (s *Server) SetTokenTTL(ttl time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.tokenTTL = ttl
+}
+
+// TokensIssued возвращает количество выданных IAM-токенов
+func (s *Server) TokensIssued() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.tokens)
+}
+
+type trustedKey struct {
+	serviceAccountID string
+	public           *rsa.PublicKey
+}
+
+// handleIAM проверяет подпись и поля JWT и выдает токен
+func (s *Server) handleIAM(w http.ResponseWriter, body []byte) {
+	var req struct {
+		JWT string `json:"jwt"`
+	}
+	if err := json.Unmarshal(body, &req); err != nil || req.JWT == "" {
+		writeError(w, http.StatusBadRequest, 3, "jwt is required")
+		return
+	}
+
+	if err := s.verifyJWT(req.JWT); err != nil {
+		writeError(w, http.StatusUnauthorized, 16, err.Error())
+		return
+	}
+
+	s.mu.Lock()
+	ttl := s.tokenTTL
+	if ttl <= 0 {
+		ttl = defaultTokenTTL
+	}
+	token := fmt.Sprintf("mock-iam-%d", len(s.tokens)+1)
+	expiresAt := time.Now().Add(ttl)
+	s.tokens[token] = expiresAt
+	s.mu.Unlock()
+
+	writeJSON(w, map[string]any{"iamToken": token, "expiresAt": expiresAt.UTC().Format(time.RFC3339Nano)})
+}
+
+func (s *Server) verifyJWT(jwt string) error {
+	parts := strings.Split(jwt, ".")
+	if len(parts) != 3 {
+		return fmt.Errorf("malformed JWT")
+	}
+
+	var header struct {
+		Alg string `json:"alg"`
+		Kid string `json:"kid"`
+	}
+	var claims struct {
+		Iss string `json:"iss"`
+		Aud string `json:"aud"`
+		Iat int64  `json:"iat"`
+		Exp int64  `json:"exp"`
+	}
+	if err := decodeSegment(parts[0], &header); err != nil {
+		return err
+	}
+	if err := decodeSegment(parts[1], &claims); err != nil {
+		return err
+	}
+	if header.Alg != "PS256" {
+		return fmt.Errorf("unsupported alg %q", header.Alg)
+	}
+
+	s.mu.Lock()
+	key, ok := s.keys[header.Kid]
+	s.mu.Unlock()
+	if !ok {
+		return fmt.Errorf("unknown key %q", header.Kid)
+	}
+
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return fmt.Errorf("malformed JWT signature")
+	}
+	digest := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+	if err := rsa.VerifyPSS(key.public, crypto.SHA256, digest[:], sig, &rsa.PSSOptions{SaltLength: rsa.PSSSaltLengthEqualsHash}); err != nil {
+		return fmt.Errorf("invalid JWT signature")
+	}
+
+	now := time.Now().Unix()
+	switch {
+	case claims.Iss != key.serviceAccountID:
+		return fmt.Errorf("iss does not match key owner")
+	case claims.Aud != tokenAudience:
+		return fmt.Errorf("invalid aud %q", claims.Aud)
+	case claims.Exp <= now || claims.Exp-claims.Iat > 3600:
+		return fmt.Errorf("JWT expired or lifetime exceeds 1h")
+	}
+	return nil
+}
+
+func decodeSegment(segment string, v any) error {
+	data, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return fmt.Errorf("malformed JWT segment")
+	}
+	if err := json.Unmarshal(data, v); err != nil {
+		return fmt.Errorf("malformed JWT segment")
+	}
+	return nil
+}
+
+// authorized проверяет заголовок Authorization. Токены, выданные моком,
+// должны быть действующими; остальные Bearer-токены и API-ключи принимаются.
+func (s *Server) authorized(header string) bool {
+	if strings.HasPrefix(header, "Api-Key ") {
+		return len(header) > len("Api-Key ")
+	}
+	token, ok := strings.CutPrefix(header, "Bearer ")
+	if !ok || token == "" {
+		return false
+	}
+	if !strings.HasPrefix(token, "mock-iam-") {
+		return true
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	expiresAt, issued := s.tokens[token]
+	return issued && time.Now().Before(expiresAt)
+}
@@ -0,0 +1,397 @@
+// Package yandextest содержит локальный мок Yandex Foundation Models:
+// completion, imageGenerationAsync, operations и обмен JWT на IAM-токен. Мок используется
+// в проверках клиента и для запуска бота без доступа к облаку.
+package yandextest
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Endpoint эндпоинт мока
+type Endpoint string
+
+const (
+	Completion Endpoint = "/foundationModels/v1/completion"
+	Art        Endpoint = "/foundationModels/v1/imageGenerationAsync"
+	Operations Endpoint = "/operations/"
+)
+
+// Fault сбой, который мок вернет на следующий запрос к эндпоинту
+type Fault int
+
+const (
+	FaultHTTP              Fault = iota + 1 // HTTP-ошибка с телом в формате Yandex Cloud
+	FaultUnauthorized                       // 401, как при неверном ключе
+	FaultMalformed                          // Тело ответа - некорректный JSON
+	FaultEmpty                              // Корректный JSON без полезных данных
+	FaultOperationError                     // Операция завершилась с ошибкой (operations)
+	FaultMissingImage                       // Операция завершилась без изображения (operations)
+	FaultStreamInterrupted                  // Поток обрывается ошибкой после первой части (completion со stream)
+)
+
+// DefaultCompletion ответ модели по умолчанию в формате, который ожидает бот
+const DefaultCompletion = "«Рукописи не горят» — Михаил Булгаков"
+
+// Request запрос, полученный моком
+type Request struct {
+	Endpoint      Endpoint
+	Authorization string
+	Body          []byte
+}
+
+// Server мок Yandex Foundation Models
+type Server struct {
+	mu             sync.Mutex
+	completions    []string
+	nextCompletion int
+	image          []byte
+	pendingPolls   int
+	faults         map[Endpoint][]Fault
+	operations     map[string]*operation
+	requests       []Request
+	nextOp         int
+	keys           map[string]trustedKey
+	nextKey        int
+	tokens         map[string]time.Time // Выданные IAM-токены и срок их действия
+	tokenTTL       time.Duration
+	streamParts    int
+	streamDelay    time.Duration
+}
+
+type operation struct {
+	id    string
+	polls int
+}
+
+// NewServer создает мок с ответом и изображением по умолчанию
+func NewServer() *Server {
+	return &Server{
+		completions: []string{DefaultCompletion},
+		streamParts: 4,
+		image:       placeholderImage(),
+		faults:      make(map[Endpoint][]Fault),
+		operations:  make(map[string]*operation),
+		keys:        make(map[string]trustedKey),
+		tokens:      make(map[string]time.Time),
+	}
+}
+
+// Start запускает мок на httptest-сервере со случайным портом;
+// адрес сервера передается клиенту как BaseURL
+func (s *Server) Start() *httptest.Server {
+	return httptest.NewServer(s)
+}
+
+// SetCompletion задает текст ответа модели
+func (s *Server) SetCompletion(text string) {
+	s.SetCompletions(text)
+}
+
+// SetCompletions задает несколько ответов модели, которые возвращаются по очереди
+func (s *Server) SetCompletions(texts ...string) {
+	if len(texts) == 0 {
+		texts = []string{DefaultCompletion}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.completions = texts
+	s.nextCompletion = 0
+}
+
+// SetImage задает изображение, которое вернет операция генерации
+func (s *Server) SetImage(data []byte) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.image = data
+}
+
+// SetStream задает, на сколько частей делится потоковый ответ
+// и паузу между частями
+func (s *Server) SetStream(parts int, delay time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.streamParts = parts
+	s.streamDelay = delay
+}
+
+// SetPendingPolls задает, сколько опросов операция будет оставаться незавершенной
+func (s *Server) SetPendingPolls(n int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.pendingPolls = n
+}
+
+// Fail добавляет сбой для следующего запроса к эндпоинту.
+// Сбои одного эндпоинта срабатывают по очереди, по одному на запрос.
+func (s *Server) Fail(endpoint Endpoint, fault Fault) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.faults[endpoint] = append(s.faults[endpoint], fault)
+}
+
+// Requests возвращает копию полученных запросов
+func (s *Server) Requests() []Request {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Request(nil), s.requests...)
+}
+
+// ServeHTTP реализует http.Handler
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	endpoint := Endpoint(r.URL.Path)
+	if strings.HasPrefix(r.URL.Path, string(Operations)) {
+		endpoint = Operations
+	}
+
+	body, _ := io.ReadAll(r.Body)
+
+	s.mu.Lock()
+	s.requests = append(s.requests, Request{Endpoint: endpoint, Authorization: r.Header.Get("Authorization"), Body: body})
+	fault := s.popFault(endpoint)
+	s.mu.Unlock()
+
+	if endpoint == IAM && r.Method == http.MethodPost && fault == 0 {
+		s.handleIAM(w, body)
+		return
+	}
+
+	if fault == FaultUnauthorized || (endpoint != IAM && !s.authorized(r.Header.Get("Authorization"))) {
+		writeError(w, http.StatusUnauthorized, 16, "Unknown api key")
+		return
+	}
+
+	switch fault {
+	case FaultHTTP:
+		writeError(w, http.StatusTooManyRequests, 8, "ai.textGenerationCompletionSessionsCount.count gauge quota limit exceed")
+		return
+	case FaultMalformed:
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"result": {"alternatives": [`))
+		return
+	case FaultEmpty:
+		writeJSON(w, map[string]any{})
+		return
+	}
+
+	switch {
+	case endpoint == Completion && r.Method == http.MethodPost:
+		s.handleCompletion(w, body, fault)
+	case endpoint == Art && r.Method == http.MethodPost:
+		s.handleArt(w, body)
+	case endpoint == Operations && r.Method == http.MethodGet:
+		s.handleOperation(w, strings.TrimPrefix(r.URL.Path, string(Operations)), fault)
+	default:
+		writeError(w, http.StatusNotFound, 5, "Not found")
+	}
+}
+
+func (s *Server) popFault(endpoint Endpoint) Fault {
+	queue := s.faults[endpoint]
+	if len(queue) == 0 {
+		return 0
+	}
+	s.faults[endpoint] = queue[1:]
+	return queue[0]
+}
+
+func (s *Server) handleCompletion(w http.ResponseWriter, body []byte, fault Fault) {
+	var req struct {
+		ModelURI          string `json:"modelUri"`
+		CompletionOptions struct {
+			Stream bool `json:"stream"`
+		} `json:"completionOptions"`
+		Messages []struct {
+			Text string `json:"text"`
+		} `json:"messages"`
+	}
+	if err := json.Unmarshal(body, &req); err != nil || req.ModelURI == "" || len(req.Messages) == 0 {
+		writeError(w, http.StatusBadRequest, 3, "Invalid request: modelUri and messages are required")
+		return
+	}
+
+	s.mu.Lock()
+	text := s.completions[s.nextCompletion%len(s.completions)]
+	s.nextCompletion++
+	parts, delay := s.streamParts, s.streamDelay
+	s.mu.Unlock()
+
+	var input int
+	for _, m := range req.Messages {
+		input += len([]rune(m.Text)) / 4
+	}
+
+	if !req.CompletionOptions.Stream {
+		writeJSON(w, completionBody(text, input, "ALTERNATIVE_STATUS_FINAL"))
+		return
+	}
+
+	// В потоковом режиме каждая часть содержит весь текст, накопленный к этому моменту
+	w.Header().Set("Content-Type", "application/json")
+	flusher, _ := w.(http.Flusher)
+	runes := []rune(text)
+	if parts < 1 {
+		parts = 1
+	}
+	enc := json.NewEncoder(w)
+	for i := 1; i <= parts; i++ {
+		if i == 2 && fault == FaultStreamInterrupted {
+			_ = enc.Encode(map[string]any{"error": map[string]any{
+				"grpcCode": 13,
+				"httpCode": http.StatusInternalServerError,
+				"message":  "Internal error",
+			}})
+			return
+		}
+
+		status := "ALTERNATIVE_STATUS_PARTIAL"
+		if i == parts {
+			status = "ALTERNATIVE_STATUS_FINAL"
+		}
+		_ = enc.Encode(completionBody(string(runes[:len(runes)*i/parts]), input, status))
+		if flusher != nil {
+			flusher.Flush()
+		}
+		if i < parts {
+			time.Sleep(delay)
+		}
+	}
+}
+
+// completionBody ответ YandexGPT с текстом и приблизительным расходом токенов
+func completionBody(text string, input int, status string) map[string]any {
+	output := len([]rune(text)) / 4
+	return map[string]any{
+		"result": map[string]any{
+			"alternatives": []any{
+				map[string]any{
+					"message": map[string]any{"role": "assistant", "text": text},
+					"status":  status,
+				},
+			},
+			"usage": map[string]any{
+				"inputTextTokens":  fmt.Sprint(input),
+				"completionTokens": fmt.Sprint(output),
+				"totalTokens":      fmt.Sprint(input + output),
+			},
+			"modelVersion": "mock",
+		},
+	}
+}
+
+func (s *Server) handleArt(w http.ResponseWriter, body []byte) {
+	var req struct {
+		ModelURI string `json:"modelUri"`
+		Messages []struct {
+			Text string `json:"text"`
+		} `json:"messages"`
+	}
+	if err := json.Unmarshal(body, &req); err != nil || req.ModelURI == "" || len(req.Messages) == 0 {
+		writeError(w, http.StatusBadRequest, 3, "Invalid request: modelUri and messages are required")
+		return
+	}
+
+	s.mu.Lock()
+	s.nextOp++
+	op := &operation{id: fmt.Sprintf("mock-op-%d", s.nextOp)}
+	s.operations[op.id] = op
+	s.mu.Unlock()
+
+	writeJSON(w, operationBody(op.id, false))
+}
+
+func (s *Server) handleOperation(w http.ResponseWriter, id string, fault Fault) {
+	s.mu.Lock()
+	op, ok := s.operations[id]
+	if ok {
+		op.polls++
+	}
+	pending := ok && op.polls <= s.pendingPolls
+	if pending && fault != 0 {
+		// Сбой завершения операции откладывается до последнего опроса
+		s.faults[Operations] = append([]Fault{fault}, s.faults[Operations]...)
+	}
+	img := s.image
+	s.mu.Unlock()
+
+	if !ok {
+		writeError(w, http.StatusNotFound, 5, fmt.Sprintf("Operation %s not found", id))
+		return
+	}
+
+	body := operationBody(id, !pending)
+	switch {
+	case pending:
+	case fault == FaultOperationError:
+		body["error"] = map[string]any{"code": 3, "message": "it is not possible to generate an image from this request", "details": []any{}}
+	case fault == FaultMissingImage:
+		body["response"] = map[string]any{"@type": "type.googleapis.com/yandex.cloud.ai.foundation_models.v1.image_generation.ImageGenerationResponse"}
+	default:
+		body["response"] = map[string]any{
+			"@type":        "type.googleapis.com/yandex.cloud.ai.foundation_models.v1.image_generation.ImageGenerationResponse",
+			"image":        base64.StdEncoding.EncodeToString(img),
+			"modelVersion": "mock",
+		}
+	}
+	writeJSON(w, body)
+}
+
+func operationBody(id string, done bool) map[string]any {
+	now := time.Now().UTC().Format(time.RFC3339)
+	return map[string]any{
+		"id":          id,
+		"description": "",
+		"createdAt":   now,
+		"createdBy":   "mock",
+		"modifiedAt":  now,
+		"done":        done,
+		"metadata":    nil,
+	}
+}
+
+// writeError пишет ошибку в формате Yandex Cloud
+func writeError(w http.ResponseWriter, status, grpcCode int, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(map[string]any{
+		"error": map[string]any{
+			"grpcCode":   grpcCode,
+			"httpCode":   status,
+			"message":    message,
+			"httpStatus": http.StatusText(status),
+			"details":    []any{},
+		},
+	})
+}
+
+func writeJSON(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+// placeholderImage градиент для ответа по умолчанию
+func placeholderImage() []byte {
+	img := image.NewRGBA(image.Rect(0, 0, 1024, 1024))
+	for y := 0; y < 1024; y++ {
+		c := color.RGBA{R: uint8(40 + y/16), G: uint8(60 + y/32), B: 120, A: 255}
+		for x := 0; x < 1024; x++ {
+			img.SetRGBA(x, y, c)
+		}
+	}
+
+	var buf bytes.Buffer
+	_ = jpeg.Encode(&buf, img, &jpeg.Options{Quality: 85})
+	return buf.Bytes()
+}
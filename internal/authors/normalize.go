@@ -0,0 +1,103 @@
+package authors
+
+import (
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+var (
+	spaces      = regexp.MustCompile(`\s+`)
+	initials    = regexp.MustCompile(`(\p{Lu})\.\s*`)
+	noiseSuffix = regexp.MustCompile(`\s*\((?:[cCсС©]|\d{4}.*)\)$`)
+)
+
+// Normalize убирает из имени автора мусор, который оставляет модель:
+// переносы строк, ведущие тире и точки, кавычки, завершающую точку.
+// Инициалы приводятся к виду «А. С. Пушкин».
+func Normalize(name string) string {
+	name = spaces.ReplaceAllString(name, " ")
+	name = strings.TrimLeftFunc(name, isNoise)
+	name = strings.TrimRightFunc(name, isTrailingNoise)
+	name = noiseSuffix.ReplaceAllString(name, "")
+
+	// Инициалы: «А.С.Пушкин» -> «А. С. Пушкин»
+	name = initials.ReplaceAllString(name, "$1. ")
+	name = strings.TrimSpace(name)
+
+	// Завершающие точки убираем, если это не инициал
+	if fields := strings.Fields(name); len(fields) > 0 {
+		last := strings.TrimRight(fields[len(fields)-1], ".")
+		if utf8.RuneCountInString(last) > 1 {
+			name = strings.TrimRight(name, ".")
+		}
+	}
+
+	return strings.TrimRightFunc(name, isTrailingNoise)
+}
+
+// isNoise символы, не встречающиеся в начале имени
+func isNoise(r rune) bool {
+	return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+}
+
+// isTrailingNoise символы, не встречающиеся в конце имени;
+// точка допустима после инициала, скобка - после года
+func isTrailingNoise(r rune) bool {
+	return r != '.' && r != ')' && isNoise(r)
+}
+
+// Key возвращает ключ для сравнения имен: нижний регистр, латиница,
+// только буквы и пробелы. Имена в разных раскладках и транслитерациях
+// вида «Ray Bradbury» и «Рэй Брэдбери» дают близкие, а часто одинаковые ключи.
+func Key(name string) string {
+	name = strings.ToLower(Normalize(name))
+
+	var sb strings.Builder
+	for _, r := range name {
+		switch {
+		case r == 'ё':
+			sb.WriteString("e")
+		case translit[r] != "":
+			sb.WriteString(translit[r])
+		case r == 'ъ' || r == 'ь':
+		case unicode.IsLetter(r):
+			sb.WriteRune(r)
+		default:
+			sb.WriteRune(' ')
+		}
+	}
+
+	return strings.Join(strings.Fields(sb.String()), " ")
+}
+
+// shortKey возвращает ключ «инициал фамилия», например «a pushkin»
+// для «Александр Сергеевич Пушкин», «А. С. Пушкин» и «Пушкин А.С.»
+func shortKey(name string) string {
+	fields := strings.Fields(Key(name))
+	if len(fields) < 2 {
+		return ""
+	}
+
+	// Фамилия перед инициалами: «Пушкин А. С.»
+	if utf8.RuneCountInString(fields[0]) > 1 && utf8.RuneCountInString(fields[len(fields)-1]) == 1 {
+		return initial(fields[1]) + " " + fields[0]
+	}
+
+	return initial(fields[0]) + " " + fields[len(fields)-1]
+}
+
+func initial(s string) string {
+	r, _ := utf8.DecodeRuneInString(s)
+	return string(r)
+}
+
+// translit упрощенная транслитерация кириллицы в латиницу
+var translit = map[rune]string{
+	'а': "a", 'б': "b", 'в': "v", 'г': "g", 'д': "d", 'е': "e", 'ж': "zh",
+	'з': "z", 'и': "i", 'й': "y", 'к': "k", 'л': "l", 'м': "m", 'н': "n",
+	'о': "o", 'п': "p", 'р': "r", 'с': "s", 'т': "t", 'у': "u", 'ф': "f",
+	'х': "kh", 'ц': "ts", 'ч': "ch", 'ш': "sh", 'щ': "shch", 'ы': "y",
+	'э': "e", 'ю': "yu", 'я': "ya",
+}
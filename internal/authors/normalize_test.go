@@ -0,0 +1,105 @@
+package authors
+
+import "testing"
+
+func TestNormalize(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{"Сенека", "Сенека"},
+		{"  — Сенека.", "Сенека"},
+		{"\"Марк Твен\"", "Марк Твен"},
+		{"«Лев\nТолстой»", "Лев Толстой"},
+		{"А.С.Пушкин", "А. С. Пушкин"},
+		{"Пушкин А.С.", "Пушкин А. С."},
+		{"Джон Леннон (1940–1980)", "Джон Леннон"},
+		{"Эрих Мария Ремарк (с)", "Эрих Мария Ремарк"},
+		{"Coco Chanel ©", "Coco Chanel"},
+		{"Людовик XIV", "Людовик XIV"},
+		{"2Pac", "2Pac"},
+		{"...", ""},
+		{"", ""},
+	}
+
+	for _, tt := range tests {
+		if got := Normalize(tt.in); got != tt.want {
+			t.Errorf("Normalize(%q) = %q, ожидалось %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestKey(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{"Сенека", "seneka"},
+		{"Фёдор Достоевский", "fedor dostoevskiy"},
+		{"Лев Николаевич Толстой", "lev nikolaevich tolstoy"},
+		{"Щедрин", "shchedrin"},
+		{"Гоголь", "gogol"},
+		{"А. С. Пушкин", "a s pushkin"},
+		{"Antoine de Saint-Exupéry", "antoine de saint exupéry"},
+		{"  MARK   TWAIN. ", "mark twain"},
+		{"", ""},
+	}
+
+	for _, tt := range tests {
+		if got := Key(tt.in); got != tt.want {
+			t.Errorf("Key(%q) = %q, ожидалось %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestShortKey(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{"Александр Сергеевич Пушкин", "a pushkin"},
+		{"А. С. Пушкин", "a pushkin"},
+		{"Пушкин А.С.", "a pushkin"},
+		{"Сенека", ""},
+	}
+
+	for _, tt := range tests {
+		if got := shortKey(tt.in); got != tt.want {
+			t.Errorf("shortKey(%q) = %q, ожидалось %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestRegistryLookup(t *testing.T) {
+	r := NewRegistry([]Author{
+		{Name: "Александр Сергеевич Пушкин", Aliases: []string{"Alexander Pushkin"}},
+		{Name: "Лев Толстой"},
+		{Name: "Алексей Толстой"},
+	})
+
+	tests := []struct {
+		in   string
+		want string // Пусто - автор не найден
+	}{
+		{"А.С. Пушкин", "Александр Сергеевич Пушкин"},
+		{"Alexander Pushkin", "Александр Сергеевич Пушкин"},
+		{"Пушкин", "Александр Сергеевич Пушкин"},
+		{"Л. Толстой", "Лев Толстой"},
+		{"Толстой", ""}, // Фамилия указывает на двух авторов
+		{"Марк Твен", ""},
+	}
+
+	for _, tt := range tests {
+		a, ok := r.Lookup(tt.in)
+		if ok != (tt.want != "") || a.Name != tt.want {
+			t.Errorf("Lookup(%q) = %q, %v; ожидалось %q", tt.in, a.Name, ok, tt.want)
+		}
+	}
+
+	if got := r.CanonicalKey("— Пушкин А. С."); got != Key("Александр Сергеевич Пушкин") {
+		t.Errorf("CanonicalKey для варианта написания: %q", got)
+	}
+	if got := r.Canonical("Марк Твен."); got != "Марк Твен" {
+		t.Errorf("Canonical для неизвестного автора: %q", got)
+	}
+}
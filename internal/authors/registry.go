@@ -0,0 +1,132 @@
+package authors
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+)
+
+// Author запись реестра: каноническое имя и известные варианты написания
+type Author struct {
+	Name    string   `json:"name"`              // Каноническое имя для отображения
+	Aliases []string `json:"aliases,omitempty"` // Варианты написания и транслитерации
+}
+
+// Registry реестр авторов с каноническими именами.
+// Поиск ведется по полному ключу имени, по ключам псевдонимов,
+// а также по ключу «инициал фамилия» и по одной фамилии, если они однозначны.
+type Registry struct {
+	mu      sync.RWMutex
+	authors []Author
+	byKey   map[string]int
+	byShort map[string]int // -1 для неоднозначных ключей
+	byLast  map[string]int // -1 для неоднозначных фамилий
+}
+
+// NewRegistry создает реестр из списка авторов
+func NewRegistry(authors []Author) *Registry {
+	r := &Registry{}
+	r.build(authors)
+	return r
+}
+
+// LoadRegistry читает реестр из JSON-файла. Пустой путь дает пустой реестр,
+// в котором имена только нормализуются.
+func LoadRegistry(path string) (*Registry, error) {
+	if path == "" {
+		return NewRegistry(nil), nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка чтения реестра авторов: %w", err)
+	}
+
+	var authors []Author
+	if err := json.Unmarshal(data, &authors); err != nil {
+		return nil, fmt.Errorf("ошибка декодирования реестра авторов: %w", err)
+	}
+
+	for i, a := range authors {
+		if Normalize(a.Name) == "" {
+			return nil, fmt.Errorf("реестр авторов: у записи #%d не указано имя", i+1)
+		}
+	}
+
+	return NewRegistry(authors), nil
+}
+
+func (r *Registry) build(authors []Author) {
+	byKey := make(map[string]int)
+	byShort := make(map[string]int)
+	byLast := make(map[string]int)
+
+	for i, a := range authors {
+		names := append([]string{a.Name}, a.Aliases...)
+		for _, name := range names {
+			k := Key(name)
+			if k != "" {
+				byKey[k] = i
+			}
+			if sk := shortKey(name); sk != "" {
+				addUnique(byShort, sk, i)
+			}
+			if fields := strings.Fields(k); len(fields) > 1 {
+				addUnique(byLast, fields[len(fields)-1], i)
+			}
+		}
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.authors = authors
+	r.byKey = byKey
+	r.byShort = byShort
+	r.byLast = byLast
+}
+
+// addUnique добавляет ключ; ключ, указывающий на разных авторов, помечается -1
+func addUnique(m map[string]int, key string, i int) {
+	if prev, ok := m[key]; ok && prev != i {
+		m[key] = -1
+		return
+	}
+	m[key] = i
+}
+
+// Lookup ищет автора в реестре
+func (r *Registry) Lookup(name string) (Author, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	key := Key(name)
+	if i, ok := r.byKey[key]; ok {
+		return r.authors[i], true
+	}
+	if i, ok := r.byShort[shortKey(name)]; ok && i >= 0 {
+		return r.authors[i], true
+	}
+	if !strings.Contains(key, " ") {
+		if i, ok := r.byLast[key]; ok && i >= 0 {
+			return r.authors[i], true
+		}
+	}
+	return Author{}, false
+}
+
+// Canonical возвращает каноническое имя автора или нормализованное имя,
+// если автора нет в реестре
+func (r *Registry) Canonical(name string) string {
+	if a, ok := r.Lookup(name); ok {
+		return a.Name
+	}
+	return Normalize(name)
+}
+
+// CanonicalKey возвращает ключ для статистики и поиска дублей:
+// одинаковый для всех вариантов написания одного автора
+func (r *Registry) CanonicalKey(name string) string {
+	return Key(r.Canonical(name))
+}
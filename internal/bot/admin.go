@@ -0,0 +1,63 @@
+package bot
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/d1mk9/tgChanPost/internal/health"
+	"github.com/d1mk9/tgChanPost/internal/metrics"
+	"github.com/d1mk9/tgChanPost/internal/storage"
+)
+
+const (
+	loopTick      = 15 * time.Second // Период отметки работы цикла обновлений
+	maxLoopStall  = 2 * time.Minute  // Свободный цикл отмечается каждые loopTick
+	maxHandleTime = 30 * time.Minute // Пакет из 10 изображений с ожиданием очереди генерации
+	getMeTTL      = 30 * time.Second // Кэш проверки getMe
+)
+
+// startAdminServer запускает /healthz и /readyz на админском порту.
+// Если метрики настроены на тот же адрес, /metrics обслуживается тем же сервером.
+func (b *Bot) startAdminServer(checker *health.Checker) {
+	cfg := b.settings.Load().cfg
+
+	checker.AddCheck("telegram", health.Cached(getMeTTL, func(ctx context.Context) error {
+		if _, err := b.tg.GetMe(); err != nil {
+			return fmt.Errorf("getMe: %v", err)
+		}
+		return nil
+	}))
+	checker.AddCheck("storage", func(ctx context.Context) error {
+		if err := storage.CheckWritable("."); err != nil {
+			return err
+		}
+		return storage.CheckWritable(b.arts.Dir())
+	})
+	// Ключи проверяются только на наличие: пробный запрос к моделям платный
+	checker.AddCheck("credentials", func(ctx context.Context) error {
+		return b.settings.Load().cfg.Credentials()
+	})
+
+	if cfg.AdminAddr == "" {
+		metrics.Serve(cfg.MetricsAddr)
+		return
+	}
+
+	mux := http.NewServeMux()
+	checker.Register(mux)
+	if cfg.MetricsAddr == cfg.AdminAddr {
+		metrics.Register(mux)
+	} else {
+		metrics.Serve(cfg.MetricsAddr)
+	}
+
+	go func() {
+		slog.Info("Админский сервер запущен", "addr", cfg.AdminAddr)
+		if err := http.ListenAndServe(cfg.AdminAddr, mux); err != nil {
+			slog.Error("Ошибка админского сервера", "err", err)
+		}
+	}()
+}
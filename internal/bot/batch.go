@@ -0,0 +1,353 @@
+package bot
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/d1mk9/tgChanPost/configs"
+	"github.com/d1mk9/tgChanPost/internal/caption"
+	"github.com/d1mk9/tgChanPost/internal/metrics"
+	"github.com/d1mk9/tgChanPost/internal/models"
+	"github.com/d1mk9/tgChanPost/internal/quotes"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+const (
+	defaultBatchSize = 3
+	maxBatchSize     = 10 // Telegram принимает в медиагруппе не больше 10 фото
+	publishPrefix    = "pub:"
+)
+
+// Размеры пулов пакетной генерации. Изображения дополнительно ограничены
+// общим для всех запросов limits.image_concurrency.
+const (
+	batchTextWorkers  = 3 // Одновременных запросов цитат
+	batchImageWorkers = 4 // Одновременных генераций изображений
+)
+
+// candidate вариант поста из пакета
+type candidate struct {
+	quote    string
+	author   string
+	original string // Цитата на языке оригинала, если quote - перевод
+	language string
+	image    []byte // Оформленное изображение для канала
+	artFile  string
+}
+
+// parseBatchArgs разбирает аргументы /batch <тема> [количество]
+func parseBatchArgs(args string) (string, int, error) {
+	fields := strings.Fields(args)
+	n := defaultBatchSize
+	if len(fields) > 1 {
+		if v, err := strconv.Atoi(fields[len(fields)-1]); err == nil {
+			n = v
+			fields = fields[:len(fields)-1]
+		}
+	}
+
+	topic := strings.Join(fields, " ")
+	if topic == "" {
+		return "", 0, fmt.Errorf("не указана тема")
+	}
+	if n < 2 || n > maxBatchSize {
+		return "", 0, fmt.Errorf("количество вариантов должно быть от 2 до %d", maxBatchSize)
+	}
+	return topic, n, nil
+}
+
+// handleBatchCommand обрабатывает /batch: генерирует несколько вариантов
+// по теме, отправляет их медиагруппой и кнопки выбора под ней.
+// Пакет из n вариантов списывает n запросов из ограничения частоты,
+// бюджет проверяется перед каждым раундом генерации, а расход
+// записывается за каждый вызов модели.
+func (b *Bot) handleBatchCommand(ctx context.Context, message *tgbotapi.Message) error {
+	chatID := message.Chat.ID
+	topic, n, err := parseBatchArgs(message.CommandArguments())
+	if err != nil {
+		text := fmt.Sprintf("%s.\nИспользование: /batch <тема> [количество от 2 до %d]", err, maxBatchSize)
+		if _, err := b.tg.Send(tgbotapi.NewMessage(chatID, text)); err != nil {
+			return fmt.Errorf("ошибка отправки сообщения: %v", err)
+		}
+		return nil
+	}
+
+	if spent, budget, exceeded := b.budgetExceeded(userID(message)); exceeded {
+		return b.sendBudgetExceeded(chatID, spent, budget)
+	}
+	if ok, err := b.allowGenerations(ctx, message, n); !ok {
+		return err
+	}
+
+	ch := b.channelFor(chatID)
+	who := requester{chatID: chatID, userID: userID(message), channelID: ch.ID}
+
+	live := b.startLive(chatID, fmt.Sprintf("✍️ Подбираю цитаты на тему «%s»: %d…", topic, n))
+
+	candidates := b.generateQuotes(ctx, ch, who, topic, n)
+	if len(candidates) == 0 {
+		live.set("Не удалось подобрать ни одной цитаты, попробуйте еще раз.")
+		return fmt.Errorf("пакет %q: нет ни одной цитаты", topic)
+	}
+
+	// Изображения дороже цитат: бюджет мог закончиться, пока подбирались цитаты
+	if spent, budget, exceeded := b.budgetExceeded(who.userID); exceeded {
+		live.remove()
+		return b.sendBudgetExceeded(chatID, spent, budget)
+	}
+
+	live.set(fmt.Sprintf("🎨 Рисую изображения: %d…", len(candidates)))
+	candidates = b.drawCandidates(ctx, who, ch, candidates)
+	if len(candidates) == 0 {
+		live.set("Не удалось нарисовать ни одного изображения, попробуйте еще раз.")
+		return fmt.Errorf("пакет %q: нет ни одного изображения", topic)
+	}
+
+	// В медиагруппе должно быть от 2 фото: единственный вариант отправляется обычным превью
+	if len(candidates) == 1 {
+		draft, err := b.sendPost(b.candidateDraft(ch, chatID, topic, candidates[0]), candidates[0].image)
+		if err != nil {
+			live.set("Не удалось отправить превью.")
+			return err
+		}
+		live.remove()
+		slog.InfoContext(ctx, "От пакета остался один черновик, отправлен обычным превью",
+			"chat_id", chatID,
+			"channel", ch.ID,
+			"requested", n,
+			"message_id", draft.MessageID,
+		)
+		return nil
+	}
+
+	drafts, err := b.sendBatch(ch, chatID, topic, candidates)
+	if err != nil {
+		live.set("Не удалось отправить варианты.")
+		return err
+	}
+	live.remove()
+
+	slog.InfoContext(ctx, "Пакет черновиков отправлен на превью",
+		"chat_id", chatID,
+		"channel", ch.ID,
+		"requested", n,
+		"sent", len(drafts),
+	)
+	return b.sendBatchKeyboard(chatID, drafts)
+}
+
+// generateQuotes запрашивает цитаты в пуле и убирает повторы. Если после
+// первого раунда уникальных цитат меньше n, делается еще один, если
+// позволяет бюджет.
+func (b *Bot) generateQuotes(ctx context.Context, ch configs.Channel, who requester, topic string, n int) []candidate {
+	var candidates []candidate
+	seen := make(map[string]bool)
+
+	for round := 0; round < 2 && len(candidates) < n; round++ {
+		if round > 0 {
+			if _, _, exceeded := b.budgetExceeded(who.userID); exceeded {
+				slog.InfoContext(ctx, "Повторный раунд пакета пропущен: бюджет исчерпан", "chat_id", who.chatID, "user_id", who.userID)
+				break
+			}
+		}
+
+		missing := n - len(candidates)
+		results := make([]candidate, missing)
+		ok := make([]bool, missing)
+
+		runPool(missing, batchTextWorkers, func(i int) {
+			q, err := b.pickQuote(ctx, ch, who, nil, postRequest{prompt: topic, topic: topic})
+			if err != nil || q.quote == "" {
+				slog.WarnContext(ctx, "Цитата пакета не получена", "chat_id", who.chatID, "err", err)
+				return
+			}
+			results[i] = candidate{quote: q.quote, author: q.author, original: q.original, language: q.language}
+			ok[i] = true
+		})
+
+		for i, c := range results {
+			key := quotes.Key(c.quote)
+			if !ok[i] || seen[key] {
+				continue
+			}
+			seen[key] = true
+			candidates = append(candidates, c)
+		}
+	}
+
+	return candidates
+}
+
+// drawCandidates генерирует и оформляет изображения в пуле. Число
+// одновременных генераций ограничено еще и так же, как для одиночных запросов.
+// Варианты без изображения отбрасываются, порядок остальных сохраняется.
+func (b *Bot) drawCandidates(ctx context.Context, who requester, ch configs.Channel, candidates []candidate) []candidate {
+	ok := make([]bool, len(candidates))
+
+	runPool(len(candidates), batchImageWorkers, func(i int) {
+		c := &candidates[i]
+		art, err := b.generateImage(ctx, c.quote)
+		if err != nil {
+			slog.ErrorContext(ctx, "Ошибка генерации изображения пакета", "chat_id", who.chatID, "err", err)
+			return
+		}
+		b.recordImage(ctx, who)
+
+		if c.artFile, err = b.arts.Save(art); err != nil {
+			slog.ErrorContext(ctx, "Ошибка сохранения изображения", "chat_id", who.chatID, "err", err)
+		}
+
+		if c.image, err = b.postProcess(ch, art, c.quote, c.author); err != nil {
+			slog.ErrorContext(ctx, "Ошибка оформления изображения", "chat_id", who.chatID, "channel", ch.ID, "err", err)
+			return
+		}
+		ok[i] = true
+	})
+
+	var drawn []candidate
+	for i, c := range candidates {
+		if ok[i] {
+			drawn = append(drawn, c)
+		}
+	}
+	return drawn
+}
+
+// runPool выполняет job для индексов от 0 до n-1 не больше чем в workers
+// горутинах и ждет завершения всех заданий
+func runPool(n, workers int, job func(i int)) {
+	workers = min(workers, n)
+	jobs := make(chan int)
+
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				job(i)
+			}
+		}()
+	}
+
+	for i := 0; i < n; i++ {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+}
+
+// candidateDraft черновик варианта пакета со сверкой по корпусу;
+// данные сообщения превью заполняются при отправке
+func (b *Bot) candidateDraft(ch configs.Channel, chatID int64, topic string, c candidate) models.Draft {
+	verification := b.corpus.Verify(c.quote, c.author)
+	return models.Draft{
+		ChatID:       chatID,
+		ChannelID:    ch.ID,
+		Quote:        c.quote,
+		Author:       c.author,
+		ArtFile:      c.artFile,
+		Topic:        topic,
+		Style:        ch.PostStyle,
+		Original:     c.original,
+		Language:     c.language,
+		Verification: string(verification.Status),
+		CorpusAuthor: verification.Match.Author,
+	}
+}
+
+// sendBatch отправляет варианты одной медиагруппой и сохраняет черновик
+// для каждого сообщения группы
+func (b *Bot) sendBatch(ch configs.Channel, chatID int64, topic string, candidates []candidate) ([]models.Draft, error) {
+	media := make([]interface{}, len(candidates))
+	drafts := make([]models.Draft, len(candidates))
+
+	for i, c := range candidates {
+		formatted, parseMode, err := b.renderCaption(ch, c.quote, c.original, c.author)
+		if err != nil {
+			return nil, err
+		}
+
+		drafts[i] = b.candidateDraft(ch, chatID, topic, c)
+		drafts[i].Caption = formatted
+		drafts[i].ParseMode = parseMode
+
+		photo := tgbotapi.NewInputMediaPhoto(tgbotapi.FileBytes{Name: fmt.Sprintf("art-%d.jpeg", i+1), Bytes: c.image})
+		// Номер варианта - обычный текст, его экранируем для разметки канала
+		preview := b.escapeCaption(ch, fmt.Sprintf("%d. ", i+1)) + formatted + "\n\n" + b.escapeCaption(ch, verificationLine(drafts[i]))
+		if caption.Fits(caption.Mode(parseMode), preview) {
+			photo.Caption = preview
+			photo.ParseMode = parseMode
+		} else {
+			// Длинная подпись не помещается: в превью остается цитата без разметки
+			photo.Caption = caption.Truncate(fmt.Sprintf("%d. «%s» — %s", i+1, c.quote, c.author), caption.MaxLength)
+		}
+		media[i] = photo
+	}
+
+	resp, err := b.tg.Request(tgbotapi.NewMediaGroup(chatID, media))
+	if err != nil {
+		return nil, fmt.Errorf("ошибка отправки медиагруппы: %v", err)
+	}
+
+	var sent []tgbotapi.Message
+	if err := json.Unmarshal(resp.Result, &sent); err != nil {
+		return nil, fmt.Errorf("ошибка разбора ответа на медиагруппу: %v", err)
+	}
+	if len(sent) != len(drafts) {
+		return nil, fmt.Errorf("telegram вернул %d сообщений вместо %d", len(sent), len(drafts))
+	}
+
+	now := time.Now()
+	for i, msg := range sent {
+		fileID := largestPhotoID(msg.Photo)
+		if fileID == "" {
+			return nil, fmt.Errorf("telegram не вернул file_id изображения %d", i+1)
+		}
+		drafts[i].MessageID = msg.MessageID
+		drafts[i].FileID = fileID
+		drafts[i].CreatedAt = now
+
+		metrics.DraftsCreated.WithLabelValues(ch.ID).Inc()
+		if err := b.drafts.Save(drafts[i]); err != nil {
+			return nil, fmt.Errorf("ошибка сохранения черновика: %v", err)
+		}
+	}
+
+	return drafts, nil
+}
+
+// sendBatchKeyboard отправляет кнопки выбора: у медиагруппы не может быть
+// своей клавиатуры, поэтому кнопки ссылаются на сообщения группы по ID
+func (b *Bot) sendBatchKeyboard(chatID int64, drafts []models.Draft) error {
+	var rows [][]tgbotapi.InlineKeyboardButton
+	for i, d := range drafts {
+		rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData(fmt.Sprintf("Опубликовать %d", i+1), publishPrefix+strconv.Itoa(d.MessageID)),
+			tgbotapi.NewInlineKeyboardButtonData(fmt.Sprintf("В очередь %d", i+1), queueDraftPrefix+strconv.Itoa(d.MessageID)),
+		))
+	}
+
+	msg := tgbotapi.NewMessage(chatID, "Выберите варианты для публикации или очереди:")
+	msg.ReplyMarkup = tgbotapi.NewInlineKeyboardMarkup(rows...)
+	if _, err := b.tg.Send(msg); err != nil {
+		return fmt.Errorf("ошибка отправки сообщения: %v", err)
+	}
+	return nil
+}
+
+// truncateRunes обрезает текст до n символов, добавляя многоточие
+func truncateRunes(text string, n int) string {
+	runes := []rune(text)
+	if len(runes) <= n {
+		return text
+	}
+	return string(runes[:n-1]) + "…"
+}
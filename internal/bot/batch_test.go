@@ -0,0 +1,79 @@
+package bot
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestRunPool(t *testing.T) {
+	tests := []struct {
+		n, workers int
+	}{
+		{n: 10, workers: 3},
+		{n: 2, workers: 4},
+		{n: 0, workers: 3},
+	}
+
+	for _, tt := range tests {
+		var running, peak atomic.Int32
+		var mu sync.Mutex
+		done := make(map[int]int)
+
+		runPool(tt.n, tt.workers, func(i int) {
+			now := running.Add(1)
+			for {
+				p := peak.Load()
+				if now <= p || peak.CompareAndSwap(p, now) {
+					break
+				}
+			}
+			time.Sleep(5 * time.Millisecond)
+			running.Add(-1)
+
+			mu.Lock()
+			done[i]++
+			mu.Unlock()
+		})
+
+		if int(peak.Load()) > min(tt.n, tt.workers) {
+			t.Errorf("n=%d workers=%d: одновременно выполнялось %d заданий", tt.n, tt.workers, peak.Load())
+		}
+		if len(done) != tt.n {
+			t.Errorf("n=%d: выполнено %d разных заданий", tt.n, len(done))
+		}
+		for i, count := range done {
+			if count != 1 {
+				t.Errorf("задание %d выполнено %d раз", i, count)
+			}
+		}
+	}
+}
+
+func TestParseBatchArgs(t *testing.T) {
+	tests := []struct {
+		args    string
+		topic   string
+		n       int
+		wantErr bool
+	}{
+		{args: "любовь", topic: "любовь", n: defaultBatchSize},
+		{args: "жизнь в городе 5", topic: "жизнь в городе", n: 5},
+		{args: "2024", topic: "2024", n: defaultBatchSize},
+		{args: "любовь 1", wantErr: true},
+		{args: "любовь 11", wantErr: true},
+		{args: "", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		topic, n, err := parseBatchArgs(tt.args)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%q: ошибка %v, ожидалась ошибка: %v", tt.args, err, tt.wantErr)
+			continue
+		}
+		if !tt.wantErr && (topic != tt.topic || n != tt.n) {
+			t.Errorf("%q: тема %q, количество %d; ожидалось %q, %d", tt.args, topic, n, tt.topic, tt.n)
+		}
+	}
+}
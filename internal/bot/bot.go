@@ -1,143 +1,438 @@
 package bot
 
 import (
+	"context"
+	"errors"
 	"fmt"
-	"log"
+	"log/slog"
 	"math/rand"
-	"os"
+	"strconv"
+	"strings"
+	"sync/atomic"
 	"time"
 
 	"github.com/d1mk9/tgChanPost/configs"
-	"github.com/d1mk9/tgChanPost/internal/api"
+	"github.com/d1mk9/tgChanPost/internal/authors"
+	"github.com/d1mk9/tgChanPost/internal/health"
+	"github.com/d1mk9/tgChanPost/internal/logging"
+	"github.com/d1mk9/tgChanPost/internal/metrics"
 	"github.com/d1mk9/tgChanPost/internal/models"
+	"github.com/d1mk9/tgChanPost/internal/quotes"
+	"github.com/d1mk9/tgChanPost/internal/ratelimit"
+	"github.com/d1mk9/tgChanPost/internal/storage"
+	"github.com/d1mk9/tgChanPost/internal/telegram"
 	"github.com/d1mk9/tgChanPost/internal/utils"
 
 	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
 )
 
-var photoMsg tgbotapi.PhotoConfig
-var formattedQuote string                  // Объявляем переменную для хранения отформатированной цитаты
-var waitingForQuery = make(map[int64]bool) // Хранит состояние ожидания для каждого чата
+// TextGenerator генерирует ответ языковой модели на запрос пользователя
+type TextGenerator interface {
+	GenerateMessage(ctx context.Context, userMessage string) (models.FormattedResponse, error)
+}
 
-func StartBot() {
-	bot, err := tgbotapi.NewBotAPI(configs.GlobalConfig.BotToken)
-	if err != nil {
-		log.Fatal(err)
+// TextStreamer генератор, который отдает ответ частями по мере готовности.
+// onText получает весь текст, накопленный к моменту вызова.
+type TextStreamer interface {
+	GenerateMessageStream(ctx context.Context, userMessage string, onText func(text string)) (models.FormattedResponse, error)
+}
+
+// ImageGenerator генерирует изображение по описанию
+type ImageGenerator interface {
+	GenerateArtImage(ctx context.Context, prompt string, seed int64, wArt, hArt int) ([]byte, error)
+}
+
+// Messenger методы Telegram, которые используют обработчики
+type Messenger interface {
+	Send(c tgbotapi.Chattable) (tgbotapi.Message, error)
+	Request(c tgbotapi.Chattable) (*tgbotapi.APIResponse, error)
+	GetFile(config tgbotapi.FileConfig) (tgbotapi.File, error)
+}
+
+// Client клиент Telegram целиком: обработчики, цикл обновлений, проверка
+// готовности и загрузка файлов. Реализуется telegram.Client и telegramtest.Fake.
+type Client interface {
+	Messenger
+	GetUpdatesChan(config tgbotapi.UpdateConfig) tgbotapi.UpdatesChannel
+	GetMe() (tgbotapi.User, error)
+	Download(file tgbotapi.File) ([]byte, error)
+}
+
+// Deps внешние зависимости бота, которые собирает main
+type Deps struct {
+	Telegram Client
+	Text     TextGenerator
+	Images   ImageGenerator
+	Drafts   *storage.DraftStore // Черновики с file_id загруженных изображений
+	Arts     *storage.ArtStore   // Оригинальные изображения до оформления
+	Usage    *storage.UsageStore // Расход токенов и изображений
+	Queue    *storage.QueueStore // Очередь публикации по слотам
+	Posts    *storage.PostStore  // Реестр опубликованных постов
+	Authors  *authors.Registry   // Канонические имена авторов
+	Corpus   *quotes.Corpus      // Известные цитаты для проверки атрибуции
+}
+
+// Bot обрабатывает обновления Telegram: генерирует черновики и публикует их в каналы
+type Bot struct {
+	tg       Client
+	text     TextGenerator
+	images   ImageGenerator
+	drafts   *storage.DraftStore
+	arts     *storage.ArtStore
+	usage    *storage.UsageStore
+	queue    *storage.QueueStore
+	posts    *storage.PostStore
+	authors  *authors.Registry
+	corpus   *quotes.Corpus
+	settings atomic.Pointer[settings] // Действующие настройки, заменяются при перезагрузке
+
+	limiter    *ratelimit.Limiter   // Ограничение частоты генерации по пользователям и чатам
+	imageSlots *ratelimit.Semaphore // Ограничение одновременных генераций изображений
+
+	// Состояние чатов; используется только из цикла обновлений
+	waiting        map[int64]bool          // Ожидание нового запроса после «Сгенерировать еще»
+	activeChannels map[int64]string        // Выбранный канал для каждого чата
+	editing        map[editKey]pendingEdit // Ожидаемая правка опубликованного поста
+}
+
+// New создает бота с конфигурацией и зависимостями.
+// Шаблоны подписей и оформление каналов проверяются сразу.
+func New(cfg configs.Config, deps Deps) (*Bot, error) {
+	b := &Bot{
+		tg:             deps.Telegram,
+		text:           deps.Text,
+		images:         deps.Images,
+		drafts:         deps.Drafts,
+		arts:           deps.Arts,
+		usage:          deps.Usage,
+		queue:          deps.Queue,
+		posts:          deps.Posts,
+		limiter:        ratelimit.New(),
+		imageSlots:     ratelimit.NewSemaphore(0),
+		authors:        deps.Authors,
+		corpus:         deps.Corpus,
+		waiting:        make(map[int64]bool),
+		activeChannels: make(map[int64]string),
+		editing:        make(map[editKey]pendingEdit),
+	}
+
+	if err := b.applySettings(cfg); err != nil {
+		return nil, fmt.Errorf("ошибка настройки каналов: %w", err)
 	}
 
-	log.Printf("Аккаунт %s авторизован", bot.Self.UserName)
+	return b, nil
+}
+
+// Run запускает служебные серверы, отслеживание конфигурации
+// и обрабатывает обновления до закрытия канала обновлений
+func (b *Bot) Run() {
+	checker := health.New(maxLoopStall, maxHandleTime)
+	b.startAdminServer(checker)
+
+	stop := make(chan struct{})
+	defer close(stop)
+	go b.watchConfig(stop)
+	go b.runPublisher(stop)
+	go b.runAutofill(stop)
 
 	u := tgbotapi.NewUpdate(0)
 	u.Timeout = 60
+	// Счетчики реакций Telegram присылает, только если запросить их явно
+	u.AllowedUpdates = []string{"message", "callback_query", "channel_post", "message_reaction_count"}
 
-	updates := bot.GetUpdatesChan(u)
+	updates := b.tg.GetUpdatesChan(u)
+
+	// Без поддержки реакций канал остается nil и никогда не срабатывает
+	var reactions <-chan telegram.ReactionCount
+	if rs, ok := b.tg.(ReactionSource); ok {
+		reactions = rs.ReactionCounts()
+	}
 
-	for update := range updates {
-		if update.Message != nil {
-			if err := handleMessage(bot, update.Message); err != nil {
-				log.Printf("Ошибка при обработке сообщения: %v", err)
+	// Тикер отмечает работу цикла, даже если обновлений нет
+	ticker := time.NewTicker(loopTick)
+	defer ticker.Stop()
+
+	for {
+		var update tgbotapi.Update
+		select {
+		case <-ticker.C:
+			checker.Tick()
+			continue
+		case upd, ok := <-updates:
+			if !ok {
+				return
 			}
-		} else if update.CallbackQuery != nil {
-			if err := handleCallback(bot, update.CallbackQuery); err != nil {
-				log.Printf("Ошибка при обработке callback: %v", err)
+			update = upd
+		case rc, ok := <-reactions:
+			if !ok {
+				reactions = nil
+				continue
 			}
+			checker.Tick()
+			b.HandleReactionCount(rc)
+			continue
 		}
+
+		checker.Tick()
+		metrics.QueueDepth.Set(float64(len(updates)))
+
+		// Генерация идет прямо в цикле и может занимать минуты
+		done := checker.Busy()
+		b.HandleUpdate(update)
+		done()
 	}
 }
 
-func handleMessage(bot *tgbotapi.BotAPI, message *tgbotapi.Message) error {
-	log.Printf("[%s] %s", message.From.UserName, message.Text)
+// HandleUpdate обрабатывает одно обновление. Вызывается из Run
+// и напрямую в сценарных проверках.
+func (b *Bot) HandleUpdate(update tgbotapi.Update) {
+	// Каждое обновление получает свой идентификатор для сквозного поиска в логах
+	ctx := logging.WithCorrelationID(context.Background())
 
-	// Проверяем, ожидаем ли мы новый запрос от пользователя
-	if waitingForQuery[message.Chat.ID] {
-		// Если мы ожидаем новый запрос, генерируем новый ответ
-		userQuery := message.Text
-		response, err := generateResponse(userQuery)
+	if update.Message != nil {
+		b.trackForward(ctx, update.Message)
+		err := b.handleMessage(ctx, update.Message)
 		if err != nil {
-			log.Printf("Ошибка генерации сообщения: %v", err)
-			return err
-		}
-
-		if response.Response == "" {
-			log.Printf("Проверка наличия ответа %d", message.Chat.ID)
-			return nil
+			slog.ErrorContext(ctx, "Ошибка при обработке сообщения", "err", err)
 		}
-
-		quote, author, err := utils.ExtractQuoteAndAuthor(response.Response)
+		countUpdate("message", err)
+	} else if update.CallbackQuery != nil {
+		err := b.handleCallback(ctx, update.CallbackQuery)
 		if err != nil {
-			log.Printf("Ошибка формата ответа %d: %s. Ошибка: %v", message.Chat.ID, response.Response, err)
-			return err
+			slog.ErrorContext(ctx, "Ошибка при обработке callback", "err", err)
 		}
+		countUpdate("callback", err)
+	} else if update.ChannelPost != nil {
+		// Из постов других каналов нужны только пересылки наших
+		b.trackForward(ctx, update.ChannelPost)
+		countUpdate("channel_post", nil)
+	} else {
+		countUpdate("other", nil)
+	}
+}
 
-		imageFileName, err := generateImage(quote)
-		if err != nil {
-			log.Printf("Ошибка генерации изображения %d: %v", message.Chat.ID, err)
-			return err
-		}
+// countUpdate учитывает обработанное обновление в метриках
+func countUpdate(kind string, err error) {
+	result := "ok"
+	if err != nil {
+		result = "error"
+	}
+	metrics.UpdatesHandled.WithLabelValues(kind, result).Inc()
+}
 
-		if err := sendPost(bot, message.Chat.ID, imageFileName, quote, author); err != nil {
-			log.Printf("Ошибка отправки изображения: %v", err)
-		}
+func (b *Bot) handleMessage(ctx context.Context, message *tgbotapi.Message) error {
+	slog.InfoContext(ctx, "Получено сообщение",
+		"chat_id", message.Chat.ID,
+		"user", message.From.UserName,
+		"text", message.Text,
+	)
 
-		// Сброс состояния ожидания
-		delete(waitingForQuery, message.Chat.ID)
-		return nil
+	if handled, err := b.handlePendingEdit(ctx, message); handled {
+		return err
 	}
 
-	// Если не ожидаем новый запрос, просто обрабатываем обычное сообщение
-	userQuery := message.Text
-	response, err := generateResponse(userQuery)
-	if err != nil {
-		log.Printf("Ошибка генерации сообщения: %v", err)
-		return err
+	if message.IsCommand() && message.Command() == "channel" {
+		return b.handleChannelCommand(message)
 	}
 
-	if response.Response == "" {
-		log.Printf("Проверка наличия ответа %d", message.Chat.ID)
-		return nil
+	if message.IsCommand() && message.Command() == "batch" {
+		return b.handleBatchCommand(ctx, message)
 	}
 
-	quote, author, err := utils.ExtractQuoteAndAuthor(response.Response)
-	if err != nil {
-		log.Printf("Ошибка формата ответа %d: %s. Ошибка: %v", message.Chat.ID, response.Response, err)
+	if message.IsCommand() && message.Command() == "queue" {
+		return b.handleQueueCommand(message)
+	}
+
+	if message.IsCommand() && message.Command() == "posts" {
+		return b.handlePostsCommand(ctx, message)
+	}
+
+	if message.IsCommand() && message.Command() == "topics" {
+		return b.handleTopicsCommand(message)
+	}
+
+	if message.IsCommand() && message.Command() == "stats" {
+		return b.handleStatsCommand(message)
+	}
+
+	if message.IsCommand() && message.Command() == "usage" {
+		return b.handleUsageCommand(message)
+	}
+
+	if isImportQuotesCommand(message) {
+		return b.handleImportQuotes(ctx, message)
+	}
+
+	if spent, budget, exceeded := b.budgetExceeded(userID(message)); exceeded {
+		slog.InfoContext(ctx, "Генерация заблокирована бюджетом", "chat_id", message.Chat.ID, "user_id", userID(message), "spent", spent, "budget", budget)
+		return b.sendBudgetExceeded(message.Chat.ID, spent, budget)
+	}
+
+	if ok, err := b.allowGeneration(ctx, message); !ok {
 		return err
 	}
 
-	imageFileName, err := generateImage(quote)
+	userQuery := message.Text
+	draft, err := b.generatePost(ctx, b.channelFor(message.Chat.ID), message.Chat.ID, userID(message), postRequest{prompt: userQuery, topic: userQuery})
 	if err != nil {
-		log.Printf("Ошибка генерации изображения %d: %v", message.Chat.ID, err)
 		return err
 	}
 
-	if err := sendPost(bot, message.Chat.ID, imageFileName, quote, author); err != nil {
-		log.Printf("Ошибка отправки изображения: %v", err)
+	// Проверяем, ожидаем ли мы новый запрос от пользователя
+	if b.waiting[message.Chat.ID] {
+		// Сброс состояния ожидания
+		delete(b.waiting, message.Chat.ID)
+		return nil
+	}
+
+	if draft.Quote == "" {
+		return nil
 	}
 
 	// Сохранение интеракции
 	interaction := models.PromtReq{
 		ChatID:    message.Chat.ID,
 		UserQuery: userQuery,
-		Quote:     quote,
-		Author:    author,
+		Quote:     draft.Quote,
+		Author:    draft.Author,
+		FileID:    draft.FileID,
 		Timestamp: time.Now(),
 	}
 
 	if err := utils.SaveInteractionToFile(interaction); err != nil {
-		log.Printf(" Ошибка сохранения файла интеракции: %v", err)
+		slog.ErrorContext(ctx, "Ошибка сохранения файла интеракции", "err", err)
 	}
 
 	return nil
 }
 
-func generateResponse(userQuery string) (models.FormattedResponse, error) {
-	response, err := api.GenerateMessage(configs.GlobalConfig.YandexAPIKey, configs.GlobalConfig.CatalogID, userQuery)
+// generatePost генерирует цитату и изображение по запросу и отправляет превью в чат.
+// Пока идет генерация, в чате висит заглушка с текстом по мере его появления;
+// превью с изображением приходит отдельным сообщением, а заглушка удаляется.
+// Расход записывается на чат, пользователя и канал сразу после каждого вызова.
+func (b *Bot) generatePost(ctx context.Context, ch configs.Channel, chatID, userID int64, req postRequest) (models.Draft, error) {
+	who := requester{chatID: chatID, userID: userID, channelID: ch.ID}
+
+	live := b.startLive(chatID, "✍️ Подбираю цитату…")
+
+	q, err := b.pickQuote(ctx, ch, who, live, req)
+	if err != nil || q.quote == "" {
+		return models.Draft{}, err
+	}
+	quote, author := q.quote, q.author
+
+	live.set(fmt.Sprintf("«%s»\n— %s\n\n🎨 Рисую изображение…", quote, author))
+
+	art, err := b.generateImage(ctx, quote)
+	if err != nil {
+		live.set("Не удалось нарисовать изображение, попробуйте еще раз.")
+		slog.ErrorContext(ctx, "Ошибка генерации изображения", "chat_id", chatID, "err", err)
+		return models.Draft{}, err
+	}
+	b.recordImage(ctx, who)
+
+	// Оригинал сохраняется до оформления, чтобы его можно было переиспользовать
+	artFile, err := b.arts.Save(art)
+	if err != nil {
+		slog.ErrorContext(ctx, "Ошибка сохранения изображения", "chat_id", chatID, "err", err)
+	}
+
+	image, err := b.postProcess(ch, art, quote, author)
+	if err != nil {
+		live.set("Не удалось оформить изображение.")
+		slog.ErrorContext(ctx, "Ошибка оформления изображения", "chat_id", chatID, "channel", ch.ID, "err", err)
+		return models.Draft{}, err
+	}
+
+	// Сверяем цитату с корпусом, результат увидит модератор в превью
+	verification := b.corpus.Verify(quote, author)
+
+	draft := models.Draft{
+		ChatID:       chatID,
+		ChannelID:    ch.ID,
+		Quote:        quote,
+		Author:       author,
+		ArtFile:      artFile,
+		Topic:        req.topic,
+		Style:        ch.PostStyle,
+		Original:     q.original,
+		Language:     q.language,
+		Verification: string(verification.Status),
+		CorpusAuthor: verification.Match.Author,
+	}
+
+	draft, err = b.sendPost(draft, image)
+	if err != nil {
+		live.set("Не удалось отправить превью.")
+		return models.Draft{}, err
+	}
+	live.remove()
+	slog.InfoContext(ctx, "Черновик отправлен на превью",
+		"chat_id", chatID,
+		"message_id", draft.MessageID,
+		"channel", draft.ChannelID,
+		"verification", draft.Verification,
+	)
+
+	return draft, nil
+}
+
+// generateQuote запрашивает цитату у модели и разбирает ответ.
+// Пустая цитата без ошибки означает пустой ответ модели.
+func (b *Bot) generateQuote(ctx context.Context, who requester, live *liveMessage, prompt string) (string, string, error) {
+	// Без заглушки ответ не нужен по частям
+	var onText func(string)
+	if live != nil {
+		onText = live.update
+	}
+
+	response, err := b.generateResponse(ctx, prompt, onText)
+	if err != nil {
+		live.set("Не удалось подобрать цитату, попробуйте еще раз.")
+		slog.ErrorContext(ctx, "Ошибка генерации сообщения", "chat_id", who.chatID, "err", err)
+		return "", "", err
+	}
+	b.recordText(ctx, who, response.Usage)
+
+	if response.Response == "" {
+		live.remove()
+		slog.WarnContext(ctx, "Пустой ответ модели", "chat_id", who.chatID)
+		return "", "", nil
+	}
+	slog.DebugContext(ctx, "Ответ модели",
+		"chat_id", who.chatID,
+		"response", response.Response,
+		"total_tokens", response.Usage.TotalTokens,
+		"model_version", response.ModelVersion,
+	)
+
+	quote, author, err := utils.ExtractQuoteAndAuthor(response.Response)
+	if err != nil {
+		metrics.ParseFailures.Inc()
+		live.set("Не удалось разобрать ответ модели, попробуйте еще раз.")
+		slog.ErrorContext(ctx, "Ошибка формата ответа", "chat_id", who.chatID, "response", response.Response, "err", err)
+		return "", "", err
+	}
+
+	// Приводим автора к каноническому написанию
+	return quote, b.authors.Canonical(author), nil
+}
+
+// generateResponse генерирует ответ модели; если генератор поддерживает потоковый
+// режим, onText получает текст по мере появления
+func (b *Bot) generateResponse(ctx context.Context, userQuery string, onText func(text string)) (models.FormattedResponse, error) {
+	if streamer, ok := b.text.(TextStreamer); ok {
+		return streamer.GenerateMessageStream(ctx, userQuery, onText)
+	}
+
+	response, err := b.text.GenerateMessage(ctx, userQuery)
 	if err != nil {
 		return models.FormattedResponse{}, err
 	}
 	return response, nil
 }
 
-func generateImage(quote string) (string, error) {
+func (b *Bot) generateImage(ctx context.Context, quote string) ([]byte, error) {
 	// Генерация изображения на основе цитаты
 	seed := time.Now().UnixNano()         // Используем текущее время в качестве сид
 	rng := rand.New(rand.NewSource(seed)) // Создаем новый генератор случайных чисел
@@ -146,85 +441,241 @@ func generateImage(quote string) (string, error) {
 	wArt := rng.Intn(10) + 1 // Случайное число от 1 до 10
 	hArt := rng.Intn(10) + 1 // Случайное число от 1 до 10
 
-	imageFileName, err := api.GenerateArtImage(configs.GlobalConfig.ImageAPIKey, configs.GlobalConfig.CatalogID, quote, seed, wArt, hArt)
+	release, err := b.acquireImageSlot(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	image, err := b.images.GenerateArtImage(ctx, quote, seed, wArt, hArt)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 
-	// Проверка существования файла
-	if _, err := os.Stat(imageFileName); os.IsNotExist(err) {
-		return "", fmt.Errorf("изображение не существует: %s", imageFileName)
+	if len(image) == 0 {
+		return nil, fmt.Errorf("получено пустое изображение")
 	}
 
-	return imageFileName, nil
+	return image, nil
 }
 
-func sendPost(bot *tgbotapi.BotAPI, chatID int64, imageFileName, quote, author string) error {
+// sendPost загружает изображение из памяти один раз и сохраняет черновик
+// с полученным file_id, чтобы публикация в канал не загружала файл повторно
+func (b *Bot) sendPost(draft models.Draft, image []byte) (models.Draft, error) {
+	ch, ok := b.settings.Load().cfg.Channel(draft.ChannelID)
+	if !ok {
+		return draft, fmt.Errorf("канал %s не найден", draft.ChannelID)
+	}
+
 	// Форматируем цитату для отправки
-	formattedQuote = fmt.Sprintf("«%s»\n\n_%s_\n\n[%s](https://t.me/offthepages)", quote, author, "Мысли, сошедшие со страниц")
-	// Открываем файл для отправки
-	file, err := os.Open(imageFileName)
+	formattedQuote, parseMode, err := b.renderCaption(ch, draft.Quote, draft.Original, draft.Author)
 	if err != nil {
-		return fmt.Errorf("ошибка открытия изображения: %v", err)
+		return draft, err
 	}
-	defer file.Close()
 
 	keyboardAfterGenerate := tgbotapi.NewInlineKeyboardMarkup(
 		tgbotapi.NewInlineKeyboardRow(
 			tgbotapi.NewInlineKeyboardButtonData("Сгенерировать еще", "genAgain"),
 			tgbotapi.NewInlineKeyboardButtonData("Отправить в канал", "sendCh"),
 		),
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("В очередь", "queue"),
+		),
 	)
 
 	// Отправка изображения с подписью
-	photoMsg = tgbotapi.NewPhoto(chatID, tgbotapi.FilePath(imageFileName))
-	photoMsg.ParseMode = "Markdown"
-	photoMsg.Caption = formattedQuote            // Устанавливаем отформатированную цитату в качестве подписи
+	photoMsg := tgbotapi.NewPhoto(draft.ChatID, tgbotapi.FileBytes{Name: "art.jpeg", Bytes: image})
 	photoMsg.ReplyMarkup = keyboardAfterGenerate // Добавляем кнопки
 
-	if _, err := bot.Send(photoMsg); err != nil {
-		return fmt.Errorf("ошибка отправки изображения: %v", err)
+	// В превью под подписью показываем результат проверки цитаты
+	preview := formattedQuote + "\n\n" + b.escapeCaption(ch, verificationLine(draft))
+
+	sent, err := b.sendPhoto(photoMsg, preview, parseMode)
+	if err != nil {
+		return draft, fmt.Errorf("ошибка отправки изображения: %v", err)
 	}
 
-	return nil
+	fileID := largestPhotoID(sent.Photo)
+	if fileID == "" {
+		return draft, fmt.Errorf("telegram не вернул file_id изображения")
+	}
+
+	draft.MessageID = sent.MessageID
+	draft.Caption = formattedQuote
+	draft.ParseMode = parseMode
+	draft.FileID = fileID
+	draft.CreatedAt = time.Now()
+
+	metrics.DraftsCreated.WithLabelValues(draft.ChannelID).Inc()
+
+	if err := b.drafts.Save(draft); err != nil {
+		return draft, fmt.Errorf("ошибка сохранения черновика: %v", err)
+	}
+
+	return draft, nil
+}
+
+// largestPhotoID возвращает file_id самого крупного размера фотографии
+func largestPhotoID(sizes []tgbotapi.PhotoSize) string {
+	var fileID string
+	var maxArea int
+	for _, size := range sizes {
+		if area := size.Width * size.Height; area >= maxArea {
+			maxArea = area
+			fileID = size.FileID
+		}
+	}
+	return fileID
 }
 
-func handleCallback(bot *tgbotapi.BotAPI, callback *tgbotapi.CallbackQuery) error {
+func (b *Bot) handleCallback(ctx context.Context, callback *tgbotapi.CallbackQuery) error {
 	cb := callback.Data
 
-	log.Printf("Получен Callback: %s", cb)
+	slog.InfoContext(ctx, "Получен callback", "data", cb, "chat_id", callback.Message.Chat.ID)
+
+	answerText := "Обработка завершена"
 
-	switch cb {
-	case "genAgain":
+	switch {
+	case cb == "genAgain":
 		msg := tgbotapi.NewMessage(callback.Message.Chat.ID, "Пожалуйста, введите запрос для цитаты:")
-		if _, err := bot.Send(msg); err != nil {
-			log.Printf("Ошибка отправки сообщения: %v", err)
+		if _, err := b.tg.Send(msg); err != nil {
+			slog.ErrorContext(ctx, "Ошибка отправки сообщения", "err", err)
 			return err
 		}
 		// Устанавливаем состояние ожидания для текущего чата
-		waitingForQuery[callback.Message.Chat.ID] = true
-	case "sendCh":
-
-		msgtoch := tgbotapi.NewPhotoToChannel("@offthepages", photoMsg.File)
-		msgtoch.ParseMode = "Markdown"
-		msgtoch.Caption = formattedQuote // Устанавливаем отформатированную цитату в качестве подписи
-		if _, err := bot.Send(msgtoch); err != nil {
-			log.Printf("Ошибка отправки изображения в канал: %v", err)
+		b.waiting[callback.Message.Chat.ID] = true
+	case cb == "sendCh":
+		// Публикуем по сохраненному file_id, повторная загрузка не нужна
+		draft, ok := b.drafts.Get(callback.Message.Chat.ID, callback.Message.MessageID)
+		if !ok {
+			return fmt.Errorf("черновик для сообщения %d не найден", callback.Message.MessageID)
+		}
+		if _, err := b.publishDraft(ctx, draft, callback.From.ID); err != nil {
+			return err
+		}
+	case cb == "queue":
+		draft, ok := b.drafts.Get(callback.Message.Chat.ID, callback.Message.MessageID)
+		if !ok {
+			return fmt.Errorf("черновик для сообщения %d не найден", callback.Message.MessageID)
+		}
+		text, err := b.enqueueDraft(draft, callback.From.ID)
+		if err != nil {
+			return err
+		}
+		answerText = text
+	case strings.HasPrefix(cb, publishPrefix), strings.HasPrefix(cb, queueDraftPrefix):
+		// Кнопка под пакетом ссылается на сообщение медиагруппы с черновиком
+		prefix, enqueue := publishPrefix, strings.HasPrefix(cb, queueDraftPrefix)
+		if enqueue {
+			prefix = queueDraftPrefix
+		}
+		messageID, err := strconv.Atoi(strings.TrimPrefix(cb, prefix))
+		if err != nil {
+			return fmt.Errorf("некорректные данные кнопки %q", cb)
+		}
+		draft, ok := b.drafts.Get(callback.Message.Chat.ID, messageID)
+		if !ok {
+			return fmt.Errorf("черновик для сообщения %d не найден", messageID)
+		}
+		if enqueue {
+			if answerText, err = b.enqueueDraft(draft, callback.From.ID); err != nil {
+				return err
+			}
+			break
+		}
+		if _, err := b.publishDraft(ctx, draft, callback.From.ID); err != nil {
+			return err
+		}
+		answerText = "Опубликовано в " + draft.ChannelID
+	case isQueueControl(cb):
+		text, err := b.handleQueueCallback(ctx, callback)
+		if err != nil {
+			return err
+		}
+		answerText = text
+	case strings.HasPrefix(cb, topicsGenPrefix):
+		text, err := b.handleTopicsCallback(ctx, callback)
+		if err != nil {
+			return err
+		}
+		answerText = text
+	case strings.HasPrefix(cb, postPrefix):
+		text, err := b.handlePostCallback(ctx, callback)
+		if err != nil {
 			return err
 		}
+		answerText = text
 	}
 
 	// Ответ на callback_query
 	answer := tgbotapi.CallbackConfig{
 		CallbackQueryID: callback.ID,
-		Text:            "Обработка завершена",
+		Text:            answerText,
 		ShowAlert:       false,
 	}
 
-	if _, err := bot.Request(answer); err != nil {
-		log.Printf("Ошибка ответа на callback_query: %v", err)
+	if _, err := b.tg.Request(answer); err != nil {
+		slog.ErrorContext(ctx, "Ошибка ответа на callback_query", "err", err)
 		return err
 	}
 
 	return nil
 }
+
+// publishDraft публикует черновик в его канал по сохраненному file_id,
+// записывает пост в реестр и возвращает сообщение в канале
+func (b *Bot) publishDraft(ctx context.Context, draft models.Draft, publishedBy int64) (tgbotapi.Message, error) {
+	channelID := draft.ChannelID
+	if channelID == "" {
+		channelID = b.settings.Load().cfg.Channels[0].ID
+	}
+
+	// Черновики, созданные до перехода на MarkdownV2, размечены старым Markdown
+	parseMode := draft.ParseMode
+	if parseMode == "" {
+		parseMode = tgbotapi.ModeMarkdown
+	}
+
+	msgtoch := channelPhoto(channelID, tgbotapi.FileID(draft.FileID))
+	sent, captionID, err := b.sendPhotoPost(msgtoch, draft.Caption, parseMode)
+	if errors.Is(err, errPostTextNotSent) {
+		// Фото уже в канале: повтор публикации продублировал бы его,
+		// поэтому пост регистрируется, а текст редактор добавит сам
+		slog.ErrorContext(ctx, "Фото опубликовано без текста", "channel", channelID, "message_id", sent.MessageID, "err", err)
+		b.notify(draft.ChatID, fmt.Sprintf("Фото опубликовано в %s, но текст поста отправить не удалось: %v. Добавьте текст вручную.", channelID, err))
+	} else if err != nil {
+		slog.ErrorContext(ctx, "Ошибка отправки изображения в канал", "channel", channelID, "err", err)
+		return sent, err
+	}
+	metrics.PostsPublished.WithLabelValues(channelID).Inc()
+
+	post, err := b.posts.Add(models.Post{
+		ChannelID:        channelID,
+		MessageID:        sent.MessageID,
+		CaptionMessageID: captionID,
+		Quote:            draft.Quote,
+		Author:           draft.Author,
+		Caption:          draft.Caption,
+		ParseMode:        parseMode,
+		FileID:           draft.FileID,
+		ArtFile:          draft.ArtFile,
+		Topic:            draft.Topic,
+		Style:            draft.Style,
+		Original:         draft.Original,
+		Language:         draft.Language,
+		DraftChatID:      draft.ChatID,
+		PublishedBy:      publishedBy,
+		PublishedAt:      time.Now(),
+	})
+	if err != nil {
+		// Пост уже в канале, поэтому публикация считается успешной
+		slog.ErrorContext(ctx, "Ошибка сохранения реестра постов", "channel", channelID, "message_id", sent.MessageID, "err", err)
+	}
+	slog.InfoContext(ctx, "Пост опубликован",
+		"channel", channelID,
+		"message_id", sent.MessageID,
+		"post_id", post.ID,
+		"draft_message_id", draft.MessageID,
+	)
+	return sent, nil
+}
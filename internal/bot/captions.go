@@ -0,0 +1,142 @@
+package bot
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/d1mk9/tgChanPost/configs"
+	"github.com/d1mk9/tgChanPost/internal/caption"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// sampleCaption данные пробной подписи: заполнены все поля, чтобы
+// выполнились и условные части шаблона
+var sampleCaption = caption.Data{
+	Quote:     "Дорогу осилит идущий",
+	Original:  "Via ambulando fit",
+	Author:    "Сенека",
+	Signature: "Канал",
+	Link:      "https://t.me/channel",
+}
+
+// buildCaptions разбирает шаблоны подписей каналов и проверяет их пробной
+// подписью: опечатка в имени поля обнаруживается только при подстановке
+func buildCaptions(channels []configs.Channel) (map[string]*caption.Renderer, error) {
+	captions := make(map[string]*caption.Renderer)
+	for _, ch := range channels {
+		mode := caption.Mode(ch.ParseMode)
+
+		text := ch.Template
+		if text == "" {
+			text = defaultTemplate(mode, ch.PostStyle)
+		}
+
+		renderer, err := caption.New(mode, text)
+		if err != nil {
+			return nil, fmt.Errorf("канал %s: %w", ch.ID, err)
+		}
+		if _, err := renderer.Render(sampleCaption); err != nil {
+			return nil, fmt.Errorf("канал %s: %w", ch.ID, err)
+		}
+		captions[ch.ID] = renderer
+	}
+	return captions, nil
+}
+
+// defaultTemplate возвращает шаблон подписи по умолчанию; при наложении
+// текста на изображение в подписи остается только ссылка на канал
+func defaultTemplate(mode caption.Mode, postStyle string) string {
+	overlay := postStyle == configs.PostStyleOverlay
+	switch {
+	case mode == caption.ModeHTML && overlay:
+		return caption.SignatureHTMLTemplate
+	case mode == caption.ModeHTML:
+		return caption.DefaultHTMLTemplate
+	case overlay:
+		return caption.SignatureMarkdownV2Template
+	default:
+		return caption.DefaultMarkdownV2Template
+	}
+}
+
+// renderCaption формирует подпись к посту по шаблону канала; original -
+// цитата на языке оригинала, если quote - ее перевод
+func (b *Bot) renderCaption(ch configs.Channel, quote, original, author string) (string, string, error) {
+	renderer, ok := b.settings.Load().captions[ch.ID]
+	if !ok {
+		return "", "", fmt.Errorf("шаблон подписи для канала %s не найден", ch.ID)
+	}
+
+	text, err := renderer.Render(caption.Data{
+		Quote:     quote,
+		Original:  original,
+		Author:    author,
+		Signature: ch.Signature,
+		Link:      ch.Link,
+		Open:      languageOf(ch).OpenQuote,
+		Close:     languageOf(ch).CloseQuote,
+	})
+	if err != nil {
+		return "", "", err
+	}
+
+	return text, renderer.ParseMode(), nil
+}
+
+// escapeCaption экранирует произвольный текст для разметки канала
+func (b *Bot) escapeCaption(ch configs.Channel, text string) string {
+	if renderer, ok := b.settings.Load().captions[ch.ID]; ok {
+		return renderer.Escape(text)
+	}
+	return text
+}
+
+// sendPhoto отправляет фото с подписью. Если подпись не помещается
+// в ограничение Telegram, фото отправляется без подписи, а текст
+// следует за ним отдельным сообщением. Текст, который не помещается и в сообщение,
+// не отправляется вовсе. Возвращается сообщение с фото.
+func (b *Bot) sendPhoto(photo tgbotapi.PhotoConfig, text, parseMode string) (tgbotapi.Message, error) {
+	sent, _, err := b.sendPhotoPost(photo, text, parseMode)
+	return sent, err
+}
+
+// errPostTextNotSent фото уже отправлено, а отдельное сообщение с текстом - нет.
+// Повторная отправка продублирует фото, поэтому такой пост считается отправленным.
+var errPostTextNotSent = errors.New("ошибка отправки текста поста")
+
+// sendPhotoPost работает как sendPhoto и дополнительно возвращает ID
+// отдельного сообщения с текстом; 0, если текст поместился в подпись
+func (b *Bot) sendPhotoPost(photo tgbotapi.PhotoConfig, text, parseMode string) (tgbotapi.Message, int, error) {
+	if caption.Fits(caption.Mode(parseMode), text) {
+		photo.Caption = text
+		photo.ParseMode = parseMode
+		sent, err := b.tg.Send(photo)
+		return sent, 0, err
+	}
+	// Проверяем до отправки фото, чтобы пост не остался без текста
+	if !caption.FitsMessage(caption.Mode(parseMode), text) {
+		return tgbotapi.Message{}, 0, fmt.Errorf("текст поста длиннее %d символов (%d), сократите шаблон подписи",
+			caption.MaxMessageLength, caption.Length(caption.Mode(parseMode), text))
+	}
+
+	sent, err := b.tg.Send(photo)
+	if err != nil {
+		return sent, 0, err
+	}
+
+	msg := tgbotapi.MessageConfig{
+		BaseChat: tgbotapi.BaseChat{
+			ChatID:          photo.ChatID,
+			ChannelUsername: photo.ChannelUsername,
+		},
+		Text:      text,
+		ParseMode: parseMode,
+	}
+	textMsg, err := b.tg.Send(msg)
+	if err != nil {
+		return sent, 0, fmt.Errorf("%w: %v", errPostTextNotSent, err)
+	}
+
+	return sent, textMsg.MessageID, nil
+}
@@ -0,0 +1,198 @@
+package bot
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/d1mk9/tgChanPost/configs"
+	"github.com/d1mk9/tgChanPost/internal/imaging"
+	"github.com/d1mk9/tgChanPost/internal/lang"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// imageLayers слои, накладываемые на изображение канала
+type imageLayers struct {
+	overlay   *imaging.Overlay
+	watermark *imaging.Watermark
+}
+
+// buildImaging загружает шрифты и логотипы для оформления изображений каналов
+func buildImaging(channels []configs.Channel) (map[string]imageLayers, error) {
+	layersByChannel := make(map[string]imageLayers)
+	for _, ch := range channels {
+		var layers imageLayers
+
+		if ch.PostStyle == configs.PostStyleOverlay {
+			overlay, err := newOverlay(ch.Overlay, languageOf(ch))
+			if err != nil {
+				return nil, fmt.Errorf("канал %s: %w", ch.ID, err)
+			}
+			layers.overlay = overlay
+		}
+
+		if ch.Watermark != nil {
+			watermark, err := newWatermark(ch.Watermark)
+			if err != nil {
+				return nil, fmt.Errorf("канал %s: %w", ch.ID, err)
+			}
+			layers.watermark = watermark
+		}
+
+		if layers.overlay != nil || layers.watermark != nil {
+			layersByChannel[ch.ID] = layers
+		}
+	}
+	return layersByChannel, nil
+}
+
+func newOverlay(cfg *configs.OverlayConfig, language lang.Language) (*imaging.Overlay, error) {
+	darkness := configs.DefaultDarkness
+	if cfg.Darkness != nil {
+		darkness = *cfg.Darkness
+	}
+
+	return imaging.NewOverlay(imaging.OverlayOptions{
+		QuoteFontFile:  cfg.QuoteFontFile,
+		AuthorFontFile: cfg.AuthorFontFile,
+		FontScale:      cfg.FontScale,
+		Position:       imaging.Position(cfg.Position),
+		Darkness:       darkness,
+		OpenQuote:      language.OpenQuote,
+		CloseQuote:     language.CloseQuote,
+	})
+}
+
+func newWatermark(cfg *configs.WatermarkConfig) (*imaging.Watermark, error) {
+	opacity := configs.DefaultWatermarkOpacity
+	if cfg.Opacity != nil {
+		opacity = *cfg.Opacity
+	}
+	margin := configs.DefaultWatermarkMargin
+	if cfg.Margin != nil {
+		margin = *cfg.Margin
+	}
+
+	return imaging.NewWatermark(imaging.WatermarkOptions{
+		ImageFile: cfg.ImageFile,
+		Text:      cfg.Text,
+		FontFile:  cfg.FontFile,
+		Scale:     cfg.Scale,
+		Opacity:   opacity,
+		Corner:    imaging.Corner(cfg.Corner),
+		Margin:    margin,
+	})
+}
+
+// channelFor возвращает канал, выбранный в чате, или канал по умолчанию
+func (b *Bot) channelFor(chatID int64) configs.Channel {
+	cfg := b.settings.Load().cfg
+	if id, ok := b.activeChannels[chatID]; ok {
+		if ch, ok := cfg.Channel(id); ok {
+			return ch
+		}
+	}
+	return cfg.Channels[0]
+}
+
+// commandChannel возвращает канал из аргумента команды или выбранный в чате.
+// Если канал не найден, сообщает об этом в чат и возвращает false.
+func (b *Bot) commandChannel(message *tgbotapi.Message) (configs.Channel, bool) {
+	id := strings.TrimSpace(message.CommandArguments())
+	if id == "" {
+		return b.channelFor(message.Chat.ID), true
+	}
+	ch, ok := b.settings.Load().cfg.Channel(id)
+	if !ok {
+		b.notify(message.Chat.ID, fmt.Sprintf("Канал %s не найден", id))
+	}
+	return ch, ok
+}
+
+// handleChannelCommand обрабатывает /channel: без аргумента показывает
+// список каналов, с аргументом выбирает канал для новых черновиков
+func (b *Bot) handleChannelCommand(message *tgbotapi.Message) error {
+	id := strings.TrimSpace(message.CommandArguments())
+	cfg := b.settings.Load().cfg
+
+	var text string
+	if id == "" {
+		current := b.channelFor(message.Chat.ID)
+		var sb strings.Builder
+		sb.WriteString("Доступные каналы:\n")
+		for _, ch := range cfg.Channels {
+			mark := " "
+			if ch.ID == current.ID {
+				mark = "•"
+			}
+			fmt.Fprintf(&sb, "%s %s (%s)\n", mark, ch.ID, ch.PostStyle)
+		}
+		sb.WriteString("\nВыбрать канал: /channel <id>")
+		text = sb.String()
+	} else if _, ok := cfg.Channel(id); ok {
+		b.activeChannels[message.Chat.ID] = id
+		text = fmt.Sprintf("Новые черновики будут готовиться для канала %s", id)
+	} else {
+		text = fmt.Sprintf("Канал %s не найден", id)
+	}
+
+	if _, err := b.tg.Send(tgbotapi.NewMessage(message.Chat.ID, text)); err != nil {
+		return fmt.Errorf("ошибка отправки сообщения: %v", err)
+	}
+	return nil
+}
+
+// postProcess оформляет изображение в соответствии с настройками канала:
+// накладывает цитату и водяной знак. Оригинал не изменяется.
+func (b *Bot) postProcess(ch configs.Channel, art []byte, quote, author string) ([]byte, error) {
+	layers, ok := b.settings.Load().imaging[ch.ID]
+	if !ok {
+		return art, nil
+	}
+
+	canvas, err := imaging.Decode(art)
+	if err != nil {
+		return nil, err
+	}
+
+	if layers.overlay != nil {
+		if err := layers.overlay.Draw(canvas, quote, author); err != nil {
+			return nil, fmt.Errorf("ошибка наложения текста: %v", err)
+		}
+	}
+
+	if layers.watermark != nil {
+		if err := layers.watermark.Draw(canvas); err != nil {
+			return nil, fmt.Errorf("ошибка наложения водяного знака: %v", err)
+		}
+	}
+
+	return imaging.EncodeJPEG(canvas)
+}
+
+// channelPhoto создает сообщение с фото для канала по @username или числовому ID
+func channelPhoto(channelID string, file tgbotapi.RequestFileData) tgbotapi.PhotoConfig {
+	if id, err := strconv.ParseInt(channelID, 10, 64); err == nil {
+		return tgbotapi.NewPhoto(id, file)
+	}
+	return tgbotapi.NewPhotoToChannel(channelID, file)
+}
+
+// channelChat разбирает ID канала: числовой ID или @username
+func channelChat(channelID string) (int64, string) {
+	if id, err := strconv.ParseInt(channelID, 10, 64); err == nil {
+		return id, ""
+	}
+	return 0, channelID
+}
+
+// chatChannelIDs возвращает ID, под которыми чат мог быть указан в настройках:
+// @username для публичного канала и числовой ID
+func chatChannelIDs(chat *tgbotapi.Chat) []string {
+	ids := []string{strconv.FormatInt(chat.ID, 10)}
+	if chat.UserName != "" {
+		ids = append(ids, "@"+strings.TrimPrefix(chat.UserName, "@"))
+	}
+	return ids
+}
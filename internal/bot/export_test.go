@@ -0,0 +1,8 @@
+package bot
+
+import "time"
+
+// AutofillQueue дает сценарным тестам проверить автозаполнение без ожидания тикера
+func (b *Bot) AutofillQueue(now time.Time, tried map[string]time.Time) {
+	b.autofillQueue(now, tried)
+}
@@ -0,0 +1,105 @@
+package bot
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+
+	"github.com/d1mk9/tgChanPost/configs"
+	"github.com/d1mk9/tgChanPost/internal/lang"
+	"github.com/d1mk9/tgChanPost/internal/utils"
+)
+
+// maxQuoteAttempts сколько раз подбирать цитату, если ответ модели отклонен:
+// автор на перерыве или язык не совпадает с языком канала
+const maxQuoteAttempts = 3
+
+// quoteResult цитата, прошедшая проверки
+type quoteResult struct {
+	quote    string
+	author   string
+	original string // Цитата на языке оригинала, если quote - перевод
+	language string // Язык оригинала
+}
+
+// languageOf возвращает язык канала
+func languageOf(ch configs.Channel) lang.Language {
+	if l, ok := lang.Get(ch.Language); ok {
+		return l
+	}
+	l, _ := lang.Get(lang.Default)
+	return l
+}
+
+// pickQuote подбирает цитату для канала. Ответ модели отклоняется и запрашивается
+// заново, если автор на перерыве или цитата не на языке канала, а перевод
+// для канала не включен.
+func (b *Bot) pickQuote(ctx context.Context, ch configs.Channel, who requester, live *liveMessage, req postRequest) (quoteResult, error) {
+	language := languageOf(ch)
+	prompt := fmt.Sprintf(language.Query, req.prompt)
+	if ch.ShowOriginal {
+		prompt = fmt.Sprintf(language.Original, req.prompt)
+	}
+
+	var reason string
+	for attempt := 1; attempt <= maxQuoteAttempts; attempt++ {
+		quote, author, err := b.generateQuote(ctx, who, live, prompt)
+		if err != nil || quote == "" {
+			return quoteResult{}, err
+		}
+
+		q := quoteResult{quote: quote, author: author}
+		if avoided(author, req.avoid) {
+			// Модель может не учесть запрет из запроса
+			reason = "автор на перерыве: " + author
+		} else if q, reason, err = b.localize(ctx, who, ch, language, q); err != nil {
+			live.set("Не удалось перевести цитату, попробуйте еще раз.")
+			return quoteResult{}, err
+		}
+		if reason == "" {
+			return q, nil
+		}
+		slog.InfoContext(ctx, "Цитата отклонена, подбираю другую",
+			"chat_id", who.chatID,
+			"channel", ch.ID,
+			"reason", reason,
+			"attempt", attempt,
+		)
+	}
+
+	live.set("Не удалось подобрать подходящую цитату, попробуйте еще раз.")
+	return quoteResult{}, fmt.Errorf("цитата отклонена после %d попыток: %s", maxQuoteAttempts, reason)
+}
+
+// localize сверяет язык цитаты с языком канала и при включенном оригинале
+// переводит цитату. Непустая причина означает, что цитату нужно подобрать заново.
+// Если язык определить не удалось, цитата принимается.
+func (b *Bot) localize(ctx context.Context, who requester, ch configs.Channel, language lang.Language, q quoteResult) (quoteResult, string, error) {
+	got, ok := lang.Detect(q.quote)
+	if !ok || got == language.Code {
+		return q, "", nil
+	}
+	if !ch.ShowOriginal {
+		return q, fmt.Sprintf("ответ на языке %s вместо %s", got, language.Code), nil
+	}
+
+	response, err := b.generateResponse(ctx, fmt.Sprintf(language.Translate, "«"+q.quote+"»"), nil)
+	if err != nil {
+		return q, "", fmt.Errorf("ошибка перевода цитаты: %w", err)
+	}
+	b.recordText(ctx, who, response.Usage)
+
+	// Модель может добавить к переводу автора
+	translated, _, err := utils.ExtractQuoteAndAuthor(response.Response)
+	if err != nil {
+		translated = utils.CleanQuote(response.Response)
+	}
+	if translated == "" {
+		return q, "пустой перевод", nil
+	}
+	if tl, ok := lang.Detect(translated); ok && tl != language.Code {
+		return q, fmt.Sprintf("перевод на языке %s вместо %s", tl, language.Code), nil
+	}
+
+	return quoteResult{quote: translated, author: q.author, original: q.quote, language: got}, "", nil
+}
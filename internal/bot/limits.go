@@ -0,0 +1,107 @@
+package bot
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/d1mk9/tgChanPost/configs"
+	"github.com/d1mk9/tgChanPost/internal/metrics"
+	"github.com/d1mk9/tgChanPost/internal/ratelimit"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// imageQueueTimeout сколько генерация ждет свободного места для YandexART
+const imageQueueTimeout = 2 * time.Minute
+
+// accessDenied ответ пользователю, которому действие недоступно
+const accessDenied = "Нет доступа"
+
+// isAdmin сообщает, что у пользователя роль admin: только администраторы
+// меняют общие данные каналов. Отказ записывается в журнал с ID пользователя,
+// чтобы его было проще добавить в limits.admins.
+func (b *Bot) isAdmin(ctx context.Context, userID int64, action string) bool {
+	if b.settings.Load().cfg.Limits.RoleOf(userID) == configs.RoleAdmin {
+		return true
+	}
+	slog.WarnContext(ctx, "Действие доступно только администраторам", "user_id", userID, "action", action)
+	return false
+}
+
+// allowGeneration проверяет ограничения частоты пользователя и чата по его роли.
+// Если лимит исчерпан, пользователю отправляется сообщение с временем ожидания.
+func (b *Bot) allowGeneration(ctx context.Context, message *tgbotapi.Message) (bool, error) {
+	return b.allowGenerations(ctx, message, 1)
+}
+
+// allowGenerations работает как allowGeneration, но списывает n запросов сразу,
+// например за пакет из n черновиков. Если n больше, чем лимит пропускает подряд,
+// пользователю предлагается уменьшить пакет.
+func (b *Bot) allowGenerations(ctx context.Context, message *tgbotapi.Message, n int) (bool, error) {
+	limits := b.settings.Load().cfg.Limits
+	user := userID(message)
+	role := limits.RoleOf(user)
+	roleLimits := limits.For(role)
+	reservations := []ratelimit.Reservation{
+		{Key: "user:" + strconv.FormatInt(user, 10), Limit: ratelimit.Limit(roleLimits.User)},
+		{Key: "chat:" + strconv.FormatInt(message.Chat.ID, 10), Limit: ratelimit.Limit(roleLimits.Chat)},
+	}
+
+	for _, r := range reservations {
+		if r.Limit.Unlimited() || n <= r.Limit.Capacity() {
+			continue
+		}
+		text := fmt.Sprintf("Столько вариантов сразу нельзя: лимит — %d запросов подряд. Уменьшите количество.", r.Limit.Capacity())
+		if _, err := b.tg.Send(tgbotapi.NewMessage(message.Chat.ID, text)); err != nil {
+			return false, fmt.Errorf("ошибка отправки сообщения: %v", err)
+		}
+		return false, nil
+	}
+
+	ok, key, retryAfter := b.limiter.AllowN(time.Now(), n, reservations...)
+	if ok {
+		return true, nil
+	}
+
+	scope, _, _ := strings.Cut(key, ":")
+	metrics.RateLimited.WithLabelValues(scope).Inc()
+	slog.InfoContext(ctx, "Запрос отклонен ограничением частоты",
+		"chat_id", message.Chat.ID,
+		"user_id", user,
+		"role", role,
+		"scope", scope,
+		"retry_after", retryAfter,
+	)
+
+	seconds := int(math.Ceil(retryAfter.Seconds()))
+	text := fmt.Sprintf("Слишком много запросов. Попробуйте снова через %d с.", seconds)
+	if scope == "chat" {
+		text = fmt.Sprintf("В этом чате слишком много запросов. Попробуйте снова через %d с.", seconds)
+	}
+	if _, err := b.tg.Send(tgbotapi.NewMessage(message.Chat.ID, text)); err != nil {
+		return false, fmt.Errorf("ошибка отправки сообщения: %v", err)
+	}
+	return false, nil
+}
+
+// acquireImageSlot ждет свободного места для генерации изображения.
+// Возвращает функцию, освобождающую место.
+func (b *Bot) acquireImageSlot(ctx context.Context) (func(), error) {
+	ctx, cancel := context.WithTimeout(ctx, imageQueueTimeout)
+	defer cancel()
+
+	if err := b.imageSlots.Acquire(ctx); err != nil {
+		return nil, fmt.Errorf("нет свободного места для генерации изображения: %w", err)
+	}
+	metrics.ImagesInFlight.Inc()
+
+	return func() {
+		metrics.ImagesInFlight.Dec()
+		b.imageSlots.Release()
+	}, nil
+}
@@ -0,0 +1,69 @@
+package bot
+
+import (
+	"log/slog"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+const (
+	// liveEditInterval минимальный интервал между правками сообщения:
+	// Telegram ограничивает частоту правок примерно одной в секунду на чат
+	liveEditInterval = 1500 * time.Millisecond
+	maxMessageLength = 4096 // Максимальная длина текста сообщения Telegram
+)
+
+// liveMessage сообщение-заглушка, которое показывает ход генерации
+// и удаляется, когда готово превью с изображением. Методы безопасны для nil:
+// если заглушку не удалось отправить, генерация продолжается без нее.
+type liveMessage struct {
+	tg        Messenger
+	chatID    int64
+	messageID int
+	text      string
+	lastEdit  time.Time
+}
+
+// startLive отправляет заглушку с начальным текстом
+func (b *Bot) startLive(chatID int64, text string) *liveMessage {
+	sent, err := b.tg.Send(tgbotapi.NewMessage(chatID, text))
+	if err != nil {
+		slog.Warn("Ошибка отправки заглушки", "chat_id", chatID, "err", err)
+		return nil
+	}
+	return &liveMessage{tg: b.tg, chatID: chatID, messageID: sent.MessageID, text: text, lastEdit: time.Now()}
+}
+
+// update меняет текст не чаще liveEditInterval; промежуточные варианты пропускаются
+func (m *liveMessage) update(text string) {
+	if m == nil || time.Since(m.lastEdit) < liveEditInterval {
+		return
+	}
+	m.set(text)
+}
+
+// set меняет текст сразу
+func (m *liveMessage) set(text string) {
+	if m == nil || text == m.text || text == "" {
+		return
+	}
+	text = truncateRunes(text, maxMessageLength)
+
+	m.lastEdit = time.Now()
+	if _, err := m.tg.Send(tgbotapi.NewEditMessageText(m.chatID, m.messageID, text)); err != nil {
+		slog.Warn("Ошибка обновления заглушки", "chat_id", m.chatID, "message_id", m.messageID, "err", err)
+		return
+	}
+	m.text = text
+}
+
+// remove удаляет заглушку
+func (m *liveMessage) remove() {
+	if m == nil {
+		return
+	}
+	if _, err := m.tg.Request(tgbotapi.NewDeleteMessage(m.chatID, m.messageID)); err != nil {
+		slog.Warn("Ошибка удаления заглушки", "chat_id", m.chatID, "message_id", m.messageID, "err", err)
+	}
+}
@@ -0,0 +1,492 @@
+package bot
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/d1mk9/tgChanPost/configs"
+	"github.com/d1mk9/tgChanPost/internal/caption"
+	"github.com/d1mk9/tgChanPost/internal/models"
+	"github.com/d1mk9/tgChanPost/internal/utils"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// postPrefix префикс кнопок карточки поста: post:<действие>:<ID поста>
+const postPrefix = "post:"
+
+// Действия с опубликованным постом
+const (
+	postOpen      = "open"  // Показать карточку поста
+	postCaption   = "cap"   // Заменить текст цитаты
+	postImage     = "img"   // Заменить изображение
+	postPin       = "pin"   // Закрепить или открепить
+	postDelete    = "del"   // Запросить подтверждение удаления
+	postDeleteYes = "delok" // Удалить из канала
+)
+
+// pendingEdit правка поста, ожидающая следующего сообщения редактора
+type pendingEdit struct {
+	postID int64
+	action string // postCaption или postImage
+}
+
+// editKey ключ ожидаемой правки: в групповом чате правку завершает
+// только тот, кто ее начал
+type editKey struct {
+	chatID int64
+	userID int64
+}
+
+// handlePostsCommand обрабатывает /posts [канал]: открывает карточку
+// последнего опубликованного поста с навигацией и действиями. Посты
+// меняются прямо в канале, поэтому карточки доступны только администраторам.
+func (b *Bot) handlePostsCommand(ctx context.Context, message *tgbotapi.Message) error {
+	if !b.isAdmin(ctx, userID(message), "posts") {
+		b.notify(message.Chat.ID, accessDenied)
+		return nil
+	}
+
+	ch, ok := b.commandChannel(message)
+	if !ok {
+		return nil
+	}
+
+	posts := b.posts.List(ch.ID)
+	if len(posts) == 0 {
+		b.notify(message.Chat.ID, fmt.Sprintf("В %s еще нет опубликованных постов", ch.ID))
+		return nil
+	}
+
+	text, markup := b.renderPost(posts, 0)
+	msg := tgbotapi.NewMessage(message.Chat.ID, text)
+	msg.ReplyMarkup = markup
+	msg.DisableWebPagePreview = true
+	if _, err := b.tg.Send(msg); err != nil {
+		return fmt.Errorf("ошибка отправки сообщения: %v", err)
+	}
+	return nil
+}
+
+// renderPost формирует карточку поста posts[i] и клавиатуру
+func (b *Bot) renderPost(posts []models.Post, i int) (string, tgbotapi.InlineKeyboardMarkup) {
+	p := posts[i]
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "Пост %d из %d в %s\n", i+1, len(posts), p.ChannelID)
+	fmt.Fprintf(&sb, "Опубликован %s", p.PublishedAt.Format(queueTimeLayout))
+	if !p.EditedAt.IsZero() {
+		fmt.Fprintf(&sb, ", изменен %s", p.EditedAt.Format(queueTimeLayout))
+	}
+	if p.Pinned {
+		sb.WriteString("\n📌 Закреплен")
+	}
+	if n := p.Stats.TotalReactions(); n > 0 || p.Stats.Forwards > 0 {
+		fmt.Fprintf(&sb, "\nРеакций %d, пересылок %d", n, p.Stats.Forwards)
+	}
+	fmt.Fprintf(&sb, "\n\n«%s» — %s", p.Quote, p.Author)
+	if link := postLink(p); link != "" {
+		sb.WriteString("\n\n" + link)
+	}
+
+	data := func(action string, id int64) string {
+		return postPrefix + action + ":" + strconv.FormatInt(id, 10)
+	}
+
+	var nav []tgbotapi.InlineKeyboardButton
+	if i > 0 {
+		nav = append(nav, tgbotapi.NewInlineKeyboardButtonData("◀️ Новее", data(postOpen, posts[i-1].ID)))
+	}
+	if i < len(posts)-1 {
+		nav = append(nav, tgbotapi.NewInlineKeyboardButtonData("Старее ▶️", data(postOpen, posts[i+1].ID)))
+	}
+
+	pin := "📌 Закрепить"
+	if p.Pinned {
+		pin = "📌 Открепить"
+	}
+
+	rows := [][]tgbotapi.InlineKeyboardButton{
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("✏️ Текст", data(postCaption, p.ID)),
+			tgbotapi.NewInlineKeyboardButtonData("🖼 Изображение", data(postImage, p.ID)),
+		),
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData(pin, data(postPin, p.ID)),
+			tgbotapi.NewInlineKeyboardButtonData("🗑 Удалить", data(postDelete, p.ID)),
+		),
+	}
+	if len(nav) > 0 {
+		rows = append(rows, nav)
+	}
+	return sb.String(), tgbotapi.NewInlineKeyboardMarkup(rows...)
+}
+
+// postLink ссылка на пост в публичном канале
+func postLink(p models.Post) string {
+	if !strings.HasPrefix(p.ChannelID, "@") {
+		return ""
+	}
+	return fmt.Sprintf("https://t.me/%s/%d", strings.TrimPrefix(p.ChannelID, "@"), p.MessageID)
+}
+
+// handlePostCallback обрабатывает кнопки карточки поста и возвращает текст ответа на нажатие
+func (b *Bot) handlePostCallback(ctx context.Context, callback *tgbotapi.CallbackQuery) (string, error) {
+	if !b.isAdmin(ctx, callback.From.ID, "posts") {
+		return accessDenied, nil
+	}
+
+	action, rawID, _ := strings.Cut(strings.TrimPrefix(callback.Data, postPrefix), ":")
+	id, err := strconv.ParseInt(rawID, 10, 64)
+	if err != nil {
+		return "", fmt.Errorf("некорректные данные кнопки %q", callback.Data)
+	}
+	post, ok := b.posts.Get(id)
+	if !ok || post.Deleted {
+		return "Пост не найден или удален", nil
+	}
+
+	chatID := callback.Message.Chat.ID
+	key := editKey{chatID: chatID, userID: callback.From.ID}
+	answer := ""
+	switch action {
+	case postOpen:
+	case postCaption:
+		b.editing[key] = pendingEdit{postID: id, action: postCaption}
+		b.notify(chatID, "Отправьте новый текст в формате «цитата» — автор. Любая команда отменит правку.")
+		return "Жду новый текст", nil
+	case postImage:
+		b.editing[key] = pendingEdit{postID: id, action: postImage}
+		b.notify(chatID, "Отправьте новое изображение. Любая команда отменит правку.")
+		return "Жду изображение", nil
+	case postPin:
+		if post, err = b.togglePin(post); err != nil {
+			return "", err
+		}
+		answer = "Пост закреплен"
+		if !post.Pinned {
+			answer = "Пост откреплен"
+		}
+	case postDelete:
+		// Удаление необратимо, поэтому карточка сначала просит подтверждения
+		markup := tgbotapi.NewInlineKeyboardMarkup(tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("Да, удалить из канала", postPrefix+postDeleteYes+":"+rawID),
+			tgbotapi.NewInlineKeyboardButtonData("Отмена", postPrefix+postOpen+":"+rawID),
+		))
+		edit := tgbotapi.NewEditMessageReplyMarkup(chatID, callback.Message.MessageID, markup)
+		if _, err := b.tg.Send(edit); err != nil {
+			return "", fmt.Errorf("ошибка обновления карточки: %v", err)
+		}
+		return "Подтвердите удаление", nil
+	case postDeleteYes:
+		if err := b.deletePost(ctx, post); err != nil {
+			return "", err
+		}
+		answer = "Пост удален из канала"
+	default:
+		return "", fmt.Errorf("неизвестное действие с постом %q", action)
+	}
+
+	b.refreshPostCard(chatID, callback.Message.MessageID, post)
+	return answer, nil
+}
+
+// refreshPostCard перерисовывает карточку на месте: после удаления
+// показывает соседний пост или сообщает, что постов не осталось
+func (b *Bot) refreshPostCard(chatID int64, messageID int, post models.Post) {
+	posts := b.posts.List(post.ChannelID)
+	// Список отсортирован от новых к старым: берем сам пост или следующий за удаленным
+	i := len(posts) - 1
+	for j, p := range posts {
+		if p.ID <= post.ID {
+			i = j
+			break
+		}
+	}
+
+	var edit tgbotapi.EditMessageTextConfig
+	if len(posts) == 0 {
+		edit = tgbotapi.NewEditMessageText(chatID, messageID, fmt.Sprintf("В %s не осталось опубликованных постов", post.ChannelID))
+	} else {
+		text, markup := b.renderPost(posts, i)
+		edit = tgbotapi.NewEditMessageTextAndMarkup(chatID, messageID, text, markup)
+	}
+	edit.DisableWebPagePreview = true
+	if _, err := b.tg.Send(edit); err != nil {
+		slog.Warn("Ошибка обновления карточки поста", "chat_id", chatID, "err", err)
+	}
+}
+
+// handlePendingEdit применяет правку поста из сообщения редактора.
+// Возвращает false, если правки не ожидалось.
+func (b *Bot) handlePendingEdit(ctx context.Context, message *tgbotapi.Message) (bool, error) {
+	key := editKey{chatID: message.Chat.ID, userID: userID(message)}
+	pending, ok := b.editing[key]
+	if !ok {
+		return false, nil
+	}
+	delete(b.editing, key)
+	if message.IsCommand() {
+		// Команда отменяет правку и обрабатывается как обычно
+		return false, nil
+	}
+	// Права могли отобрать при перезагрузке настроек, пока правка ждала сообщения
+	if !b.isAdmin(ctx, key.userID, "posts") {
+		b.notify(message.Chat.ID, accessDenied)
+		return true, nil
+	}
+
+	post, ok := b.posts.Get(pending.postID)
+	if !ok || post.Deleted {
+		b.notify(message.Chat.ID, "Пост не найден или удален")
+		return true, nil
+	}
+
+	var err error
+	switch pending.action {
+	case postCaption:
+		quote, author, parseErr := utils.ExtractQuoteAndAuthor(message.Text)
+		if parseErr != nil {
+			b.editing[key] = pending
+			b.notify(message.Chat.ID, "Не удалось разобрать текст. Ожидается «цитата» — автор.")
+			return true, nil
+		}
+		err = b.editPostCaption(ctx, post, quote, b.authors.Canonical(author))
+	case postImage:
+		fileID := largestPhotoID(message.Photo)
+		if fileID == "" {
+			b.editing[key] = pending
+			b.notify(message.Chat.ID, "Ожидается изображение. Отправьте фото или команду для отмены.")
+			return true, nil
+		}
+		err = b.replacePostImage(ctx, post, fileID)
+	}
+	if errors.Is(err, errCaptionTooLong) {
+		// Ошибка редактора, а не Telegram: ждем исправленный текст
+		b.editing[key] = pending
+		b.notify(message.Chat.ID, err.Error()+". Отправьте текст короче или команду для отмены.")
+		return true, nil
+	}
+	if err != nil {
+		b.notify(message.Chat.ID, "Не удалось изменить пост: "+err.Error())
+		return true, err
+	}
+
+	b.notify(message.Chat.ID, "Пост изменен")
+	return true, nil
+}
+
+// errCaptionTooLong новый текст поста не помещается в ограничение Telegram
+var errCaptionTooLong = errors.New("текст не помещается в пост")
+
+// editPostCaption перерисовывает подпись по шаблону канала и меняет ее в канале.
+// Если цитата наложена на изображение, оно оформляется заново из сохраненного оригинала.
+func (b *Bot) editPostCaption(ctx context.Context, post models.Post, quote, author string) error {
+	ch, ok := b.settings.Load().cfg.Channel(post.ChannelID)
+	if !ok {
+		return fmt.Errorf("канал %s не найден", post.ChannelID)
+	}
+	// Новый текст задает редактор, поэтому оригинал перевода больше не относится к нему
+	text, parseMode, err := b.renderCaption(ch, quote, "", author)
+	if err != nil {
+		return err
+	}
+
+	// Подпись к фото и отдельное сообщение ограничены по-разному,
+	// а переносить текст между ними при правке Telegram не позволяет
+	limit, fits := caption.MaxLength, caption.Fits(caption.Mode(parseMode), text)
+	if post.CaptionMessageID != 0 {
+		limit, fits = caption.MaxMessageLength, caption.FitsMessage(caption.Mode(parseMode), text)
+	}
+	if !fits {
+		return fmt.Errorf("%w: %d символов при ограничении %d", errCaptionTooLong,
+			caption.Length(caption.Mode(parseMode), text), limit)
+	}
+
+	fileID := post.FileID
+	overlay := ch.PostStyle == configs.PostStyleOverlay
+	if overlay {
+		// Иначе на изображении останется прежняя цитата
+		if post.ArtFile == "" {
+			return errors.New("исходное изображение поста не сохранено, замените изображение целиком")
+		}
+		art, err := b.arts.Load(post.ArtFile)
+		if err != nil {
+			return err
+		}
+		image, err := b.postProcess(ch, art, quote, author)
+		if err != nil {
+			return fmt.Errorf("ошибка оформления изображения: %v", err)
+		}
+		// Подпись к фото меняется вместе с изображением
+		if fileID, err = b.editPostMedia(ctx, post, image, text, parseMode); err != nil {
+			return err
+		}
+	}
+
+	chatID, username := channelChat(post.ChannelID)
+	if post.CaptionMessageID != 0 {
+		// Текст поста отдельным сообщением: правится оно, а не подпись к фото
+		edit := tgbotapi.EditMessageTextConfig{
+			BaseEdit:  tgbotapi.BaseEdit{ChatID: chatID, ChannelUsername: username, MessageID: post.CaptionMessageID},
+			Text:      text,
+			ParseMode: parseMode,
+		}
+		if _, err := b.tg.Request(edit); err != nil {
+			return fmt.Errorf("ошибка изменения текста поста: %v", err)
+		}
+	} else if !overlay {
+		edit := tgbotapi.EditMessageCaptionConfig{
+			BaseEdit:  tgbotapi.BaseEdit{ChatID: chatID, ChannelUsername: username, MessageID: post.MessageID},
+			Caption:   text,
+			ParseMode: parseMode,
+		}
+		if _, err := b.tg.Request(edit); err != nil {
+			return fmt.Errorf("ошибка изменения подписи: %v", err)
+		}
+	}
+
+	_, err = b.posts.Update(post.ID, func(p *models.Post) {
+		p.Quote, p.Author, p.Caption, p.ParseMode = quote, author, text, parseMode
+		p.Original, p.Language = "", ""
+		p.FileID = fileID
+		p.EditedAt = time.Now()
+	})
+	slog.InfoContext(ctx, "Подпись поста изменена", "channel", post.ChannelID, "message_id", post.MessageID, "post_id", post.ID)
+	return err
+}
+
+// replacePostImage заменяет изображение поста, сохраняя подпись. Новое
+// изображение оформляется так же, как сгенерированное: с наложением
+// цитаты и водяным знаком канала.
+func (b *Bot) replacePostImage(ctx context.Context, post models.Post, fileID string) error {
+	ch, ok := b.settings.Load().cfg.Channel(post.ChannelID)
+	if !ok {
+		return fmt.Errorf("канал %s не найден", post.ChannelID)
+	}
+
+	art, err := b.downloadFile(fileID)
+	if err != nil {
+		return err
+	}
+	artFile, err := b.arts.Save(art)
+	if err != nil {
+		slog.ErrorContext(ctx, "Ошибка сохранения изображения", "channel", ch.ID, "post_id", post.ID, "err", err)
+	}
+	image, err := b.postProcess(ch, art, post.Quote, post.Author)
+	if err != nil {
+		return fmt.Errorf("ошибка оформления изображения: %v", err)
+	}
+
+	newFileID, err := b.editPostMedia(ctx, post, image, post.Caption, post.ParseMode)
+	if err != nil {
+		return err
+	}
+
+	_, err = b.posts.Update(post.ID, func(p *models.Post) {
+		p.FileID = newFileID
+		p.ArtFile = artFile
+		p.EditedAt = time.Now()
+	})
+	slog.InfoContext(ctx, "Изображение поста заменено", "channel", post.ChannelID, "message_id", post.MessageID, "post_id", post.ID)
+	return err
+}
+
+// editPostMedia заменяет фото поста оформленным изображением и возвращает
+// его file_id. Подпись к фото задается заново, если текст не вынесен в
+// отдельное сообщение. Если file_id из ответа получить не удалось,
+// возвращается прежний, чтобы пост можно было опубликовать повторно.
+func (b *Bot) editPostMedia(ctx context.Context, post models.Post, image []byte, text, parseMode string) (string, error) {
+	photo := tgbotapi.NewInputMediaPhoto(tgbotapi.FileBytes{Name: "art.jpeg", Bytes: image})
+	if post.CaptionMessageID == 0 {
+		photo.Caption = text
+		photo.ParseMode = parseMode
+	}
+
+	chatID, username := channelChat(post.ChannelID)
+	edit := tgbotapi.EditMessageMediaConfig{
+		BaseEdit: tgbotapi.BaseEdit{ChatID: chatID, ChannelUsername: username, MessageID: post.MessageID},
+		Media:    photo,
+	}
+	resp, err := b.tg.Request(edit)
+	if err != nil {
+		return "", fmt.Errorf("ошибка замены изображения: %v", err)
+	}
+
+	// Telegram возвращает измененное сообщение с file_id оформленного фото
+	var edited tgbotapi.Message
+	if err := json.Unmarshal(resp.Result, &edited); err != nil {
+		slog.WarnContext(ctx, "Не удалось разобрать ответ на замену изображения", "post_id", post.ID, "err", err)
+		return post.FileID, nil
+	}
+	fileID := largestPhotoID(edited.Photo)
+	if fileID == "" {
+		slog.WarnContext(ctx, "В ответе на замену изображения нет file_id", "post_id", post.ID)
+		return post.FileID, nil
+	}
+	return fileID, nil
+}
+
+// togglePin закрепляет пост в канале или снимает закрепление
+func (b *Bot) togglePin(post models.Post) (models.Post, error) {
+	chatID, username := channelChat(post.ChannelID)
+
+	var req tgbotapi.Chattable = tgbotapi.PinChatMessageConfig{
+		ChatID:              chatID,
+		ChannelUsername:     username,
+		MessageID:           post.MessageID,
+		DisableNotification: true,
+	}
+	if post.Pinned {
+		req = tgbotapi.UnpinChatMessageConfig{ChatID: chatID, ChannelUsername: username, MessageID: post.MessageID}
+	}
+	if _, err := b.tg.Request(req); err != nil {
+		return post, fmt.Errorf("ошибка закрепления поста: %v", err)
+	}
+
+	return b.posts.Update(post.ID, func(p *models.Post) { p.Pinned = !p.Pinned })
+}
+
+// deletePost удаляет пост из канала вместе с отдельным текстом и отмечает это в реестре.
+// Каждая удаленная часть сразу отмечается в реестре, а уже удаленное сообщение
+// считается успехом, поэтому после частичного сбоя удаление можно повторить.
+func (b *Bot) deletePost(ctx context.Context, post models.Post) error {
+	chatID, username := channelChat(post.ChannelID)
+	deleteMessage := func(messageID int) error {
+		del := tgbotapi.DeleteMessageConfig{ChatID: chatID, ChannelUsername: username, MessageID: messageID}
+		if _, err := b.tg.Request(del); err != nil && !messageGone(err) {
+			return fmt.Errorf("ошибка удаления поста: %v", err)
+		}
+		return nil
+	}
+
+	if post.CaptionMessageID != 0 {
+		if err := deleteMessage(post.CaptionMessageID); err != nil {
+			return err
+		}
+		if _, err := b.posts.Update(post.ID, func(p *models.Post) { p.CaptionMessageID = 0 }); err != nil {
+			return err
+		}
+	}
+	if err := deleteMessage(post.MessageID); err != nil {
+		return err
+	}
+
+	if _, err := b.posts.Update(post.ID, func(p *models.Post) { p.Deleted = true }); err != nil {
+		return err
+	}
+	slog.InfoContext(ctx, "Пост удален из канала", "channel", post.ChannelID, "message_id", post.MessageID, "post_id", post.ID)
+	return nil
+}
+
+// messageGone сообщает, что Telegram не нашел сообщение для удаления:
+// его уже удалили, например вручную или предыдущей попыткой
+func messageGone(err error) bool {
+	return strings.Contains(err.Error(), "message to delete not found")
+}
@@ -0,0 +1,284 @@
+package bot
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/d1mk9/tgChanPost/configs"
+	"github.com/d1mk9/tgChanPost/internal/logging"
+	"github.com/d1mk9/tgChanPost/internal/models"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+const (
+	publishTick        = 30 * time.Second // Период проверки очереди на наступившие слоты
+	maxPublishAttempts = 3                // Попыток публикации до статуса failed
+	queueTimeLayout    = "02.01 15:04"
+)
+
+// Префиксы данных кнопок очереди
+const (
+	queueDraftPrefix = "q:"     // Поставить в очередь черновик из пакета по ID сообщения
+	queueUpPrefix    = "qup:"   // Поднять элемент очереди
+	queueDownPrefix  = "qdown:" // Опустить элемент очереди
+	queueDelPrefix   = "qdel:"  // Удалить элемент очереди
+)
+
+// enqueueDraft ставит черновик в очередь его канала на ближайший свободный слот
+// и возвращает текст для редактора
+func (b *Bot) enqueueDraft(draft models.Draft, queuedBy int64) (string, error) {
+	ch, ok := b.settings.Load().cfg.Channel(draft.ChannelID)
+	if !ok {
+		return "", fmt.Errorf("канал %s не найден", draft.ChannelID)
+	}
+	if len(ch.Slots) == 0 {
+		return fmt.Sprintf("Для канала %s не настроены слоты публикации", ch.ID), nil
+	}
+	if item, ok := b.queue.FindDraft(draft.ChatID, draft.MessageID); ok {
+		return "Уже в очереди на " + formatPublishAt(item.PublishAt), nil
+	}
+
+	item, err := b.queue.Add(models.QueueItem{Draft: draft, QueuedAt: time.Now(), QueuedBy: queuedBy})
+	if err != nil {
+		return "", fmt.Errorf("ошибка сохранения очереди: %v", err)
+	}
+	if err := b.reschedule(ch, nil); err != nil {
+		return "", err
+	}
+
+	item, _ = b.queue.Get(item.ID)
+	slog.Info("Черновик поставлен в очередь",
+		"channel", ch.ID,
+		"queue_id", item.ID,
+		"publish_at", item.PublishAt,
+		"draft_message_id", draft.MessageID,
+	)
+	return fmt.Sprintf("В очереди %s на %s", ch.ID, formatPublishAt(item.PublishAt)), nil
+}
+
+// formatPublishAt показывает время публикации элемента очереди
+func formatPublishAt(t time.Time) string {
+	if t.IsZero() {
+		return "без слота"
+	}
+	return t.Format(queueTimeLayout)
+}
+
+// upcoming возвращает элементы канала, время которых еще не наступило.
+// Наступившие ждут публикатора и в перестановках не участвуют.
+func (b *Bot) upcoming(channelID string, now time.Time) []models.QueueItem {
+	var items []models.QueueItem
+	for _, item := range b.queue.Queued(channelID) {
+		if item.PublishAt.After(now) || item.PublishAt.IsZero() {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// reschedule раскладывает ожидающие элементы канала по ближайшим слотам.
+// order задает новый порядок ID; nil - сохранить текущий.
+func (b *Bot) reschedule(ch configs.Channel, order []int64) error {
+	now := time.Now()
+	if order == nil {
+		for _, item := range b.upcoming(ch.ID, now) {
+			order = append(order, item.ID)
+		}
+	}
+	times := ch.SlotTimes(now, len(order))
+	if len(ch.Slots) == 0 {
+		// Слоты убраны из настроек: элементы ждут, пока их не настроят снова
+		times = make([]time.Time, len(order))
+	}
+	if err := b.queue.Schedule(order, times); err != nil {
+		return fmt.Errorf("ошибка сохранения очереди: %v", err)
+	}
+	return nil
+}
+
+// moveQueued сдвигает элемент на одну позицию вверх (delta = -1) или вниз (delta = 1)
+func (b *Bot) moveQueued(ch configs.Channel, id int64, delta int) error {
+	items := b.upcoming(ch.ID, time.Now())
+	order := make([]int64, len(items))
+	pos := -1
+	for i, item := range items {
+		order[i] = item.ID
+		if item.ID == id {
+			pos = i
+		}
+	}
+	if pos < 0 {
+		return nil
+	}
+	next := pos + delta
+	if next < 0 || next >= len(order) {
+		return nil
+	}
+	order[pos], order[next] = order[next], order[pos]
+	return b.reschedule(ch, order)
+}
+
+// handleQueueCommand обрабатывает /queue [канал]: показывает очередь канала
+// с кнопками перестановки и удаления
+func (b *Bot) handleQueueCommand(message *tgbotapi.Message) error {
+	ch, ok := b.commandChannel(message)
+	if !ok {
+		return nil
+	}
+
+	text, markup := b.renderQueue(ch)
+	msg := tgbotapi.NewMessage(message.Chat.ID, text)
+	if markup != nil {
+		msg.ReplyMarkup = *markup
+	}
+	if _, err := b.tg.Send(msg); err != nil {
+		return fmt.Errorf("ошибка отправки сообщения: %v", err)
+	}
+	return nil
+}
+
+// renderQueue формирует список очереди канала и клавиатуру управления
+func (b *Bot) renderQueue(ch configs.Channel) (string, *tgbotapi.InlineKeyboardMarkup) {
+	items := b.queue.Queued(ch.ID)
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "Очередь %s", ch.ID)
+	if len(ch.Slots) > 0 {
+		fmt.Fprintf(&sb, " (слоты: %s)", strings.Join(ch.Slots, ", "))
+	}
+	if len(items) == 0 {
+		sb.WriteString("\n\nОчередь пуста.")
+		return sb.String(), nil
+	}
+	sb.WriteString(":\n")
+
+	var rows [][]tgbotapi.InlineKeyboardButton
+	for i, item := range items {
+		fmt.Fprintf(&sb, "\n%d. %s — «%s» — %s", i+1, formatPublishAt(item.PublishAt), truncateRunes(item.Draft.Quote, 60), item.Draft.Author)
+		id := strconv.FormatInt(item.ID, 10)
+		rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData(fmt.Sprintf("%d ⬆️", i+1), queueUpPrefix+id),
+			tgbotapi.NewInlineKeyboardButtonData(fmt.Sprintf("%d ⬇️", i+1), queueDownPrefix+id),
+			tgbotapi.NewInlineKeyboardButtonData(fmt.Sprintf("%d ✖️", i+1), queueDelPrefix+id),
+		))
+	}
+
+	markup := tgbotapi.NewInlineKeyboardMarkup(rows...)
+	return sb.String(), &markup
+}
+
+// handleQueueCallback обрабатывает кнопки управления очередью и обновляет
+// сообщение со списком. Возвращает текст ответа на нажатие. Очередь
+// общая для канала, поэтому менять ее могут только администраторы.
+func (b *Bot) handleQueueCallback(ctx context.Context, callback *tgbotapi.CallbackQuery) (string, error) {
+	if !b.isAdmin(ctx, callback.From.ID, "queue") {
+		return accessDenied, nil
+	}
+
+	data := callback.Data
+	var prefix string
+	for _, p := range []string{queueUpPrefix, queueDownPrefix, queueDelPrefix} {
+		if strings.HasPrefix(data, p) {
+			prefix = p
+		}
+	}
+
+	id, err := strconv.ParseInt(strings.TrimPrefix(data, prefix), 10, 64)
+	if err != nil {
+		return "", fmt.Errorf("некорректные данные кнопки %q", data)
+	}
+	item, ok := b.queue.Get(id)
+	if !ok || item.Status != models.QueueQueued {
+		return "Элемент уже опубликован или удален", nil
+	}
+	ch, ok := b.settings.Load().cfg.Channel(item.Draft.ChannelID)
+	if !ok {
+		return "", fmt.Errorf("канал %s не найден", item.Draft.ChannelID)
+	}
+
+	answer := "Очередь обновлена"
+	switch prefix {
+	case queueUpPrefix:
+		err = b.moveQueued(ch, id, -1)
+	case queueDownPrefix:
+		err = b.moveQueued(ch, id, 1)
+	case queueDelPrefix:
+		if _, err = b.queue.Remove(id); err == nil {
+			err = b.reschedule(ch, nil)
+			answer = "Удалено из очереди"
+		}
+	}
+	if err != nil {
+		return "", err
+	}
+
+	text, markup := b.renderQueue(ch)
+	edit := tgbotapi.NewEditMessageText(callback.Message.Chat.ID, callback.Message.MessageID, text)
+	edit.ReplyMarkup = markup
+	if _, err := b.tg.Send(edit); err != nil {
+		slog.Warn("Ошибка обновления списка очереди", "chat_id", callback.Message.Chat.ID, "err", err)
+	}
+	return answer, nil
+}
+
+// isQueueControl сообщает, относится ли кнопка к управлению очередью
+func isQueueControl(data string) bool {
+	return strings.HasPrefix(data, queueUpPrefix) || strings.HasPrefix(data, queueDownPrefix) || strings.HasPrefix(data, queueDelPrefix)
+}
+
+// runPublisher публикует элементы очереди, время которых наступило,
+// до закрытия stop
+func (b *Bot) runPublisher(stop <-chan struct{}) {
+	ticker := time.NewTicker(publishTick)
+	defer ticker.Stop()
+
+	for {
+		b.publishDue(time.Now())
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// publishDue публикует наступившие элементы очереди и сообщает редактору результат
+func (b *Bot) publishDue(now time.Time) {
+	for _, item := range b.queue.Due(now) {
+		ctx := logging.WithCorrelationID(context.Background())
+		sent, err := b.publishDraft(ctx, item.Draft, item.QueuedBy)
+		if err != nil {
+			failed, markErr := b.queue.MarkAttemptFailed(item.ID, err, maxPublishAttempts)
+			if markErr != nil {
+				slog.ErrorContext(ctx, "Ошибка сохранения очереди", "queue_id", item.ID, "err", markErr)
+			}
+			if failed {
+				b.notify(item.Draft.ChatID, fmt.Sprintf("Не удалось опубликовать в %s по расписанию: %v", item.Draft.ChannelID, err))
+			}
+			continue
+		}
+
+		if err := b.queue.MarkPublished(item.ID, sent.MessageID, time.Now()); err != nil {
+			slog.ErrorContext(ctx, "Ошибка сохранения очереди", "queue_id", item.ID, "err", err)
+		}
+		slog.InfoContext(ctx, "Опубликован элемент очереди",
+			"queue_id", item.ID,
+			"channel", item.Draft.ChannelID,
+			"message_id", sent.MessageID,
+			"slot", item.PublishAt,
+		)
+		b.notify(item.Draft.ChatID, fmt.Sprintf("Опубликовано в %s по расписанию: «%s»", item.Draft.ChannelID, truncateRunes(item.Draft.Quote, 60)))
+	}
+}
+
+// notify отправляет служебное сообщение редактору; ошибка только логируется
+func (b *Bot) notify(chatID int64, text string) {
+	if _, err := b.tg.Send(tgbotapi.NewMessage(chatID, text)); err != nil {
+		slog.Warn("Ошибка отправки уведомления", "chat_id", chatID, "err", err)
+	}
+}
@@ -0,0 +1,96 @@
+package bot
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/d1mk9/tgChanPost/internal/models"
+	"github.com/d1mk9/tgChanPost/internal/quotes"
+	"github.com/d1mk9/tgChanPost/internal/telegram"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+const importQuotesCommand = "/importquotes"
+
+// maxImportSize ограничение на размер импортируемого файла
+const maxImportSize = telegram.MaxDownloadSize
+
+// verificationLine описывает результат проверки цитаты для модератора
+func verificationLine(draft models.Draft) string {
+	switch quotes.Status(draft.Verification) {
+	case quotes.StatusVerified:
+		return "✅ Цитата подтверждена корпусом"
+	case quotes.StatusAuthorMismatch:
+		return fmt.Sprintf("⚠️ Автор не совпадает: в корпусе — %s", draft.CorpusAuthor)
+	default:
+		return "❔ Цитата не найдена в корпусе"
+	}
+}
+
+// isImportQuotesCommand проверяет, что сообщение - команда импорта цитат:
+// файл с подписью /importquotes или ответ этой командой на сообщение с файлом
+func isImportQuotesCommand(message *tgbotapi.Message) bool {
+	if message.Document != nil {
+		return strings.HasPrefix(message.Caption, importQuotesCommand)
+	}
+	return message.IsCommand() && "/"+message.Command() == importQuotesCommand
+}
+
+// handleImportQuotes загружает JSON или CSV с цитатами и добавляет их в корпус.
+// Корпус общий для всех каналов, поэтому импорт доступен только администраторам.
+func (b *Bot) handleImportQuotes(ctx context.Context, message *tgbotapi.Message) error {
+	if !b.isAdmin(ctx, userID(message), "importquotes") {
+		b.notify(message.Chat.ID, accessDenied)
+		return nil
+	}
+
+	doc := message.Document
+	if doc == nil && message.ReplyToMessage != nil {
+		doc = message.ReplyToMessage.Document
+	}
+
+	var text string
+	if doc == nil {
+		text = "Отправьте JSON или CSV файл с подписью /importquotes или ответьте этой командой на сообщение с файлом.\n" +
+			"JSON: [{\"text\": \"...\", \"author\": \"...\", \"source\": \"...\"}]\nCSV: цитата,автор[,источник]"
+	} else if added, err := b.importQuotes(doc); err != nil {
+		text = fmt.Sprintf("Не удалось импортировать цитаты: %v", err)
+	} else {
+		text = fmt.Sprintf("Добавлено цитат: %d. Всего в корпусе: %d", added, b.corpus.Len())
+	}
+
+	if _, err := b.tg.Send(tgbotapi.NewMessage(message.Chat.ID, text)); err != nil {
+		return fmt.Errorf("ошибка отправки сообщения: %v", err)
+	}
+	return nil
+}
+
+func (b *Bot) importQuotes(doc *tgbotapi.Document) (int, error) {
+	if doc.FileSize > maxImportSize {
+		return 0, fmt.Errorf("файл слишком большой: %d байт", doc.FileSize)
+	}
+
+	data, err := b.downloadFile(doc.FileID)
+	if err != nil {
+		return 0, err
+	}
+
+	parsed, err := quotes.Parse(doc.FileName, data)
+	if err != nil {
+		return 0, err
+	}
+
+	return b.corpus.Import(parsed)
+}
+
+// downloadFile скачивает файл, отправленный боту
+func (b *Bot) downloadFile(fileID string) ([]byte, error) {
+	file, err := b.tg.GetFile(tgbotapi.FileConfig{FileID: fileID})
+	if err != nil {
+		return nil, fmt.Errorf("ошибка получения файла: %v", err)
+	}
+
+	return b.tg.Download(file)
+}
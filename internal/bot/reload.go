@@ -0,0 +1,111 @@
+package bot
+
+import (
+	"log/slog"
+	"os"
+	"os/signal"
+	"slices"
+	"syscall"
+	"time"
+
+	"github.com/d1mk9/tgChanPost/configs"
+	"github.com/d1mk9/tgChanPost/internal/caption"
+	"github.com/d1mk9/tgChanPost/internal/topics"
+)
+
+// reloadPoll период проверки файлов конфигурации на изменения
+const reloadPoll = 10 * time.Second
+
+// settings неизменяемый снимок конфигурации с подготовленными шаблонами
+// и оформлением каналов. При перезагрузке снимок заменяется целиком,
+// поэтому обработчики никогда не видят наполовину примененные настройки.
+type settings struct {
+	cfg      configs.Config
+	imaging  map[string]imageLayers
+	captions map[string]*caption.Renderer
+	topics   map[string]*topics.Catalog
+}
+
+// applySettings проверяет шаблоны и оформление каналов и только затем
+// атомарно подменяет действующие настройки
+func (b *Bot) applySettings(cfg configs.Config) error {
+	imaging, err := buildImaging(cfg.Channels)
+	if err != nil {
+		return err
+	}
+
+	captions, err := buildCaptions(cfg.Channels)
+	if err != nil {
+		return err
+	}
+
+	catalogs, err := buildTopics(cfg.Channels)
+	if err != nil {
+		return err
+	}
+
+	b.settings.Store(&settings{cfg: cfg, imaging: imaging, captions: captions, topics: catalogs})
+	b.imageSlots.SetLimit(cfg.Limits.ImageSlots())
+	return nil
+}
+
+// watchConfig перезагружает конфигурацию по SIGHUP и при изменении файлов.
+// Ошибочная конфигурация отклоняется, бот продолжает работать со старой.
+func (b *Bot) watchConfig(stop <-chan struct{}) {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	defer signal.Stop(hup)
+
+	ticker := time.NewTicker(reloadPoll)
+	defer ticker.Stop()
+
+	watcher := configs.NewWatcher(b.settings.Load().cfg.WatchedFiles())
+	for {
+		select {
+		case <-stop:
+			return
+		case <-hup:
+			b.reloadConfig("SIGHUP")
+		case <-ticker.C:
+			if watcher.Changed(b.settings.Load().cfg.WatchedFiles()) {
+				b.reloadConfig("file")
+			}
+		}
+	}
+}
+
+func (b *Bot) reloadConfig(trigger string) {
+	prev := b.settings.Load().cfg
+	next, restartOnly, err := prev.Reload()
+	if err != nil {
+		slog.Error("Новая конфигурация отклонена", "trigger", trigger, "err", err)
+		return
+	}
+
+	if err := b.applySettings(next); err != nil {
+		slog.Error("Новая конфигурация отклонена", "trigger", trigger, "err", err)
+		return
+	}
+	b.rescheduleChangedSlots(prev, next)
+
+	if len(restartOnly) > 0 {
+		slog.Warn("Часть параметров применится только после перезапуска", "params", restartOnly)
+	}
+	slog.Info("Конфигурация перезагружена", "trigger", trigger, "channels", len(next.Channels))
+}
+
+// rescheduleChangedSlots раскладывает очередь каналов, у которых изменились слоты,
+// по новому расписанию
+func (b *Bot) rescheduleChangedSlots(prev, next configs.Config) {
+	for _, ch := range next.Channels {
+		old, ok := prev.Channel(ch.ID)
+		if ok && slices.Equal(old.Slots, ch.Slots) {
+			continue
+		}
+		if err := b.reschedule(ch, nil); err != nil {
+			slog.Error("Ошибка перераспределения очереди", "channel", ch.ID, "err", err)
+			continue
+		}
+		slog.Info("Очередь перераспределена по новым слотам", "channel", ch.ID, "slots", ch.Slots)
+	}
+}
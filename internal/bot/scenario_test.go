@@ -0,0 +1,675 @@
+package bot_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/d1mk9/tgChanPost/configs"
+	"github.com/d1mk9/tgChanPost/internal/authors"
+	"github.com/d1mk9/tgChanPost/internal/bot"
+	"github.com/d1mk9/tgChanPost/internal/metrics"
+	"github.com/d1mk9/tgChanPost/internal/models"
+	"github.com/d1mk9/tgChanPost/internal/quotes"
+	"github.com/d1mk9/tgChanPost/internal/storage"
+	"github.com/d1mk9/tgChanPost/internal/telegram/telegramtest"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	dto "github.com/prometheus/client_model/go"
+)
+
+const (
+	moderatorChat = 100 // Чат и пользователь модератора, администратор бота
+	strangerChat  = 300 // Пользователь без роли admin
+	testChannel   = "@test"
+)
+
+// stubText всегда отвечает одной и той же цитатой
+type stubText struct {
+	answer string
+}
+
+func (s stubText) GenerateMessage(ctx context.Context, userMessage string) (models.FormattedResponse, error) {
+	return models.FormattedResponse{
+		Response: s.answer,
+		Status:   "ALTERNATIVE_STATUS_FINAL",
+		Usage:    models.Usage{InputTokens: 10, CompletionTokens: 20, TotalTokens: 30},
+	}, nil
+}
+
+// stubImages возвращает однотонную картинку
+type stubImages struct{}
+
+func (stubImages) GenerateArtImage(ctx context.Context, prompt string, seed int64, wArt, hArt int) ([]byte, error) {
+	img := image.NewRGBA(image.Rect(0, 0, 64, 64))
+	for x := 0; x < 64; x++ {
+		for y := 0; y < 64; y++ {
+			img.Set(x, y, color.RGBA{R: 40, G: 80, B: 120, A: 255})
+		}
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// scenario бот с Telegram в памяти и заглушками моделей
+type scenario struct {
+	bot    *bot.Bot
+	tg     *telegramtest.Fake
+	drafts *storage.DraftStore
+	queue  *storage.QueueStore
+	posts  *storage.PostStore
+}
+
+func newScenario(t *testing.T) *scenario {
+	t.Helper()
+	return newScenarioWith(t, "")
+}
+
+// newScenarioWith дополняет настройки тестового канала строками YAML
+func newScenarioWith(t *testing.T, channel string) *scenario {
+	t.Helper()
+
+	// Бот пишет историю запросов в рабочий каталог
+	dir := t.TempDir()
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.Chdir(wd) })
+
+	cfg := loadConfig(t, dir, channel)
+
+	registry := authors.NewRegistry(nil)
+	drafts, err := storage.NewDraftStore(filepath.Join(dir, "drafts.json"))
+	check(t, err)
+	arts, err := storage.NewArtStore(filepath.Join(dir, "art"))
+	check(t, err)
+	usage, err := storage.NewUsageStore(filepath.Join(dir, "usage.json"))
+	check(t, err)
+	queue, err := storage.NewQueueStore(filepath.Join(dir, "queue.json"))
+	check(t, err)
+	posts, err := storage.NewPostStore(filepath.Join(dir, "posts.json"))
+	check(t, err)
+	corpus, err := quotes.LoadCorpus(filepath.Join(dir, "quotes.json"), registry)
+	check(t, err)
+
+	s := &scenario{tg: telegramtest.New(), drafts: drafts, queue: queue, posts: posts}
+	s.bot, err = bot.New(cfg, bot.Deps{
+		Telegram: s.tg,
+		Text:     stubText{answer: "«Дорогу осилит идущий» — Сенека"},
+		Images:   stubImages{},
+		Drafts:   drafts,
+		Arts:     arts,
+		Usage:    usage,
+		Queue:    queue,
+		Posts:    posts,
+		Authors:  registry,
+		Corpus:   corpus,
+	})
+	if err != nil {
+		t.Fatalf("бот: %v", err)
+	}
+	return s
+}
+
+// loadConfig сохраняет в dir конфигурацию с тестовым каналом, дополненным
+// строками YAML, и загружает ее
+func loadConfig(t *testing.T, dir, channel string) configs.Config {
+	t.Helper()
+
+	config := filepath.Join(dir, "config.yaml")
+	yaml := "bot_token: test\nyandex_api_key: key\nimage_api_key: key\ncatalog_id: catalog\n" +
+		fmt.Sprintf("limits:\n  admins: [%d]\n", moderatorChat) +
+		"channels:\n  - id: \"" + testChannel + "\"\n    signature: Тест\n" + channel
+	if err := os.WriteFile(config, []byte(yaml), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := configs.Load([]string{"-config", config})
+	if err != nil {
+		t.Fatalf("конфигурация: %v", err)
+	}
+	return cfg
+}
+
+// check прерывает тест при ошибке подготовки
+func check(t *testing.T, err error) {
+	t.Helper()
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+// preview возвращает последнее превью с фото в чате модератора
+func (s *scenario) preview(t *testing.T) tgbotapi.Message {
+	t.Helper()
+	var found *tgbotapi.Message
+	for _, sent := range s.tg.SentTo(moderatorChat, "") {
+		if sent.Message.Photo != nil {
+			msg := sent.Message
+			found = &msg
+		}
+	}
+	if found == nil {
+		t.Fatal("превью с фото не отправлено")
+	}
+	return *found
+}
+
+// counter возвращает значение счетчика обработанных обновлений
+func counter(t *testing.T, kind, result string) float64 {
+	t.Helper()
+	var m dto.Metric
+	if err := metrics.UpdatesHandled.WithLabelValues(kind, result).Write(&m); err != nil {
+		t.Fatal(err)
+	}
+	return m.GetCounter().GetValue()
+}
+
+func TestGeneratePreviewPublish(t *testing.T) {
+	s := newScenario(t)
+
+	s.bot.HandleUpdate(telegramtest.Text(moderatorChat, "стойкость"))
+
+	preview := s.preview(t)
+	fileID := preview.Photo[len(preview.Photo)-1].FileID
+	draft, ok := s.drafts.Get(moderatorChat, preview.MessageID)
+	if !ok {
+		t.Fatalf("черновик для превью %d не сохранен", preview.MessageID)
+	}
+	if draft.FileID != fileID || draft.ChannelID != testChannel {
+		t.Fatalf("черновик: file_id %q, канал %q; ожидалось %q, %q", draft.FileID, draft.ChannelID, fileID, testChannel)
+	}
+	if draft.Quote != "Дорогу осилит идущий" || draft.Author != "Сенека" {
+		t.Fatalf("черновик: «%s» — %s", draft.Quote, draft.Author)
+	}
+
+	s.bot.HandleUpdate(telegramtest.Click(moderatorChat, preview.MessageID, "sendCh"))
+
+	sent := s.tg.SentTo(0, testChannel)
+	if len(sent) != 1 {
+		t.Fatalf("в канал отправлено %d сообщений, ожидалось 1", len(sent))
+	}
+	photo, ok := sent[0].Chattable.(tgbotapi.PhotoConfig)
+	if !ok {
+		t.Fatalf("в канал отправлено %T, ожидалось фото", sent[0].Chattable)
+	}
+	if id, ok := photo.File.(tgbotapi.FileID); !ok || string(id) != fileID {
+		t.Fatalf("в канал отправлен файл %#v, ожидался file_id %q без повторной загрузки", photo.File, fileID)
+	}
+
+	posts := s.posts.List(testChannel)
+	if len(posts) != 1 {
+		t.Fatalf("в реестре %d постов, ожидался 1", len(posts))
+	}
+	if posts[0].MessageID != sent[0].Message.MessageID || posts[0].FileID != fileID {
+		t.Fatalf("пост в реестре: message_id %d, file_id %q", posts[0].MessageID, posts[0].FileID)
+	}
+
+	var answered bool
+	for _, req := range s.tg.Requests() {
+		if _, ok := req.(tgbotapi.CallbackConfig); ok {
+			answered = true
+		}
+	}
+	if !answered {
+		t.Fatal("нет ответа на нажатие кнопки")
+	}
+}
+
+func TestPreviewSendFailure(t *testing.T) {
+	s := newScenario(t)
+	s.tg.FailSends(errors.New("telegram недоступен"))
+
+	before := counter(t, "message", "error")
+	s.bot.HandleUpdate(telegramtest.Text(moderatorChat, "стойкость"))
+
+	if got := counter(t, "message", "error") - before; got != 1 {
+		t.Fatalf("ошибок обработки сообщения %v, ожидалась 1", got)
+	}
+	if len(s.tg.Sent()) != 0 {
+		t.Fatalf("отправлено %d сообщений при недоступном Telegram", len(s.tg.Sent()))
+	}
+}
+
+func TestPublishSendFailure(t *testing.T) {
+	s := newScenario(t)
+
+	s.bot.HandleUpdate(telegramtest.Text(moderatorChat, "стойкость"))
+	preview := s.preview(t)
+
+	s.tg.FailSends(errors.New("telegram недоступен"))
+	before := counter(t, "callback", "error")
+	s.bot.HandleUpdate(telegramtest.Click(moderatorChat, preview.MessageID, "sendCh"))
+
+	if got := counter(t, "callback", "error") - before; got != 1 {
+		t.Fatalf("ошибок обработки нажатия %v, ожидалась 1", got)
+	}
+	if posts := s.posts.List(testChannel); len(posts) != 0 {
+		t.Fatalf("неотправленный пост попал в реестр: %+v", posts)
+	}
+
+	// После восстановления связи тот же черновик публикуется
+	s.tg.FailSends(nil)
+	s.bot.HandleUpdate(telegramtest.Click(moderatorChat, preview.MessageID, "sendCh"))
+	if posts := s.posts.List(testChannel); len(posts) != 1 {
+		t.Fatalf("в реестре %d постов после повторной публикации, ожидался 1", len(posts))
+	}
+}
+
+func TestRunProcessesInjectedUpdates(t *testing.T) {
+	s := newScenario(t)
+
+	done := make(chan struct{})
+	go func() {
+		s.bot.Run()
+		close(done)
+	}()
+
+	// Закрытый канал обновлений отдает накопленные обновления до конца
+	s.tg.Inject(telegramtest.Text(moderatorChat, "стойкость"))
+	s.tg.Close()
+	<-done
+
+	s.preview(t)
+}
+
+func TestBatchSingleCandidateFallsBackToPreview(t *testing.T) {
+	s := newScenario(t)
+
+	// Модель отвечает одной и той же цитатой, после удаления повторов остается один вариант
+	s.bot.HandleUpdate(telegramtest.Text(moderatorChat, "/batch стойкость 3"))
+
+	for _, req := range s.tg.Requests() {
+		if _, ok := req.(tgbotapi.MediaGroupConfig); ok {
+			t.Fatal("медиагруппа из одного фото отправлена")
+		}
+	}
+	preview := s.preview(t)
+	if _, ok := s.drafts.Get(moderatorChat, preview.MessageID); !ok {
+		t.Fatalf("черновик для превью %d не сохранен", preview.MessageID)
+	}
+
+	s.bot.HandleUpdate(telegramtest.Click(moderatorChat, preview.MessageID, "sendCh"))
+	if posts := s.posts.List(testChannel); len(posts) != 1 || posts[0].Topic != "стойкость" {
+		t.Fatalf("ожидался один пост по теме пакета, получено %+v", posts)
+	}
+}
+
+// publish генерирует превью, публикует его и возвращает пост из реестра
+func (s *scenario) publish(t *testing.T) models.Post {
+	t.Helper()
+	s.bot.HandleUpdate(telegramtest.Text(moderatorChat, "стойкость"))
+	s.bot.HandleUpdate(telegramtest.Click(moderatorChat, s.preview(t).MessageID, "sendCh"))
+
+	posts := s.posts.List(testChannel)
+	if len(posts) != 1 {
+		t.Fatalf("в реестре %d постов, ожидался 1", len(posts))
+	}
+	return posts[0]
+}
+
+// postAction нажимает кнопку карточки поста
+func (s *scenario) postAction(post models.Post, action string) {
+	data := fmt.Sprintf("post:%s:%d", action, post.ID)
+	s.bot.HandleUpdate(telegramtest.Click(moderatorChat, 1, data))
+}
+
+func TestEditPostCaptionTooLong(t *testing.T) {
+	s := newScenario(t)
+	post := s.publish(t)
+
+	s.postAction(post, "cap")
+	long := "«" + strings.Repeat("очень длинная цитата ", 60) + "» — Сенека"
+	s.bot.HandleUpdate(telegramtest.Text(moderatorChat, long))
+
+	for _, req := range s.tg.Requests() {
+		if _, ok := req.(tgbotapi.EditMessageCaptionConfig); ok {
+			t.Fatal("подпись длиннее ограничения отправлена в Telegram")
+		}
+	}
+	if got, _ := s.posts.Get(post.ID); got.Quote != post.Quote {
+		t.Fatalf("цитата изменена: %q", got.Quote)
+	}
+
+	// Правка остается активной: следующий текст применяется без повторного нажатия
+	s.bot.HandleUpdate(telegramtest.Text(moderatorChat, "«Тише едешь — дальше будешь» — Сенека"))
+	if got, _ := s.posts.Get(post.ID); got.Quote != "Тише едешь — дальше будешь" {
+		t.Fatalf("после сокращения цитата %q", got.Quote)
+	}
+}
+
+func TestReplacePostImage(t *testing.T) {
+	s := newScenarioWith(t, "    watermark:\n      text: \"@test\"\n")
+	post := s.publish(t)
+
+	upload, err := stubImages{}.GenerateArtImage(context.Background(), "", 0, 0, 0)
+	check(t, err)
+	s.tg.AddFile("upload", upload)
+
+	s.postAction(post, "img")
+	s.bot.HandleUpdate(telegramtest.Photo(moderatorChat, "upload"))
+
+	var edit *tgbotapi.EditMessageMediaConfig
+	for _, req := range s.tg.Requests() {
+		if e, ok := req.(tgbotapi.EditMessageMediaConfig); ok {
+			edit = &e
+		}
+	}
+	if edit == nil {
+		t.Fatal("изображение в канале не заменено")
+	}
+	// Оформленное изображение перекодируется в JPEG, загруженный PNG в канал не уходит
+	file, ok := edit.Media.(tgbotapi.InputMediaPhoto).Media.(tgbotapi.FileBytes)
+	if !ok || !bytes.HasPrefix(file.Bytes, []byte{0xFF, 0xD8}) {
+		t.Fatalf("в канал отправлено %#v, ожидался оформленный JPEG", edit.Media)
+	}
+
+	got, _ := s.posts.Get(post.ID)
+	if got.FileID == "" || got.FileID == "upload" || got.FileID == post.FileID {
+		t.Fatalf("file_id поста %q, ожидался file_id оформленного фото", got.FileID)
+	}
+	if got.ArtFile == "" {
+		t.Fatal("оригинал нового изображения не сохранен")
+	}
+}
+
+func TestDeletePostRetry(t *testing.T) {
+	s := newScenario(t)
+	post, err := s.posts.Add(models.Post{ChannelID: testChannel, MessageID: 10, CaptionMessageID: 11})
+	check(t, err)
+
+	// Текст удален, а фото не удалось удалить из-за сбоя сети
+	s.tg.FailRequests(func(c tgbotapi.Chattable) error {
+		if del, ok := c.(tgbotapi.DeleteMessageConfig); ok && del.MessageID == 10 {
+			return errors.New("connection reset")
+		}
+		return nil
+	})
+	s.postAction(post, "delok")
+	if got, _ := s.posts.Get(post.ID); got.Deleted || got.CaptionMessageID != 0 {
+		t.Fatalf("после частичного удаления: deleted %v, caption_message_id %d", got.Deleted, got.CaptionMessageID)
+	}
+
+	// Повтор удаляет только фото; уже удаленное сообщение не считается ошибкой
+	var deleted []int
+	s.tg.FailRequests(func(c tgbotapi.Chattable) error {
+		if del, ok := c.(tgbotapi.DeleteMessageConfig); ok {
+			deleted = append(deleted, del.MessageID)
+			return errors.New("Bad Request: message to delete not found")
+		}
+		return nil
+	})
+	s.postAction(post, "delok")
+	if got, _ := s.posts.Get(post.ID); !got.Deleted {
+		t.Fatal("пост не отмечен удаленным после повтора")
+	}
+	if len(deleted) != 1 || deleted[0] != 10 {
+		t.Fatalf("при повторе удалялись сообщения %v, ожидалось только фото 10", deleted)
+	}
+}
+
+func TestAutofillQueuesRotationDraft(t *testing.T) {
+	const autofillChat = 200
+	slot := time.Now().Add(time.Hour).Format("15:04")
+	s := newScenarioWith(t, fmt.Sprintf("    slots: [%q]\n    topics:\n      autofill_chat: %d\n      catalog:\n        - name: стойкость\n",
+		slot, autofillChat))
+
+	done := make(chan struct{})
+	go func() {
+		s.bot.Run()
+		close(done)
+	}()
+	defer func() {
+		s.tg.Close()
+		<-done
+	}()
+
+	// Автозаполнение проверяет очередь сразу после запуска
+	deadline := time.Now().Add(5 * time.Second)
+	var items []models.QueueItem
+	for len(items) == 0 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+		items = s.queue.Queued(testChannel)
+	}
+	if len(items) != 1 {
+		t.Fatalf("в очереди %d элементов, ожидался черновик по ротации", len(items))
+	}
+	if item := items[0]; item.Draft.Topic != "стойкость" || item.Draft.ChatID != autofillChat || item.PublishAt.Format("15:04") != slot {
+		t.Fatalf("элемент очереди: тема %q, чат %d, слот %s", item.Draft.Topic, item.Draft.ChatID, item.PublishAt)
+	}
+	if len(s.tg.SentTo(autofillChat, "")) == 0 {
+		t.Fatal("превью не отправлено в чат автозаполнения")
+	}
+}
+
+func TestCaptionTemplateTypoRejected(t *testing.T) {
+	// Шаблон разбирается без ошибок, но поля Quotee в данных подписи нет
+	cfg := loadConfig(t, t.TempDir(), "    caption_template: \"{{.Quotee}}\"\n")
+
+	_, err := bot.New(cfg, bot.Deps{})
+	if err == nil || !strings.Contains(err.Error(), "Quotee") {
+		t.Fatalf("ожидалась ошибка шаблона с полем Quotee, получено %v", err)
+	}
+}
+
+func TestImportQuotesRequiresAdmin(t *testing.T) {
+	s := newScenario(t)
+
+	s.bot.HandleUpdate(telegramtest.Text(strangerChat, "/importquotes"))
+	sent := s.tg.SentTo(strangerChat, "")
+	if len(sent) != 1 || sent[0].Message.Text != "Нет доступа" {
+		t.Fatalf("пользователю без прав отправлено %+v, ожидался отказ", sent)
+	}
+
+	s.bot.HandleUpdate(telegramtest.Text(moderatorChat, "/importquotes"))
+	if sent := s.tg.SentTo(moderatorChat, ""); len(sent) != 1 || !strings.Contains(sent[0].Message.Text, "JSON") {
+		t.Fatalf("администратору отправлено %+v, ожидалась справка по импорту", sent)
+	}
+}
+
+func TestBatchChargesEveryDraft(t *testing.T) {
+	s := newScenario(t)
+
+	// По умолчанию пользователь может сделать 3 запроса подряд
+	s.bot.HandleUpdate(telegramtest.Text(strangerChat, "/batch стойкость 5"))
+	if sent := s.tg.SentTo(strangerChat, ""); len(sent) != 1 || !strings.Contains(sent[0].Message.Text, "лимит — 3") {
+		t.Fatalf("ожидался отказ для пакета больше лимита, отправлено %+v", sent)
+	}
+
+	s.bot.HandleUpdate(telegramtest.Text(strangerChat, "/batch стойкость 3"))
+	before := len(s.tg.SentTo(strangerChat, ""))
+
+	// Пакет из трех вариантов списал все три запроса
+	s.bot.HandleUpdate(telegramtest.Text(strangerChat, "/batch стойкость 2"))
+	sent := s.tg.SentTo(strangerChat, "")
+	if len(sent) != before+1 || !strings.Contains(sent[len(sent)-1].Message.Text, "Слишком много запросов") {
+		t.Fatalf("ожидался отказ ограничением частоты, последние сообщения %+v", sent[before:])
+	}
+}
+
+func TestPublishPhotoWithoutText(t *testing.T) {
+	// Подпись длиннее лимита, текст поста уходит отдельным сообщением
+	s := newScenarioWith(t, fmt.Sprintf("    caption_template: \"{{.Quote}} %s\"\n", strings.Repeat("я", 1100)))
+
+	s.bot.HandleUpdate(telegramtest.Text(moderatorChat, "стойкость"))
+	preview := s.preview(t)
+
+	s.tg.FailSendsIf(func(c tgbotapi.Chattable) error {
+		if msg, ok := c.(tgbotapi.MessageConfig); ok && msg.ChannelUsername == testChannel {
+			return errors.New("telegram недоступен")
+		}
+		return nil
+	})
+	before := counter(t, "callback", "error")
+	s.bot.HandleUpdate(telegramtest.Click(moderatorChat, preview.MessageID, "sendCh"))
+
+	// Фото уже в канале, повторная публикация его бы продублировала
+	if got := counter(t, "callback", "error") - before; got != 0 {
+		t.Fatalf("ошибок обработки нажатия %v, публикация фото без текста должна считаться успешной", got)
+	}
+	sent := s.tg.SentTo(0, testChannel)
+	if len(sent) != 1 {
+		t.Fatalf("в канал отправлено %d сообщений, ожидалось только фото", len(sent))
+	}
+	posts := s.posts.List(testChannel)
+	if len(posts) != 1 || posts[0].MessageID != sent[0].Message.MessageID || posts[0].CaptionMessageID != 0 {
+		t.Fatalf("пост в реестре: %+v", posts)
+	}
+	notes := s.tg.SentTo(moderatorChat, "")
+	if last := notes[len(notes)-1].Message.Text; !strings.Contains(last, "текст поста отправить не удалось") {
+		t.Fatalf("редактор не предупрежден о потерянном тексте: %q", last)
+	}
+}
+
+func TestQueueControlsRequireAdmin(t *testing.T) {
+	s := newScenario(t)
+
+	item, err := s.queue.Add(models.QueueItem{
+		Draft:     models.Draft{ChatID: moderatorChat, ChannelID: testChannel, Quote: "Дорогу осилит идущий"},
+		PublishAt: time.Now().Add(time.Hour),
+		QueuedBy:  moderatorChat,
+		Status:    models.QueueQueued,
+	})
+	check(t, err)
+
+	s.bot.HandleUpdate(telegramtest.Click(strangerChat, 1, fmt.Sprintf("qdel:%d", item.ID)))
+
+	if answer := s.lastAnswer(); answer != "Нет доступа" {
+		t.Fatalf("ответ на нажатие %q, ожидался отказ", answer)
+	}
+	if items := s.queue.Queued(testChannel); len(items) != 1 {
+		t.Fatalf("в очереди %d элементов, пользователь без прав удалил элемент", len(items))
+	}
+}
+
+// lastAnswer возвращает текст последнего ответа на нажатие кнопки
+func (s *scenario) lastAnswer() string {
+	var answer string
+	for _, req := range s.tg.Requests() {
+		if cb, ok := req.(tgbotapi.CallbackConfig); ok {
+			answer = cb.Text
+		}
+	}
+	return answer
+}
+
+func TestPostActionsRequireAdmin(t *testing.T) {
+	s := newScenario(t)
+	post := s.publish(t)
+
+	for _, action := range []string{"pin", "delok", "cap"} {
+		s.bot.HandleUpdate(telegramtest.Click(strangerChat, 1, fmt.Sprintf("post:%s:%d", action, post.ID)))
+		if answer := s.lastAnswer(); answer != "Нет доступа" {
+			t.Fatalf("%s: ответ на нажатие %q, ожидался отказ", action, answer)
+		}
+	}
+	for _, req := range s.tg.Requests() {
+		switch r := req.(type) {
+		case tgbotapi.PinChatMessageConfig:
+			t.Fatal("пользователь без прав закрепил пост")
+		case tgbotapi.DeleteMessageConfig:
+			if r.ChannelUsername == testChannel {
+				t.Fatal("пользователь без прав удалил пост")
+			}
+		}
+	}
+
+	s.bot.HandleUpdate(telegramtest.Text(strangerChat, "/posts"))
+	if sent := s.tg.SentTo(strangerChat, ""); len(sent) == 0 || sent[len(sent)-1].Message.Text != "Нет доступа" {
+		t.Fatalf("пользователю без прав открыта карточка поста: %+v", sent)
+	}
+}
+
+func TestPendingEditBelongsToUser(t *testing.T) {
+	s := newScenario(t)
+	post := s.publish(t)
+
+	// Правку начал администратор, а следующее сообщение в том же чате написал другой участник
+	s.postAction(post, "cap")
+	other := telegramtest.Text(moderatorChat, "«Чужая цитата» — Аноним")
+	other.Message.From.ID = strangerChat
+	s.bot.HandleUpdate(other)
+	if got, _ := s.posts.Get(post.ID); got.Quote != post.Quote {
+		t.Fatalf("правку применил другой пользователь: %q", got.Quote)
+	}
+
+	s.bot.HandleUpdate(telegramtest.Text(moderatorChat, "«Тише едешь — дальше будешь» — Сенека"))
+	if got, _ := s.posts.Get(post.ID); got.Quote != "Тише едешь — дальше будешь" {
+		t.Fatalf("правка администратора не применена: %q", got.Quote)
+	}
+}
+
+func TestEditOverlayPostCaption(t *testing.T) {
+	s := newScenarioWith(t, "    post_style: overlay\n")
+	post := s.publish(t)
+
+	s.postAction(post, "cap")
+	s.bot.HandleUpdate(telegramtest.Text(moderatorChat, "«Тише едешь — дальше будешь» — Сенека"))
+
+	// Цитата нарисована на изображении, поэтому оно перерисовывается вместе с подписью
+	var edits int
+	for _, req := range s.tg.Requests() {
+		switch e := req.(type) {
+		case tgbotapi.EditMessageCaptionConfig:
+			t.Fatal("изменена только подпись, на изображении осталась прежняя цитата")
+		case tgbotapi.EditMessageMediaConfig:
+			edits++
+			if _, ok := e.Media.(tgbotapi.InputMediaPhoto).Media.(tgbotapi.FileBytes); !ok {
+				t.Fatalf("в канал отправлено %#v, ожидалось оформленное изображение", e.Media)
+			}
+		}
+	}
+	if edits != 1 {
+		t.Fatalf("изображение заменялось %d раз, ожидался 1", edits)
+	}
+
+	got, _ := s.posts.Get(post.ID)
+	if got.Quote != "Тише едешь — дальше будешь" || got.FileID == post.FileID || got.FileID == "" {
+		t.Fatalf("пост после правки: цитата %q, file_id %q", got.Quote, got.FileID)
+	}
+}
+
+func TestAutofillOncePerSlot(t *testing.T) {
+	const autofillChat = 200
+	now := time.Now()
+	slot := now.Add(time.Hour).Format("15:04")
+	s := newScenarioWith(t, fmt.Sprintf("    slots: [%q]\n    topics:\n      autofill_chat: %d\n      catalog:\n        - name: стойкость\n",
+		slot, autofillChat))
+
+	// Неудачная попытка не повторяется до следующего слота
+	tried := make(map[string]time.Time)
+	s.tg.FailSends(errors.New("telegram недоступен"))
+	s.bot.AutofillQueue(now, tried)
+	s.tg.FailSends(nil)
+	s.bot.AutofillQueue(now.Add(time.Minute), tried)
+	if items := s.queue.Queued(testChannel); len(items) != 0 {
+		t.Fatalf("в очереди %d элементов, попытка для того же слота повторена", len(items))
+	}
+	if sent := s.tg.SentTo(autofillChat, ""); len(sent) != 0 {
+		t.Fatalf("в чат автозаполнения отправлено %d сообщений после неудачной попытки", len(sent))
+	}
+
+	// Черновик для пустого слота готовится один раз
+	tried = make(map[string]time.Time)
+	for i := 0; i < 3; i++ {
+		s.bot.AutofillQueue(now.Add(time.Duration(i)*time.Minute), tried)
+	}
+	if items := s.queue.Queued(testChannel); len(items) != 1 {
+		t.Fatalf("в очереди %d элементов, ожидался один черновик", len(items))
+	}
+}
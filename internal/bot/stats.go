@@ -0,0 +1,191 @@
+package bot
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/d1mk9/tgChanPost/configs"
+	"github.com/d1mk9/tgChanPost/internal/models"
+	"github.com/d1mk9/tgChanPost/internal/telegram"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// statsTop сколько строк показывать в каждом разделе /stats
+const statsTop = 5
+
+// styleNames названия оформления изображений в отчете
+var styleNames = map[string]string{
+	configs.PostStyleCaption: "цитата в подписи",
+	configs.PostStyleOverlay: "цитата на изображении",
+}
+
+// ReactionSource клиент, который получает счетчики реакций на посты в каналах.
+// Бот должен быть администратором канала.
+type ReactionSource interface {
+	ReactionCounts() <-chan telegram.ReactionCount
+}
+
+// findPost ищет пост реестра по сообщению в канале
+func (b *Bot) findPost(chat *tgbotapi.Chat, messageID int) (models.Post, bool) {
+	for _, id := range chatChannelIDs(chat) {
+		if post, ok := b.posts.FindByMessage(id, messageID); ok {
+			return post, true
+		}
+	}
+	return models.Post{}, false
+}
+
+// HandleReactionCount сохраняет счетчики реакций на опубликованный пост.
+// Вызывается из Run и напрямую в сценарных проверках.
+func (b *Bot) HandleReactionCount(rc telegram.ReactionCount) {
+	post, ok := b.findPost(&rc.Chat, rc.MessageID)
+	if !ok {
+		countUpdate("reactions", nil)
+		return
+	}
+
+	reactions := make(map[string]int, len(rc.Reactions))
+	for _, r := range rc.Reactions {
+		reactions[r.Type.Key()] += r.TotalCount
+	}
+
+	_, err := b.posts.Update(post.ID, func(p *models.Post) {
+		p.Stats.Reactions = reactions
+		p.Stats.UpdatedAt = time.Now()
+	})
+	if err != nil {
+		slog.Error("Ошибка сохранения реакций", "channel", post.ChannelID, "message_id", post.MessageID, "err", err)
+	}
+	countUpdate("reactions", err)
+}
+
+// trackForward учитывает пересылку опубликованного поста в чат, где есть бот.
+// Автоматические пересылки в группу обсуждения канала не считаются.
+func (b *Bot) trackForward(ctx context.Context, message *tgbotapi.Message) {
+	if message.ForwardFromChat == nil || message.IsAutomaticForward {
+		return
+	}
+	post, ok := b.findPost(message.ForwardFromChat, message.ForwardFromMessageID)
+	if !ok {
+		return
+	}
+
+	_, err := b.posts.Update(post.ID, func(p *models.Post) {
+		p.Stats.Forwards++
+		p.Stats.UpdatedAt = time.Now()
+	})
+	if err != nil {
+		slog.ErrorContext(ctx, "Ошибка сохранения пересылки", "channel", post.ChannelID, "message_id", post.MessageID, "err", err)
+		return
+	}
+	slog.DebugContext(ctx, "Пересылка поста", "channel", post.ChannelID, "message_id", post.MessageID, "to_chat", message.Chat.ID)
+}
+
+// statsRow показатели группы постов
+type statsRow struct {
+	name      string
+	posts     int
+	reactions int
+	forwards  int
+}
+
+// score реакции и пересылки в среднем на пост
+func (r statsRow) score() float64 {
+	return float64(r.reactions+r.forwards) / float64(r.posts)
+}
+
+// rankPosts группирует посты по ключу и сортирует группы по среднему отклику
+func rankPosts(posts []models.Post, key func(models.Post) string) []statsRow {
+	groups := make(map[string]*statsRow)
+	for _, p := range posts {
+		name := key(p)
+		row, ok := groups[name]
+		if !ok {
+			row = &statsRow{name: name}
+			groups[name] = row
+		}
+		row.posts++
+		row.reactions += p.Stats.TotalReactions()
+		row.forwards += p.Stats.Forwards
+	}
+
+	rows := make([]statsRow, 0, len(groups))
+	for _, row := range groups {
+		rows = append(rows, *row)
+	}
+	sort.Slice(rows, func(i, j int) bool {
+		if rows[i].score() != rows[j].score() {
+			return rows[i].score() > rows[j].score()
+		}
+		if rows[i].posts != rows[j].posts {
+			return rows[i].posts > rows[j].posts
+		}
+		return rows[i].name < rows[j].name
+	})
+	return rows
+}
+
+// handleStatsCommand обрабатывает /stats [канал]: рейтинг тем, авторов
+// и оформления изображений по реакциям и пересылкам
+func (b *Bot) handleStatsCommand(message *tgbotapi.Message) error {
+	ch, ok := b.commandChannel(message)
+	if !ok {
+		return nil
+	}
+
+	posts := b.posts.List(ch.ID)
+	if len(posts) == 0 {
+		b.notify(message.Chat.ID, fmt.Sprintf("В %s еще нет опубликованных постов", ch.ID))
+		return nil
+	}
+
+	b.notify(message.Chat.ID, renderStats(ch.ID, posts))
+	return nil
+}
+
+// renderStats формирует отчет /stats
+func renderStats(channelID string, posts []models.Post) string {
+	total := rankPosts(posts, func(models.Post) string { return "" })[0]
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "Статистика %s: постов %d, реакций %d, пересылок %d\n", channelID, total.posts, total.reactions, total.forwards)
+	sb.WriteString("Просмотры Bot API не передает, рейтинг считается по реакциям и пересылкам в среднем на пост.")
+
+	sections := []struct {
+		title string
+		key   func(models.Post) string
+	}{
+		{"Темы", func(p models.Post) string { return orDash(p.Topic) }},
+		{"Авторы", func(p models.Post) string { return orDash(p.Author) }},
+		{"Оформление", func(p models.Post) string {
+			if name, ok := styleNames[p.Style]; ok {
+				return name
+			}
+			return orDash(p.Style)
+		}},
+	}
+	for _, s := range sections {
+		fmt.Fprintf(&sb, "\n\n%s:", s.title)
+		for i, row := range rankPosts(posts, s.key) {
+			if i == statsTop {
+				break
+			}
+			fmt.Fprintf(&sb, "\n%d. %s — %.1f (постов %d, реакций %d, пересылок %d)",
+				i+1, row.name, row.score(), row.posts, row.reactions, row.forwards)
+		}
+	}
+	return sb.String()
+}
+
+// orDash заменяет пустое значение прочерком
+func orDash(s string) string {
+	if s == "" {
+		return "—"
+	}
+	return s
+}
@@ -0,0 +1,296 @@
+package bot
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math/rand"
+	"strings"
+	"time"
+
+	"github.com/d1mk9/tgChanPost/configs"
+	"github.com/d1mk9/tgChanPost/internal/logging"
+	"github.com/d1mk9/tgChanPost/internal/topics"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// topicsGenPrefix кнопка «Черновик по ротации»: topics:<ID канала>
+const topicsGenPrefix = "topics:"
+
+const (
+	autofillTick = time.Minute   // Период проверки пустых слотов
+	autofillLead = 3 * time.Hour // За сколько до пустого слота готовится черновик по ротации
+)
+
+// postRequest запрос на генерацию черновика
+type postRequest struct {
+	prompt string   // Запрос к модели
+	topic  string   // Тема для реестра постов и ротации
+	avoid  []string // Авторы на перерыве
+}
+
+// avoided сообщает, входит ли автор в список
+func avoided(author string, avoid []string) bool {
+	for _, a := range avoid {
+		if strings.EqualFold(a, author) {
+			return true
+		}
+	}
+	return false
+}
+
+// buildTopics собирает каталоги тем каналов, в которых настроена ротация
+func buildTopics(channels []configs.Channel) (map[string]*topics.Catalog, error) {
+	catalogs := make(map[string]*topics.Catalog)
+	for _, ch := range channels {
+		if ch.Topics == nil {
+			continue
+		}
+
+		list := make([]topics.Topic, len(ch.Topics.Catalog))
+		for i, t := range ch.Topics.Catalog {
+			list[i] = topics.Topic{
+				Name:     t.Name,
+				Prompt:   t.Prompt,
+				Weight:   t.Weight,
+				Cooldown: ch.Topics.TopicCooldown(t),
+			}
+		}
+
+		catalog, err := topics.New(topics.Options{
+			Topics:         list,
+			AuthorCooldown: ch.Topics.AuthorCooldownDuration(),
+			Template:       topicsTemplate(ch),
+		})
+		if err != nil {
+			return nil, fmt.Errorf("канал %s: %w", ch.ID, err)
+		}
+		catalogs[ch.ID] = catalog
+	}
+	return catalogs, nil
+}
+
+// topicsTemplate возвращает шаблон запроса ротации: из настроек канала
+// или по умолчанию на языке канала
+func topicsTemplate(ch configs.Channel) string {
+	if ch.Topics.PromptTemplate != "" {
+		return ch.Topics.PromptTemplate
+	}
+	return languageOf(ch).Avoid
+}
+
+// topicHistory собирает историю канала для ротации: опубликованные посты
+// и черновики в очереди, которые считаются использованными с момента постановки
+func (b *Bot) topicHistory(channelID string) []topics.Use {
+	var history []topics.Use
+	for _, p := range b.posts.List(channelID) {
+		history = append(history, topics.Use{Topic: p.Topic, Author: p.Author, At: p.PublishedAt})
+	}
+	for _, item := range b.queue.Queued(channelID) {
+		history = append(history, topics.Use{Topic: item.Draft.Topic, Author: item.Draft.Author, At: item.QueuedAt})
+	}
+	return history
+}
+
+// pickTopic выбирает тему канала и формирует запрос к модели
+func (b *Bot) pickTopic(ch configs.Channel, now time.Time) (postRequest, error) {
+	catalog, ok := b.settings.Load().topics[ch.ID]
+	if !ok {
+		return postRequest{}, topics.ErrEmpty
+	}
+
+	history := b.topicHistory(ch.ID)
+	topic, err := catalog.Pick(now, history, rand.New(rand.NewSource(now.UnixNano())))
+	if err != nil {
+		return postRequest{}, err
+	}
+
+	var avoid []string
+	for _, blocked := range catalog.BlockedAuthors(now, history) {
+		avoid = append(avoid, blocked.Author)
+	}
+
+	prompt, err := catalog.Prompt(topic, avoid)
+	if err != nil {
+		return postRequest{}, err
+	}
+	return postRequest{prompt: prompt, topic: topic.Name, avoid: avoid}, nil
+}
+
+// handleTopicsCommand обрабатывает /topics [канал]: показывает каталог тем
+// с перерывами, авторов на перерыве и кнопку черновика по ротации
+func (b *Bot) handleTopicsCommand(message *tgbotapi.Message) error {
+	ch, ok := b.commandChannel(message)
+	if !ok {
+		return nil
+	}
+
+	catalog, ok := b.settings.Load().topics[ch.ID]
+	if !ok || catalog.Len() == 0 {
+		b.notify(message.Chat.ID, fmt.Sprintf("Для %s ротация тем не настроена (topics в настройках канала)", ch.ID))
+		return nil
+	}
+
+	msg := tgbotapi.NewMessage(message.Chat.ID, b.renderTopics(ch, catalog, time.Now()))
+	msg.ReplyMarkup = tgbotapi.NewInlineKeyboardMarkup(tgbotapi.NewInlineKeyboardRow(
+		tgbotapi.NewInlineKeyboardButtonData("🎲 Черновик по ротации", topicsGenPrefix+ch.ID),
+	))
+	if _, err := b.tg.Send(msg); err != nil {
+		return fmt.Errorf("ошибка отправки сообщения: %v", err)
+	}
+	return nil
+}
+
+// renderTopics формирует описание ротации тем канала
+func (b *Bot) renderTopics(ch configs.Channel, catalog *topics.Catalog, now time.Time) string {
+	history := b.topicHistory(ch.ID)
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "Темы %s:", ch.ID)
+	for _, s := range catalog.States(now, history) {
+		fmt.Fprintf(&sb, "\n%s %s — вес %g, перерыв %s, постов %d",
+			topicMark(s, now), s.Topic.Name, s.Topic.Weight, formatCooldown(s.Topic.Cooldown), s.Uses)
+		if !s.Available(now) {
+			fmt.Fprintf(&sb, ", доступна с %s", s.AvailableAt.Format(queueTimeLayout))
+		}
+	}
+
+	blocked := catalog.BlockedAuthors(now, history)
+	if len(blocked) == 0 {
+		fmt.Fprintf(&sb, "\n\nАвторы на перерыве (%s): нет", formatCooldown(catalog.AuthorCooldown()))
+		return sb.String()
+	}
+	fmt.Fprintf(&sb, "\n\nАвторы на перерыве (%s):", formatCooldown(catalog.AuthorCooldown()))
+	for _, a := range blocked {
+		fmt.Fprintf(&sb, "\n• %s — до %s", a.Author, a.Until.Format(queueTimeLayout))
+	}
+	return sb.String()
+}
+
+// topicMark отмечает, доступна ли тема для выбора
+func topicMark(s topics.State, now time.Time) string {
+	if s.Available(now) {
+		return "✅"
+	}
+	return "⏳"
+}
+
+// formatCooldown показывает перерыв в днях, если он кратен суткам
+func formatCooldown(d time.Duration) string {
+	if d == 0 {
+		return "нет"
+	}
+	if d > 0 && d%(24*time.Hour) == 0 {
+		return fmt.Sprintf("%d д", d/(24*time.Hour))
+	}
+	return strings.TrimSuffix(strings.TrimSuffix(d.String(), "0s"), "0m")
+}
+
+// handleTopicsCallback генерирует черновик по теме из ротации канала.
+// Генерация проходит те же проверки бюджета и частоты, что и обычный запрос.
+func (b *Bot) handleTopicsCallback(ctx context.Context, callback *tgbotapi.CallbackQuery) (string, error) {
+	ch, ok := b.settings.Load().cfg.Channel(strings.TrimPrefix(callback.Data, topicsGenPrefix))
+	if !ok {
+		return "Канал не найден", nil
+	}
+
+	// Ограничения считаются по пользователю, нажавшему кнопку
+	message := *callback.Message
+	message.From = callback.From
+	chatID := message.Chat.ID
+
+	if spent, budget, exceeded := b.budgetExceeded(userID(&message)); exceeded {
+		return "", b.sendBudgetExceeded(chatID, spent, budget)
+	}
+	if ok, err := b.allowGeneration(ctx, &message); !ok {
+		return "", err
+	}
+
+	req, err := b.pickTopic(ch, time.Now())
+	if errors.Is(err, topics.ErrEmpty) {
+		return "Нет тем для ротации: не настроены или у всех идет перерыв", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	slog.InfoContext(ctx, "Тема выбрана ротацией", "chat_id", chatID, "channel", ch.ID, "topic", req.topic, "avoid_authors", len(req.avoid))
+
+	if _, err := b.generatePost(ctx, ch, chatID, userID(&message), req); err != nil {
+		return "", err
+	}
+	return "Тема: " + req.topic, nil
+}
+
+// runAutofill заполняет пустую очередь каналов черновиками по ротации
+// до закрытия stop
+func (b *Bot) runAutofill(stop <-chan struct{}) {
+	ticker := time.NewTicker(autofillTick)
+	defer ticker.Stop()
+
+	// Слоты, для которых черновик уже готовился: после ошибки генерация
+	// не повторяется каждую минуту, а ждет следующего слота
+	tried := make(map[string]time.Time)
+	for {
+		b.autofillQueue(time.Now(), tried)
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// autofillQueue готовит черновик по ротации для каналов с autofill_chat,
+// если в очереди нет ни одного ожидающего элемента, а ближайший слот
+// наступит в пределах autofillLead. Превью уходит в чат модераторов,
+// черновик встает на этот слот, и снять его можно через /queue.
+func (b *Bot) autofillQueue(now time.Time, tried map[string]time.Time) {
+	for _, ch := range b.settings.Load().cfg.Channels {
+		if ch.Topics == nil || ch.Topics.AutofillChat == 0 {
+			continue
+		}
+		slots := ch.SlotTimes(now, 1)
+		if len(slots) == 0 || slots[0].Sub(now) > autofillLead || tried[ch.ID].Equal(slots[0]) {
+			continue
+		}
+		if len(b.upcoming(ch.ID, now)) > 0 {
+			continue
+		}
+		tried[ch.ID] = slots[0]
+
+		ctx := logging.WithCorrelationID(context.Background())
+		if err := b.autofillChannel(ctx, ch, now); err != nil {
+			slog.ErrorContext(ctx, "Ошибка автозаполнения очереди", "channel", ch.ID, "slot", slots[0], "err", err)
+			b.notify(ch.Topics.AutofillChat, fmt.Sprintf("Не удалось подготовить черновик по ротации для %s: %v", ch.ID, err))
+		}
+	}
+}
+
+// autofillChannel генерирует черновик по ротации и ставит его в очередь канала
+func (b *Bot) autofillChannel(ctx context.Context, ch configs.Channel, now time.Time) error {
+	chatID := ch.Topics.AutofillChat
+
+	req, err := b.pickTopic(ch, now)
+	if err != nil {
+		return err
+	}
+	slog.InfoContext(ctx, "Тема выбрана ротацией для очереди", "channel", ch.ID, "topic", req.topic, "avoid_authors", len(req.avoid))
+
+	// Черновик заказывает сам бот, поэтому расход записывается без пользователя
+	draft, err := b.generatePost(ctx, ch, chatID, 0, req)
+	if err != nil {
+		return err
+	}
+	if draft.MessageID == 0 {
+		return errors.New("модель не предложила подходящую цитату")
+	}
+
+	text, err := b.enqueueDraft(draft, 0)
+	if err != nil {
+		return err
+	}
+	b.notify(chatID, fmt.Sprintf("Черновик по ротации (тема: %s). %s", req.topic, text))
+	return nil
+}
@@ -0,0 +1,145 @@
+package bot
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/d1mk9/tgChanPost/internal/metrics"
+	"github.com/d1mk9/tgChanPost/internal/models"
+	"github.com/d1mk9/tgChanPost/internal/storage"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// requester чат, пользователь и канал генерации, на которые записывается расход
+type requester struct {
+	chatID    int64
+	userID    int64
+	channelID string
+}
+
+// recordText записывает расход токенов на генерацию текста
+func (b *Bot) recordText(ctx context.Context, r requester, usage models.Usage) {
+	prices := b.settings.Load().cfg.Usage
+	cost := prices.TextCost(usage.InputTokens + usage.CompletionTokens)
+
+	metrics.TokensUsed.WithLabelValues(r.channelID, "input").Add(float64(usage.InputTokens))
+	metrics.TokensUsed.WithLabelValues(r.channelID, "completion").Add(float64(usage.CompletionTokens))
+	metrics.Spend.WithLabelValues(metrics.ProviderLLM, r.channelID).Add(cost)
+
+	b.record(ctx, models.UsageEntry{
+		ChatID:           r.chatID,
+		UserID:           r.userID,
+		ChannelID:        r.channelID,
+		InputTokens:      usage.InputTokens,
+		CompletionTokens: usage.CompletionTokens,
+		Cost:             cost,
+	})
+}
+
+// recordImage записывает генерацию изображения
+func (b *Bot) recordImage(ctx context.Context, r requester) {
+	cost := b.settings.Load().cfg.Usage.ImageCost(1)
+	metrics.Spend.WithLabelValues(metrics.ProviderArt, r.channelID).Add(cost)
+
+	b.record(ctx, models.UsageEntry{
+		ChatID:    r.chatID,
+		UserID:    r.userID,
+		ChannelID: r.channelID,
+		Images:    1,
+		Cost:      cost,
+	})
+}
+
+func (b *Bot) record(ctx context.Context, e models.UsageEntry) {
+	// Ошибка записи не должна терять уже оплаченный результат генерации
+	if err := b.usage.Record(time.Now(), e); err != nil {
+		slog.ErrorContext(ctx, "Ошибка сохранения расхода", "chat_id", e.ChatID, "err", err)
+	}
+}
+
+// budgetExceeded сообщает, исчерпал ли пользователь месячный бюджет
+func (b *Bot) budgetExceeded(userID int64) (spent, budget float64, exceeded bool) {
+	budget = b.settings.Load().cfg.Usage.BudgetFor(userID)
+	if budget <= 0 {
+		return 0, 0, false
+	}
+	spent = b.usage.Totals(storage.UsageFilter{From: storage.StartOfMonth(time.Now()), UserID: userID}).Cost
+	return spent, budget, spent >= budget
+}
+
+// sendBudgetExceeded сообщает пользователю, что генерация заблокирована до конца месяца
+func (b *Bot) sendBudgetExceeded(chatID int64, spent, budget float64) error {
+	currency := b.settings.Load().cfg.Usage.Currency
+	text := fmt.Sprintf("Месячный бюджет исчерпан: потрачено %s из %s. Генерация снова станет доступна в следующем месяце.",
+		formatCost(spent, currency), formatCost(budget, currency))
+	if _, err := b.tg.Send(tgbotapi.NewMessage(chatID, text)); err != nil {
+		return fmt.Errorf("ошибка отправки сообщения: %v", err)
+	}
+	return nil
+}
+
+// handleUsageCommand обрабатывает /usage: расход чата за день и месяц,
+// расход по каналам за месяц и остаток бюджета пользователя
+func (b *Bot) handleUsageCommand(message *tgbotapi.Message) error {
+	usage := b.settings.Load().cfg.Usage
+	now := time.Now()
+	chat := storage.UsageFilter{ChatID: message.Chat.ID}
+
+	day := chat
+	day.From = storage.StartOfDay(now)
+	month := chat
+	month.From = storage.StartOfMonth(now)
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "Расход чата за сегодня: %s\n", formatTotals(b.usage.Totals(day), usage.Currency))
+	fmt.Fprintf(&sb, "Расход чата за месяц: %s\n", formatTotals(b.usage.Totals(month), usage.Currency))
+
+	byChannel := b.usage.ByChannel(month)
+	if len(byChannel) > 0 {
+		ids := make([]string, 0, len(byChannel))
+		for id := range byChannel {
+			ids = append(ids, id)
+		}
+		sort.Strings(ids)
+
+		sb.WriteString("\nПо каналам за месяц:\n")
+		for _, id := range ids {
+			fmt.Fprintf(&sb, "%s: %s\n", id, formatTotals(byChannel[id], usage.Currency))
+		}
+	}
+
+	if message.From != nil {
+		spent := b.usage.Totals(storage.UsageFilter{From: month.From, UserID: message.From.ID}).Cost
+		if budget := usage.BudgetFor(message.From.ID); budget > 0 {
+			fmt.Fprintf(&sb, "\nВаш бюджет на месяц: потрачено %s из %s", formatCost(spent, usage.Currency), formatCost(budget, usage.Currency))
+		} else {
+			fmt.Fprintf(&sb, "\nВаши расходы за месяц: %s, бюджет не ограничен", formatCost(spent, usage.Currency))
+		}
+	}
+
+	if _, err := b.tg.Send(tgbotapi.NewMessage(message.Chat.ID, sb.String())); err != nil {
+		return fmt.Errorf("ошибка отправки сообщения: %v", err)
+	}
+	return nil
+}
+
+func formatTotals(t storage.UsageTotals, currency string) string {
+	return fmt.Sprintf("токены: %d, изображения: %d, стоимость: %s", t.Tokens(), t.Images, formatCost(t.Cost, currency))
+}
+
+func formatCost(cost float64, currency string) string {
+	return fmt.Sprintf("%.2f %s", cost, currency)
+}
+
+// userID возвращает ID автора сообщения; у сообщений от имени канала его нет
+func userID(message *tgbotapi.Message) int64 {
+	if message.From == nil {
+		return 0
+	}
+	return message.From.ID
+}
@@ -0,0 +1,226 @@
+package caption
+
+import (
+	"fmt"
+	"html"
+	"regexp"
+	"strings"
+	"text/template"
+	"unicode/utf16"
+)
+
+// Mode режим разметки Telegram
+type Mode string
+
+const (
+	ModeMarkdownV2 Mode = "MarkdownV2"
+	ModeHTML       Mode = "HTML"
+)
+
+// MaxLength ограничение Telegram на длину подписи к медиа
+const MaxLength = 1024
+
+// MaxMessageLength ограничение Telegram на длину текстового сообщения
+const MaxMessageLength = 4096
+
+// Шаблоны по умолчанию для подписи с цитатой. Оригинал переведенной
+// цитаты показывается цитатой-блоком под переводом.
+const (
+	DefaultMarkdownV2Template = "{{.Open}}{{.Quote}}{{.Close}}{{if .Original}}\n\n>{{.Open}}{{.Original}}{{.Close}}{{end}}\n\n_{{.Author}}_{{if .Signature}}\n\n[{{.Signature}}]({{.Link}}){{end}}"
+	DefaultHTMLTemplate       = "{{.Open}}{{.Quote}}{{.Close}}{{if .Original}}\n\n<blockquote>{{.Open}}{{.Original}}{{.Close}}</blockquote>{{end}}\n\n<i>{{.Author}}</i>{{if .Signature}}\n\n<a href=\"{{.Link}}\">{{.Signature}}</a>{{end}}"
+)
+
+// Шаблоны по умолчанию для постов с цитатой на изображении
+const (
+	SignatureMarkdownV2Template = "{{if .Original}}>{{.Open}}{{.Original}}{{.Close}}\n\n{{end}}{{if .Signature}}[{{.Signature}}]({{.Link}}){{end}}"
+	SignatureHTMLTemplate       = "{{if .Original}}<blockquote>{{.Open}}{{.Original}}{{.Close}}</blockquote>\n\n{{end}}{{if .Signature}}<a href=\"{{.Link}}\">{{.Signature}}</a>{{end}}"
+)
+
+// Кавычки по умолчанию
+const (
+	DefaultOpenQuote  = "«"
+	DefaultCloseQuote = "»"
+)
+
+// Data данные для подстановки в шаблон. Перед подстановкой все поля
+// экранируются для выбранного режима, поэтому в шаблоне их не нужно экранировать.
+type Data struct {
+	Quote     string
+	Original  string // Цитата на языке оригинала, если Quote - перевод
+	Author    string
+	Signature string
+	Link      string
+	Open      string // Кавычки языка канала, по умолчанию «»
+	Close     string
+}
+
+// Renderer формирует подпись по шаблону канала
+type Renderer struct {
+	mode Mode
+	tmpl *template.Template
+}
+
+// New разбирает шаблон подписи. Текст шаблона вне подстановок
+// должен быть корректной разметкой выбранного режима.
+func New(mode Mode, text string) (*Renderer, error) {
+	switch mode {
+	case ModeMarkdownV2, ModeHTML:
+	default:
+		return nil, fmt.Errorf("неизвестный режим разметки: %q", mode)
+	}
+
+	tmpl, err := template.New("caption").Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка разбора шаблона подписи: %w", err)
+	}
+
+	return &Renderer{mode: mode, tmpl: tmpl}, nil
+}
+
+// ParseMode возвращает значение parse_mode для Telegram
+func (r *Renderer) ParseMode() string {
+	return string(r.mode)
+}
+
+// Render подставляет экранированные данные в шаблон
+func (r *Renderer) Render(d Data) (string, error) {
+	if d.Open == "" && d.Close == "" {
+		d.Open, d.Close = DefaultOpenQuote, DefaultCloseQuote
+	}
+	escaped := Data{
+		Quote: r.Escape(d.Quote),
+		// Цитата-блок в MarkdownV2 заканчивается на переносе строки
+		Original:  r.Escape(strings.Join(strings.Fields(d.Original), " ")),
+		Author:    r.Escape(d.Author),
+		Signature: r.Escape(d.Signature),
+		Link:      r.escapeLink(d.Link),
+		Open:      r.Escape(d.Open),
+		Close:     r.Escape(d.Close),
+	}
+
+	var sb strings.Builder
+	if err := r.tmpl.Execute(&sb, escaped); err != nil {
+		return "", fmt.Errorf("ошибка формирования подписи: %w", err)
+	}
+	return strings.TrimSpace(sb.String()), nil
+}
+
+// Escape экранирует произвольный текст для режима разметки
+func (r *Renderer) Escape(s string) string {
+	if r.mode == ModeHTML {
+		return EscapeHTML(s)
+	}
+	return EscapeMarkdownV2(s)
+}
+
+func (r *Renderer) escapeLink(s string) string {
+	if r.mode == ModeHTML {
+		return EscapeHTML(s)
+	}
+	return escapeMarkdownV2Link(s)
+}
+
+// Fits проверяет, помещается ли подпись в ограничение Telegram
+func Fits(mode Mode, text string) bool {
+	return Length(mode, text) <= MaxLength
+}
+
+// FitsMessage сообщает, помещается ли текст в отдельное сообщение
+func FitsMessage(mode Mode, text string) bool {
+	return Length(mode, text) <= MaxMessageLength
+}
+
+var markdownV2Special = "_*[]()~`>#+-=|{}.!\\"
+
+// EscapeMarkdownV2 экранирует все служебные символы MarkdownV2
+func EscapeMarkdownV2(s string) string {
+	var sb strings.Builder
+	for _, r := range s {
+		if strings.ContainsRune(markdownV2Special, r) {
+			sb.WriteByte('\\')
+		}
+		sb.WriteRune(r)
+	}
+	return sb.String()
+}
+
+// escapeMarkdownV2Link экранирует URL внутри (...) ссылки MarkdownV2
+func escapeMarkdownV2Link(s string) string {
+	s = strings.ReplaceAll(s, "\\", "\\\\")
+	return strings.ReplaceAll(s, ")", "\\)")
+}
+
+// EscapeHTML экранирует символы, которые Telegram требует заменять в HTML
+func EscapeHTML(s string) string {
+	r := strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;", "\"", "&quot;")
+	return r.Replace(s)
+}
+
+var htmlTag = regexp.MustCompile(`<[^>]*>`)
+
+// Length возвращает длину текста после разбора разметки в единицах UTF-16,
+// как ее считает Telegram при проверке ограничений
+func Length(mode Mode, text string) int {
+	var plain string
+	if mode == ModeHTML {
+		plain = html.UnescapeString(htmlTag.ReplaceAllString(text, ""))
+	} else {
+		plain = stripMarkdownV2(text)
+	}
+	return len(utf16.Encode([]rune(plain)))
+}
+
+// Truncate обрезает текст без разметки до max единиц UTF-16, заменяя
+// окончание многоточием
+func Truncate(text string, max int) string {
+	if len(utf16.Encode([]rune(text))) <= max {
+		return text
+	}
+	var n int
+	runes := []rune(text)
+	for i, r := range runes {
+		// Многоточие занимает одну единицу
+		if n += utf16.RuneLen(r); n > max-1 {
+			return string(runes[:i]) + "…"
+		}
+	}
+	return text
+}
+
+// stripMarkdownV2 удаляет разметку MarkdownV2, оставляя видимый текст
+func stripMarkdownV2(text string) string {
+	var sb strings.Builder
+	runes := []rune(text)
+	for i := 0; i < len(runes); i++ {
+		switch r := runes[i]; r {
+		case '\\':
+			if i+1 < len(runes) {
+				i++
+				sb.WriteRune(runes[i])
+			}
+		case '*', '_', '~', '|', '`', '[':
+		case '>':
+			// Маркер цитаты-блока в начале строки не виден, в том числе
+			// у раскрывающейся цитаты **>
+			j := i
+			for j > 0 && runes[j-1] == '*' {
+				j--
+			}
+			if j > 0 && runes[j-1] != '\n' {
+				sb.WriteRune(r)
+			}
+		case ']':
+			// Пропускаем URL ссылки: ](...)
+			if i+1 < len(runes) && runes[i+1] == '(' {
+				for i++; i < len(runes) && runes[i] != ')'; i++ {
+					if runes[i] == '\\' {
+						i++
+					}
+				}
+			}
+		default:
+			sb.WriteRune(r)
+		}
+	}
+	return sb.String()
+}
@@ -0,0 +1,137 @@
+package caption
+
+import (
+	"testing"
+	"unicode/utf16"
+)
+
+func TestLength(t *testing.T) {
+	tests := []struct {
+		name string
+		mode Mode
+		text string
+		want int
+	}{
+		{name: "кириллица", mode: ModeMarkdownV2, text: "Привет", want: 6},
+		{name: "экранированные символы", mode: ModeMarkdownV2, text: `a\.b\!\\`, want: 5},
+		{name: "выделение", mode: ModeMarkdownV2, text: "*жирный* _курсив_ ~зачеркнутый~ ||скрытый||", want: 33},
+		{name: "ссылка", mode: ModeMarkdownV2, text: `[Канал](https://t.me/a\)b)`, want: 5},
+		{name: "суррогатная пара", mode: ModeMarkdownV2, text: "😀a", want: 3},
+		{name: "цитата-блок", mode: ModeMarkdownV2, text: ">первая\n>вторая", want: 13},
+		{name: "раскрывающаяся цитата", mode: ModeMarkdownV2, text: "**>скрыто||", want: 6},
+		{name: "экранированная стрелка", mode: ModeMarkdownV2, text: `\>не цитата`, want: 10},
+		{name: "стрелка внутри строки", mode: ModeMarkdownV2, text: "a>b", want: 3},
+		{name: "HTML теги", mode: ModeHTML, text: `<b>жирный</b> <a href="https://t.me/a">Канал</a>`, want: 12},
+		{name: "HTML сущности", mode: ModeHTML, text: "&lt;a&gt; &amp; &quot;", want: 7},
+		{name: "HTML цитата-блок", mode: ModeHTML, text: "<blockquote>цитата</blockquote>", want: 6},
+		{name: "HTML суррогатная пара", mode: ModeHTML, text: "<i>😀</i>", want: 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Length(tt.mode, tt.text); got != tt.want {
+				t.Fatalf("Length(%q) = %d, ожидалось %d", tt.text, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEscape(t *testing.T) {
+	tests := []struct {
+		mode Mode
+		text string
+		want string
+	}{
+		{ModeMarkdownV2, "Смех - лучшее лекарство!", `Смех \- лучшее лекарство\!`},
+		{ModeMarkdownV2, "a_b*c[d](e)>f#g", `a\_b\*c\[d\]\(e\)\>f\#g`},
+		{ModeMarkdownV2, `C:\dir`, `C:\\dir`},
+		{ModeHTML, `<a> & "b"`, "&lt;a&gt; &amp; &quot;b&quot;"},
+	}
+
+	for _, tt := range tests {
+		r, err := New(tt.mode, "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		got := r.Escape(tt.text)
+		if got != tt.want {
+			t.Errorf("%s: Escape(%q) = %q, ожидалось %q", tt.mode, tt.text, got, tt.want)
+		}
+		// Экранирование не меняет видимую длину
+		if n := Length(tt.mode, got); n != len(utf16.Encode([]rune(tt.text))) {
+			t.Errorf("%s: видимая длина %q = %d, ожидалась длина исходного текста", tt.mode, got, n)
+		}
+	}
+}
+
+func TestRenderSameVisibleText(t *testing.T) {
+	data := Data{
+		Quote:     "Жизнь — это 1.5% вдохновения & 98.5% труда 😀",
+		Original:  "Life is *hard*\n<really>",
+		Author:    "Автор_с_подчеркиваниями",
+		Signature: "[Канал]",
+		Link:      "https://t.me/channel_(1)",
+	}
+	templates := map[Mode][]string{
+		ModeMarkdownV2: {DefaultMarkdownV2Template, SignatureMarkdownV2Template},
+		ModeHTML:       {DefaultHTMLTemplate, SignatureHTMLTemplate},
+	}
+
+	// Шаблоны по умолчанию в обоих режимах дают одинаковый видимый текст
+	var lengths [2]map[Mode]int
+	for i := range lengths {
+		lengths[i] = make(map[Mode]int)
+	}
+	for mode, list := range templates {
+		for i, text := range list {
+			r, err := New(mode, text)
+			if err != nil {
+				t.Fatal(err)
+			}
+			out, err := r.Render(data)
+			if err != nil {
+				t.Fatal(err)
+			}
+			lengths[i][mode] = Length(mode, out)
+		}
+	}
+	for i, l := range lengths {
+		if l[ModeMarkdownV2] != l[ModeHTML] {
+			t.Errorf("шаблон #%d: длина MarkdownV2 %d, HTML %d", i+1, l[ModeMarkdownV2], l[ModeHTML])
+		}
+	}
+}
+
+func TestRenderUnknownField(t *testing.T) {
+	// Опечатка в имени поля не видна при разборе и обнаруживается только при подстановке
+	r, err := New(ModeHTML, "{{.Quotee}}")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := r.Render(Data{Quote: "q"}); err == nil {
+		t.Fatal("ожидалась ошибка подстановки")
+	}
+}
+
+func TestTruncate(t *testing.T) {
+	tests := []struct {
+		text string
+		max  int
+		want string
+	}{
+		{"короткий", 10, "короткий"},
+		{"длинный текст", 8, "длинный…"},
+		{"ab😀cd", 4, "ab…"}, // Суррогатная пара не разрывается
+		{"ab😀cd", 5, "ab😀…"},
+	}
+
+	for _, tt := range tests {
+		got := Truncate(tt.text, tt.max)
+		if got != tt.want {
+			t.Errorf("Truncate(%q, %d) = %q, ожидалось %q", tt.text, tt.max, got, tt.want)
+		}
+		if n := Length(ModeHTML, got); n > tt.max {
+			t.Errorf("Truncate(%q, %d): длина %d", tt.text, tt.max, n)
+		}
+	}
+}
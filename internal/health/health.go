@@ -0,0 +1,146 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// checkTimeout ограничение на время выполнения всех проверок готовности
+const checkTimeout = 5 * time.Second
+
+// Check проверка готовности; nil означает, что компонент готов
+type Check func(ctx context.Context) error
+
+type namedCheck struct {
+	name  string
+	check Check
+}
+
+// Checker отвечает на /healthz по пульсу цикла обновлений
+// и на /readyz по результатам зарегистрированных проверок
+type Checker struct {
+	mu        sync.Mutex
+	lastTick  time.Time
+	maxAge    time.Duration
+	busySince time.Time // Начало текущей обработки, ноль - цикл свободен
+	maxBusy   time.Duration
+	checks    []namedCheck
+}
+
+// New создает Checker; процесс считается живым, если цикл обновлений
+// отмечался не позже maxAge назад, а обработка одного обновления
+// идет не дольше maxBusy
+func New(maxAge, maxBusy time.Duration) *Checker {
+	return &Checker{lastTick: time.Now(), maxAge: maxAge, maxBusy: maxBusy}
+}
+
+// Tick отмечает, что цикл обновлений работает
+func (c *Checker) Tick() {
+	c.mu.Lock()
+	c.lastTick = time.Now()
+	c.mu.Unlock()
+}
+
+// Busy отмечает начало обработки обновления. Пока она идет, пульс цикла
+// не проверяется: долгая генерация не считается зависанием, пока не превысит
+// maxBusy. Возвращает функцию, которую нужно вызвать по окончании обработки.
+func (c *Checker) Busy() func() {
+	c.mu.Lock()
+	c.busySince = time.Now()
+	c.mu.Unlock()
+
+	return func() {
+		c.mu.Lock()
+		c.busySince = time.Time{}
+		c.lastTick = time.Now()
+		c.mu.Unlock()
+	}
+}
+
+// AddCheck регистрирует проверку готовности
+func (c *Checker) AddCheck(name string, check Check) {
+	c.mu.Lock()
+	c.checks = append(c.checks, namedCheck{name: name, check: check})
+	c.mu.Unlock()
+}
+
+// Register добавляет обработчики /healthz и /readyz
+func (c *Checker) Register(mux *http.ServeMux) {
+	mux.HandleFunc("/healthz", c.healthz)
+	mux.HandleFunc("/readyz", c.readyz)
+}
+
+type report struct {
+	Status string            `json:"status"`
+	Checks map[string]string `json:"checks,omitempty"`
+}
+
+func (c *Checker) healthz(w http.ResponseWriter, r *http.Request) {
+	c.mu.Lock()
+	age, maxAge := time.Since(c.lastTick), c.maxAge
+	if !c.busySince.IsZero() {
+		age, maxAge = time.Since(c.busySince), c.maxBusy
+	}
+	c.mu.Unlock()
+
+	if age > maxAge {
+		writeReport(w, http.StatusServiceUnavailable, report{
+			Status: "stalled",
+			Checks: map[string]string{"update_loop": "нет активности " + age.Round(time.Second).String()},
+		})
+		return
+	}
+	writeReport(w, http.StatusOK, report{Status: "ok"})
+}
+
+func (c *Checker) readyz(w http.ResponseWriter, r *http.Request) {
+	c.mu.Lock()
+	checks := append([]namedCheck(nil), c.checks...)
+	c.mu.Unlock()
+
+	ctx, cancel := context.WithTimeout(r.Context(), checkTimeout)
+	defer cancel()
+
+	rep := report{Status: "ok", Checks: make(map[string]string, len(checks))}
+	code := http.StatusOK
+	for _, nc := range checks {
+		if err := nc.check(ctx); err != nil {
+			rep.Checks[nc.name] = err.Error()
+			rep.Status = "not ready"
+			code = http.StatusServiceUnavailable
+			continue
+		}
+		rep.Checks[nc.name] = "ok"
+	}
+
+	writeReport(w, code, rep)
+}
+
+func writeReport(w http.ResponseWriter, code int, rep report) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	_ = json.NewEncoder(w).Encode(rep)
+}
+
+// Cached кэширует результат проверки на ttl, чтобы частые запросы
+// оркестратора не превращались в частые запросы к внешним API
+func Cached(ttl time.Duration, check Check) Check {
+	var mu sync.Mutex
+	var checkedAt time.Time
+	var last error
+
+	return func(ctx context.Context) error {
+		mu.Lock()
+		defer mu.Unlock()
+
+		if !checkedAt.IsZero() && time.Since(checkedAt) < ttl {
+			return last
+		}
+		last = check(ctx)
+		checkedAt = time.Now()
+		return last
+	}
+}
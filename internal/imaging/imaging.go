@@ -0,0 +1,35 @@
+package imaging
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/draw"
+	"image/jpeg"
+	_ "image/png" // Поддержка PNG на входе
+)
+
+const jpegQuality = 92
+
+// Decode декодирует изображение в новый RGBA-холст, пригодный для рисования.
+// Исходные байты не изменяются.
+func Decode(data []byte) (*image.RGBA, error) {
+	src, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("ошибка декодирования изображения: %w", err)
+	}
+
+	b := src.Bounds()
+	dst := image.NewRGBA(image.Rect(0, 0, b.Dx(), b.Dy()))
+	draw.Draw(dst, dst.Bounds(), src, b.Min, draw.Src)
+	return dst, nil
+}
+
+// EncodeJPEG кодирует изображение в JPEG
+func EncodeJPEG(img image.Image) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: jpegQuality}); err != nil {
+		return nil, fmt.Errorf("ошибка кодирования изображения: %w", err)
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,308 @@
+package imaging
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"os"
+	"strings"
+
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/gofont/goitalic"
+	"golang.org/x/image/font/gofont/goregular"
+	"golang.org/x/image/font/opentype"
+	"golang.org/x/image/math/fixed"
+)
+
+// Position положение блока с цитатой на изображении
+type Position string
+
+const (
+	PositionTop    Position = "top"
+	PositionCenter Position = "center"
+	PositionBottom Position = "bottom"
+)
+
+const (
+	defaultFontScale = 0.055 // Размер шрифта цитаты относительно ширины изображения
+	minFontSize      = 14    // Минимальный размер шрифта в пикселях
+	marginScale      = 0.07  // Отступ от краев относительно ширины изображения
+	maxBlockScale    = 0.6   // Максимальная доля высоты, занимаемая текстом
+)
+
+// OverlayOptions параметры наложения цитаты на изображение
+type OverlayOptions struct {
+	QuoteFontFile  string   // TTF/OTF для цитаты, пусто - встроенный Go Regular
+	AuthorFontFile string   // TTF/OTF для автора, пусто - встроенный Go Italic
+	FontScale      float64  // Размер шрифта цитаты относительно ширины, 0 - по умолчанию
+	Position       Position // Положение текста, пусто - снизу
+	Darkness       float64  // Непрозрачность затемняющего градиента от 0 до 1
+	OpenQuote      string   // Кавычки вокруг цитаты, пусто - «»
+	CloseQuote     string
+}
+
+// Overlay накладывает текст цитаты на изображение.
+// Шрифты разбираются один раз при создании.
+type Overlay struct {
+	opts       OverlayOptions
+	quoteFont  *opentype.Font
+	authorFont *opentype.Font
+}
+
+// NewOverlay загружает шрифты и проверяет параметры наложения
+func NewOverlay(opts OverlayOptions) (*Overlay, error) {
+	switch opts.Position {
+	case "":
+		opts.Position = PositionBottom
+	case PositionTop, PositionCenter, PositionBottom:
+	default:
+		return nil, fmt.Errorf("неизвестное положение текста: %q", opts.Position)
+	}
+	if opts.FontScale <= 0 {
+		opts.FontScale = defaultFontScale
+	}
+	if opts.OpenQuote == "" && opts.CloseQuote == "" {
+		opts.OpenQuote, opts.CloseQuote = "«", "»"
+	}
+	if opts.Darkness < 0 || opts.Darkness > 1 {
+		return nil, fmt.Errorf("затемнение должно быть от 0 до 1: %v", opts.Darkness)
+	}
+
+	quoteFont, err := loadFont(opts.QuoteFontFile, goregular.TTF)
+	if err != nil {
+		return nil, err
+	}
+	authorFont, err := loadFont(opts.AuthorFontFile, goitalic.TTF)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Overlay{opts: opts, quoteFont: quoteFont, authorFont: authorFont}, nil
+}
+
+// loadFont читает шрифт из файла или использует встроенный
+func loadFont(path string, fallback []byte) (*opentype.Font, error) {
+	data := fallback
+	if path != "" {
+		var err error
+		data, err = os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("ошибка чтения шрифта: %w", err)
+		}
+	}
+
+	f, err := opentype.Parse(data)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка разбора шрифта %q: %w", path, err)
+	}
+	return f, nil
+}
+
+// Draw накладывает цитату и автора на изображение
+func (o *Overlay) Draw(dst *image.RGBA, quote, author string) error {
+	width, height := dst.Bounds().Dx(), dst.Bounds().Dy()
+	margin := int(float64(width) * marginScale)
+	maxWidth := width - 2*margin
+
+	quoteText := o.opts.OpenQuote + quote + o.opts.CloseQuote
+	authorText := "— " + author
+
+	// Подбираем размер шрифта так, чтобы текст поместился в отведенную область
+	size := o.opts.FontScale * float64(width)
+	var block *textBlock
+	for {
+		var err error
+		block, err = o.layout(quoteText, authorText, size, maxWidth)
+		if err != nil {
+			return err
+		}
+		if block.height <= int(float64(height)*maxBlockScale) || size <= minFontSize {
+			break
+		}
+		block.close()
+		size *= 0.9
+	}
+	defer block.close()
+
+	var top int
+	switch o.opts.Position {
+	case PositionTop:
+		top = margin
+	case PositionCenter:
+		top = (height - block.height) / 2
+	default:
+		top = height - margin - block.height
+	}
+
+	shade(dst, o.opts.Position, top, top+block.height, margin, o.opts.Darkness)
+	block.draw(dst, top)
+	return nil
+}
+
+// textBlock размеченный блок текста: строки цитаты и автора
+type textBlock struct {
+	quoteFace  font.Face
+	authorFace font.Face
+	quote      []string
+	author     []string
+	gap        int
+	height     int
+}
+
+func (o *Overlay) layout(quote, author string, size float64, maxWidth int) (*textBlock, error) {
+	quoteFace, err := opentype.NewFace(o.quoteFont, &opentype.FaceOptions{Size: size, DPI: 72, Hinting: font.HintingFull})
+	if err != nil {
+		return nil, fmt.Errorf("ошибка создания шрифта: %w", err)
+	}
+	authorFace, err := opentype.NewFace(o.authorFont, &opentype.FaceOptions{Size: size * 0.75, DPI: 72, Hinting: font.HintingFull})
+	if err != nil {
+		quoteFace.Close()
+		return nil, fmt.Errorf("ошибка создания шрифта: %w", err)
+	}
+
+	t := &textBlock{
+		quoteFace:  quoteFace,
+		authorFace: authorFace,
+		quote:      wrap(quoteFace, quote, maxWidth),
+		author:     wrap(authorFace, author, maxWidth),
+		gap:        int(size * 0.6),
+	}
+	t.height = len(t.quote)*lineHeight(quoteFace) + t.gap + len(t.author)*lineHeight(authorFace)
+	return t, nil
+}
+
+func (t *textBlock) close() {
+	t.quoteFace.Close()
+	t.authorFace.Close()
+}
+
+// draw рисует строки по центру изображения начиная с координаты top
+func (t *textBlock) draw(dst *image.RGBA, top int) {
+	y := top
+	for _, line := range t.quote {
+		drawLine(dst, t.quoteFace, line, y)
+		y += lineHeight(t.quoteFace)
+	}
+	y += t.gap
+	for _, line := range t.author {
+		drawLine(dst, t.authorFace, line, y)
+		y += lineHeight(t.authorFace)
+	}
+}
+
+func lineHeight(face font.Face) int {
+	return int(float64(face.Metrics().Height.Ceil()) * 1.15)
+}
+
+// drawLine рисует строку с тенью для читаемости на светлом фоне
+func drawLine(dst *image.RGBA, face font.Face, line string, top int) {
+	width := font.MeasureString(face, line).Ceil()
+	x := (dst.Bounds().Dx() - width) / 2
+	baseline := top + face.Metrics().Ascent.Ceil()
+	offset := face.Metrics().Height.Ceil()/20 + 1
+
+	d := &font.Drawer{Dst: dst, Face: face}
+
+	d.Src = image.NewUniform(color.NRGBA{0, 0, 0, 160})
+	d.Dot = fixed.P(x+offset, baseline+offset)
+	d.DrawString(line)
+
+	d.Src = image.White
+	d.Dot = fixed.P(x, baseline)
+	d.DrawString(line)
+}
+
+// wrap разбивает текст на строки не шире maxWidth
+func wrap(face font.Face, text string, maxWidth int) []string {
+	var lines []string
+	var current string
+
+	for _, word := range strings.Fields(text) {
+		candidate := word
+		if current != "" {
+			candidate = current + " " + word
+		}
+		if font.MeasureString(face, candidate).Ceil() <= maxWidth {
+			current = candidate
+			continue
+		}
+		if current != "" {
+			lines = append(lines, current)
+		}
+		// Слишком длинное слово переносим посимвольно
+		for font.MeasureString(face, word).Ceil() > maxWidth {
+			head := breakWord(face, word, maxWidth)
+			lines = append(lines, head)
+			word = word[len(head):]
+		}
+		current = word
+	}
+	if current != "" {
+		lines = append(lines, current)
+	}
+
+	return lines
+}
+
+// breakWord возвращает самое длинное начало слова, помещающееся в maxWidth
+func breakWord(face font.Face, word string, maxWidth int) string {
+	end := 0
+	for i, r := range word {
+		next := i + len(string(r))
+		if end > 0 && font.MeasureString(face, word[:next]).Ceil() > maxWidth {
+			break
+		}
+		end = next
+	}
+	return word[:end]
+}
+
+// shade затемняет область под текстом плавным градиентом
+func shade(dst *image.RGBA, pos Position, top, bottom, pad int, darkness float64) {
+	if darkness == 0 {
+		return
+	}
+
+	height := dst.Bounds().Dy()
+	fade := 2 * pad
+
+	alpha := func(y int) float64 {
+		switch pos {
+		case PositionTop:
+			return ramp(y, bottom+fade, bottom+pad/2)
+		case PositionCenter:
+			return ramp(y, top-pad-fade, top-pad) * ramp(y, bottom+pad+fade, bottom+pad)
+		default:
+			return ramp(y, top-pad/2-fade, top-pad/2)
+		}
+	}
+
+	for y := 0; y < height; y++ {
+		a := alpha(y) * darkness
+		if a <= 0 {
+			continue
+		}
+		k := 1 - a
+		row := dst.Pix[y*dst.Stride : y*dst.Stride+dst.Bounds().Dx()*4]
+		for i := 0; i < len(row); i += 4 {
+			row[i] = uint8(float64(row[i]) * k)
+			row[i+1] = uint8(float64(row[i+1]) * k)
+			row[i+2] = uint8(float64(row[i+2]) * k)
+		}
+	}
+}
+
+// ramp линейно растет от 0 в точке from до 1 в точке to
+func ramp(y, from, to int) float64 {
+	if from == to {
+		return 1
+	}
+	v := float64(y-from) / float64(to-from)
+	switch {
+	case v < 0:
+		return 0
+	case v > 1:
+		return 1
+	}
+	return v
+}
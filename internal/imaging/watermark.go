@@ -0,0 +1,163 @@
+package imaging
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"os"
+
+	"golang.org/x/image/draw"
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/gofont/gobold"
+	"golang.org/x/image/font/opentype"
+	"golang.org/x/image/math/fixed"
+)
+
+// Corner угол изображения для размещения водяного знака
+type Corner string
+
+const (
+	CornerTopLeft     Corner = "top-left"
+	CornerTopRight    Corner = "top-right"
+	CornerBottomLeft  Corner = "bottom-left"
+	CornerBottomRight Corner = "bottom-right"
+)
+
+const (
+	defaultLogoScale = 0.15  // Ширина логотипа относительно ширины изображения
+	defaultTextScale = 0.028 // Размер шрифта подписи относительно ширины изображения
+)
+
+// WatermarkOptions параметры водяного знака канала
+type WatermarkOptions struct {
+	ImageFile string  // PNG с логотипом; если пусто, рисуется Text
+	Text      string  // Подпись канала, например @offthepages
+	FontFile  string  // TTF/OTF для подписи, пусто - встроенный Go Bold
+	Scale     float64 // Ширина логотипа или размер шрифта относительно ширины, 0 - по умолчанию
+	Opacity   float64 // Непрозрачность от 0 до 1
+	Corner    Corner  // Угол, пусто - правый нижний
+	Margin    int     // Отступ от краев в пикселях
+}
+
+// Watermark накладывает логотип или подпись канала на изображение
+type Watermark struct {
+	opts WatermarkOptions
+	logo image.Image
+	font *opentype.Font
+}
+
+// NewWatermark загружает логотип или шрифт и проверяет параметры
+func NewWatermark(opts WatermarkOptions) (*Watermark, error) {
+	switch opts.Corner {
+	case "":
+		opts.Corner = CornerBottomRight
+	case CornerTopLeft, CornerTopRight, CornerBottomLeft, CornerBottomRight:
+	default:
+		return nil, fmt.Errorf("неизвестный угол водяного знака: %q", opts.Corner)
+	}
+	if opts.Opacity <= 0 || opts.Opacity > 1 {
+		return nil, fmt.Errorf("непрозрачность водяного знака должна быть больше 0 и не больше 1: %v", opts.Opacity)
+	}
+	if opts.Margin < 0 {
+		return nil, fmt.Errorf("отступ водяного знака не может быть отрицательным: %d", opts.Margin)
+	}
+
+	w := &Watermark{opts: opts}
+
+	if opts.ImageFile != "" {
+		data, err := os.ReadFile(opts.ImageFile)
+		if err != nil {
+			return nil, fmt.Errorf("ошибка чтения логотипа: %w", err)
+		}
+		logo, err := Decode(data)
+		if err != nil {
+			return nil, err
+		}
+		w.logo = logo
+		if w.opts.Scale <= 0 {
+			w.opts.Scale = defaultLogoScale
+		}
+		return w, nil
+	}
+
+	if opts.Text == "" {
+		return nil, fmt.Errorf("для водяного знака нужно указать файл логотипа или текст")
+	}
+	f, err := loadFont(opts.FontFile, gobold.TTF)
+	if err != nil {
+		return nil, err
+	}
+	w.font = f
+	if w.opts.Scale <= 0 {
+		w.opts.Scale = defaultTextScale
+	}
+
+	return w, nil
+}
+
+// Draw накладывает водяной знак на изображение
+func (w *Watermark) Draw(dst *image.RGBA) error {
+	if w.logo != nil {
+		w.drawLogo(dst)
+		return nil
+	}
+	return w.drawText(dst)
+}
+
+func (w *Watermark) drawLogo(dst *image.RGBA) {
+	lb := w.logo.Bounds()
+	width := int(float64(dst.Bounds().Dx()) * w.opts.Scale)
+	height := lb.Dy() * width / lb.Dx()
+	if width == 0 || height == 0 {
+		return
+	}
+
+	scaled := image.NewRGBA(image.Rect(0, 0, width, height))
+	draw.CatmullRom.Scale(scaled, scaled.Bounds(), w.logo, lb, draw.Src, nil)
+
+	at := w.origin(dst.Bounds(), width, height)
+	mask := image.NewUniform(color.Alpha{A: uint8(w.opts.Opacity * 255)})
+	draw.DrawMask(dst, image.Rect(at.X, at.Y, at.X+width, at.Y+height), scaled, image.Point{}, mask, image.Point{}, draw.Over)
+}
+
+func (w *Watermark) drawText(dst *image.RGBA) error {
+	size := w.opts.Scale * float64(dst.Bounds().Dx())
+	face, err := opentype.NewFace(w.font, &opentype.FaceOptions{Size: size, DPI: 72, Hinting: font.HintingFull})
+	if err != nil {
+		return fmt.Errorf("ошибка создания шрифта: %w", err)
+	}
+	defer face.Close()
+
+	width := font.MeasureString(face, w.opts.Text).Ceil()
+	height := face.Metrics().Height.Ceil()
+	at := w.origin(dst.Bounds(), width, height)
+	baseline := at.Y + face.Metrics().Ascent.Ceil()
+	alpha := uint8(w.opts.Opacity * 255)
+
+	d := &font.Drawer{Dst: dst, Face: face}
+
+	d.Src = image.NewUniform(color.NRGBA{0, 0, 0, alpha / 2})
+	d.Dot = fixed.P(at.X+1, baseline+1)
+	d.DrawString(w.opts.Text)
+
+	d.Src = image.NewUniform(color.NRGBA{255, 255, 255, alpha})
+	d.Dot = fixed.P(at.X, baseline)
+	d.DrawString(w.opts.Text)
+
+	return nil
+}
+
+// origin вычисляет левый верхний угол знака размером width x height
+func (w *Watermark) origin(b image.Rectangle, width, height int) image.Point {
+	m := w.opts.Margin
+	switch w.opts.Corner {
+	case CornerTopLeft:
+		return image.Pt(m, m)
+	case CornerTopRight:
+		return image.Pt(b.Dx()-m-width, m)
+	case CornerBottomLeft:
+		return image.Pt(m, b.Dy()-m-height)
+	default:
+		return image.Pt(b.Dx()-m-width, b.Dy()-m-height)
+	}
+}
@@ -0,0 +1,183 @@
+// Package lang описывает языки каналов: запросы к модели, кавычки для подписей
+// и определение языка текста по алфавиту и частым словам.
+package lang
+
+import (
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// Default язык по умолчанию
+const Default = "ru"
+
+// Language язык канала. Запросы к модели всегда просят ответ в формате
+// «цитата» — автор, потому что так его разбирает бот.
+type Language struct {
+	Code       string // ISO 639-1
+	Name       string // Название для модераторов
+	OpenQuote  string // Кавычки для подписи и наложения на изображение
+	CloseQuote string
+	Query      string // Запрос цитаты на языке канала, %s - тема
+	Original   string // Запрос цитаты на языке оригинала, %s - тема
+	Translate  string // Запрос перевода цитаты на язык канала, %s - цитата
+	Avoid      string // Шаблон ротации тем, запрещающий авторов на перерыве
+}
+
+// languages поддерживаемые языки. Русский запрос передается модели
+// без изменений, как и до появления языков.
+var languages = map[string]Language{
+	"ru": {
+		Code: "ru", Name: "русский", OpenQuote: "«", CloseQuote: "»",
+		Query:     "%s",
+		Original:  "%s. Приведи цитату на языке оригинала в формате «цитата» — автор",
+		Translate: "Переведи цитату на русский язык. Ответь только переводом в кавычках «». Цитата: %s",
+		Avoid:     `{{.Prompt}}{{if .AvoidAuthors}}. Не используй цитаты этих авторов: {{join .AvoidAuthors ", "}}{{end}}`,
+	},
+	"uk": {
+		Code: "uk", Name: "украинский", OpenQuote: "«", CloseQuote: "»",
+		Query:     "%s. Наведи відому цитату українською мовою у форматі «цитата» — автор",
+		Original:  "%s. Наведи відому цитату мовою оригіналу у форматі «цитата» — автор",
+		Translate: "Переклади цитату українською мовою. Відповідай лише перекладом у лапках «». Цитата: %s",
+		Avoid:     `{{.Prompt}}{{if .AvoidAuthors}}. Не використовуй цитати цих авторів: {{join .AvoidAuthors ", "}}{{end}}`,
+	},
+	"en": {
+		Code: "en", Name: "английский", OpenQuote: "“", CloseQuote: "”",
+		Query:     "%s. Give a well-known quote in English in the format «quote» — author",
+		Original:  "%s. Give a well-known quote in its original language in the format «quote» — author",
+		Translate: "Translate the quote into English. Reply only with the translation in «» quotes. Quote: %s",
+		Avoid:     `{{.Prompt}}{{if .AvoidAuthors}}. Do not use quotes by these authors: {{join .AvoidAuthors ", "}}{{end}}`,
+	},
+	"de": {
+		Code: "de", Name: "немецкий", OpenQuote: "„", CloseQuote: "“",
+		Query:     "%s. Nenne ein bekanntes Zitat auf Deutsch im Format «Zitat» — Autor",
+		Original:  "%s. Nenne ein bekanntes Zitat in der Originalsprache im Format «Zitat» — Autor",
+		Translate: "Übersetze das Zitat ins Deutsche. Antworte nur mit der Übersetzung in «». Zitat: %s",
+		Avoid:     `{{.Prompt}}{{if .AvoidAuthors}}. Verwende keine Zitate dieser Autoren: {{join .AvoidAuthors ", "}}{{end}}`,
+	},
+	"fr": {
+		Code: "fr", Name: "французский", OpenQuote: "« ", CloseQuote: " »",
+		Query:     "%s. Donne une citation célèbre en français au format «citation» — auteur",
+		Original:  "%s. Donne une citation célèbre dans sa langue originale au format «citation» — auteur",
+		Translate: "Traduis la citation en français. Réponds uniquement par la traduction entre «». Citation : %s",
+		Avoid:     `{{.Prompt}}{{if .AvoidAuthors}}. N'utilise pas de citations de ces auteurs : {{join .AvoidAuthors ", "}}{{end}}`,
+	},
+	"es": {
+		Code: "es", Name: "испанский", OpenQuote: "«", CloseQuote: "»",
+		Query:     "%s. Da una cita célebre en español con el formato «cita» — autor",
+		Original:  "%s. Da una cita célebre en su idioma original con el formato «cita» — autor",
+		Translate: "Traduce la cita al español. Responde solo con la traducción entre «». Cita: %s",
+		Avoid:     `{{.Prompt}}{{if .AvoidAuthors}}. No uses citas de estos autores: {{join .AvoidAuthors ", "}}{{end}}`,
+	},
+	"it": {
+		Code: "it", Name: "итальянский", OpenQuote: "«", CloseQuote: "»",
+		Query:     "%s. Scrivi una citazione famosa in italiano nel formato «citazione» — autore",
+		Original:  "%s. Scrivi una citazione famosa nella lingua originale nel formato «citazione» — autore",
+		Translate: "Traduci la citazione in italiano. Rispondi solo con la traduzione tra «». Citazione: %s",
+		Avoid:     `{{.Prompt}}{{if .AvoidAuthors}}. Non usare citazioni di questi autori: {{join .AvoidAuthors ", "}}{{end}}`,
+	},
+}
+
+// Get возвращает язык по коду
+func Get(code string) (Language, bool) {
+	l, ok := languages[code]
+	return l, ok
+}
+
+// Codes возвращает коды поддерживаемых языков по алфавиту
+func Codes() []string {
+	codes := make([]string, 0, len(languages))
+	for code := range languages {
+		codes = append(codes, code)
+	}
+	sort.Strings(codes)
+	return codes
+}
+
+// markers частые слова языка, которые редко встречаются в других языках
+// того же алфавита
+var markers = map[string][]string{
+	"ru": {"и", "не", "что", "он", "как", "это", "все", "она", "так", "его", "но", "ты", "вы", "бы", "только", "мне", "было", "вот", "от", "меня", "еще", "нет", "из", "кто", "когда", "быть", "если", "чем", "жизнь", "себя", "есть", "мы", "был", "где", "всегда", "никогда", "человек"},
+	"uk": {"і", "та", "що", "він", "як", "це", "все", "вона", "його", "але", "ти", "ви", "б", "тільки", "мені", "було", "від", "мене", "ще", "ні", "із", "хто", "коли", "бути", "якщо", "ніж", "життя", "себе", "є", "ми", "був", "де", "завжди", "ніколи", "людина"},
+	"en": {"the", "and", "is", "of", "to", "you", "that", "it", "in", "not", "be", "what", "are", "your", "life", "who", "we", "but", "with", "have", "love", "will", "never", "only", "can", "when", "all", "my", "he", "his", "there"},
+	"de": {"der", "die", "das", "und", "ist", "nicht", "ich", "du", "zu", "ein", "eine", "sie", "es", "mit", "wer", "was", "wir", "auf", "sich", "man", "nur", "leben", "liebe", "kann", "nie", "wenn", "auch", "aber", "sein", "ohne"},
+	"fr": {"le", "la", "les", "et", "est", "pas", "je", "tu", "un", "une", "qui", "que", "des", "du", "vous", "nous", "il", "elle", "en", "ce", "ne", "vie", "amour", "dans", "pour", "sur", "mais", "avec", "jamais", "toujours", "on"},
+	"es": {"el", "la", "los", "las", "y", "es", "no", "que", "un", "una", "de", "en", "por", "para", "con", "se", "lo", "su", "vida", "amor", "pero", "más", "como", "nunca", "siempre", "quien", "hay", "ser", "sin", "del"},
+	"it": {"il", "la", "lo", "gli", "e", "è", "non", "che", "un", "una", "di", "per", "con", "si", "ma", "più", "come", "vita", "amore", "chi", "sempre", "mai", "sono", "della", "nel", "del", "anche", "essere", "senza", "ci"},
+}
+
+// letters буквы, которые встречаются только в одном из языков алфавита
+var letters = map[string]string{
+	"ru": "ыэъё",
+	"uk": "іїєґ",
+	"de": "äöüß",
+	"fr": "çêëœâû",
+	"es": "ñ¿¡",
+	"it": "ìò",
+}
+
+// minLetters минимальное число букв, по которому определяется язык:
+// в более коротком тексте одно частое слово решает исход случайно
+const minLetters = 10
+
+// Detect определяет язык текста. Возвращает false, если текст слишком
+// короткий или признаки разных языков равны: такой ответ не отклоняется.
+func Detect(text string) (string, bool) {
+	text = strings.ToLower(text)
+
+	var cyrillic, latin int
+	for _, r := range text {
+		switch {
+		case unicode.Is(unicode.Cyrillic, r):
+			cyrillic++
+		case unicode.Is(unicode.Latin, r):
+			latin++
+		}
+	}
+	if cyrillic+latin < minLetters {
+		return "", false
+	}
+
+	candidates := []string{"en", "de", "fr", "es", "it"}
+	if cyrillic > latin {
+		candidates = []string{"ru", "uk"}
+	}
+
+	words := strings.FieldsFunc(text, func(r rune) bool {
+		return !unicode.IsLetter(r) && r != '\''
+	})
+
+	scores := make(map[string]int, len(candidates))
+	for _, code := range candidates {
+		set := make(map[string]bool, len(markers[code]))
+		for _, w := range markers[code] {
+			set[w] = true
+		}
+		for _, w := range words {
+			if set[w] {
+				scores[code]++
+			}
+		}
+		// Особая буква весит как несколько слов
+		for _, r := range letters[code] {
+			if strings.ContainsRune(text, r) {
+				scores[code] += 3
+			}
+		}
+	}
+
+	best, second := "", 0
+	for _, code := range candidates {
+		switch {
+		case best == "" || scores[code] > scores[best]:
+			second = scores[best]
+			best = code
+		case scores[code] > second:
+			second = scores[code]
+		}
+	}
+	if scores[best] == 0 || scores[best] == second {
+		return "", false
+	}
+	return best, true
+}
@@ -0,0 +1,31 @@
+package lang
+
+import "testing"
+
+func TestDetect(t *testing.T) {
+	tests := []struct {
+		text string
+		want string // Пусто - язык не определен
+	}{
+		{"Жизнь — это то, что с тобой происходит, пока ты строишь планы", "ru"},
+		{"Мы в ответе за тех, кого приручили", "ru"},
+		{"Життя — це те, що відбувається, поки ти будуєш плани", "uk"},
+		{"Life is what happens to you while you are busy making other plans", "en"},
+		{"Was mich nicht umbringt, macht mich stärker", "de"},
+		{"On ne voit bien qu'avec le cœur, l'essentiel est invisible pour les yeux", "fr"},
+		{"Caminante, no hay camino, se hace camino al andar", "es"},
+		{"Fatti non foste a viver come bruti, ma per seguir virtute e canoscenza", "it"},
+		{"Не все", ""},        // Слишком короткий текст
+		{"Carpe diem", ""},    // Слишком короткий текст
+		{"Qwerty zxcvbn", ""}, // Нет признаков ни одного языка
+		{"1984 — 2024!", ""},  // Нет букв
+		{"", ""},
+	}
+
+	for _, tt := range tests {
+		got, ok := Detect(tt.text)
+		if ok != (tt.want != "") || got != tt.want {
+			t.Errorf("Detect(%q) = %q, %v; ожидалось %q", tt.text, got, ok, tt.want)
+		}
+	}
+}
@@ -0,0 +1,160 @@
+package logging
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log"
+	"log/slog"
+	"strings"
+)
+
+// CorrelationKey имя атрибута с идентификатором генерации
+const CorrelationKey = "cid"
+
+const redacted = "[REDACTED]"
+
+// sensitiveKeys атрибуты, значения которых никогда не попадают в лог
+var sensitiveKeys = []string{"api_key", "apikey", "token", "authorization", "secret", "password"}
+
+type ctxKey struct{}
+
+// Setup настраивает логгер по умолчанию: уровень (debug, info, warn, error),
+// формат (text или json) и список секретов, которые вырезаются из всех сообщений.
+// Стандартный пакет log после вызова пишет через тот же обработчик.
+func Setup(w io.Writer, level, format string, secrets ...string) error {
+	var lvl slog.Level
+	if err := lvl.UnmarshalText([]byte(level)); err != nil {
+		return fmt.Errorf("неизвестный уровень логирования: %q", level)
+	}
+
+	opts := &slog.HandlerOptions{Level: lvl, ReplaceAttr: redactKeys}
+
+	var handler slog.Handler
+	switch strings.ToLower(format) {
+	case "", "text":
+		handler = slog.NewTextHandler(w, opts)
+	case "json":
+		handler = slog.NewJSONHandler(w, opts)
+	default:
+		return fmt.Errorf("неизвестный формат логов: %q", format)
+	}
+
+	slog.SetDefault(slog.New(&contextHandler{Handler: newRedactor(handler, secrets)}))
+	return nil
+}
+
+// StdLogger возвращает *log.Logger поверх slog для библиотек,
+// которые принимают стандартный логгер
+func StdLogger(level slog.Level) *log.Logger {
+	return slog.NewLogLogger(slog.Default().Handler(), level)
+}
+
+// WithCorrelationID добавляет в контекст новый идентификатор генерации
+func WithCorrelationID(ctx context.Context) context.Context {
+	b := make([]byte, 6)
+	_, _ = rand.Read(b)
+	return context.WithValue(ctx, ctxKey{}, hex.EncodeToString(b))
+}
+
+// CorrelationID возвращает идентификатор генерации из контекста
+func CorrelationID(ctx context.Context) string {
+	id, _ := ctx.Value(ctxKey{}).(string)
+	return id
+}
+
+// contextHandler добавляет к записи идентификатор генерации из контекста
+type contextHandler struct {
+	slog.Handler
+}
+
+func (h *contextHandler) Handle(ctx context.Context, r slog.Record) error {
+	if id := CorrelationID(ctx); id != "" {
+		r.AddAttrs(slog.String(CorrelationKey, id))
+	}
+	return h.Handler.Handle(ctx, r)
+}
+
+func (h *contextHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &contextHandler{Handler: h.Handler.WithAttrs(attrs)}
+}
+
+func (h *contextHandler) WithGroup(name string) slog.Handler {
+	return &contextHandler{Handler: h.Handler.WithGroup(name)}
+}
+
+// redactKeys скрывает значения атрибутов с чувствительными именами,
+// например token, bot_token или api_key
+func redactKeys(_ []string, a slog.Attr) slog.Attr {
+	key := strings.ToLower(a.Key)
+	for _, s := range sensitiveKeys {
+		if key == s || strings.HasSuffix(key, "_"+s) {
+			return slog.String(a.Key, redacted)
+		}
+	}
+	return a
+}
+
+// redactor вырезает значения секретов из сообщения и строковых атрибутов,
+// например ключ API в тексте ошибки или токен бота в URL
+type redactor struct {
+	slog.Handler
+	replacer *strings.Replacer
+}
+
+func newRedactor(h slog.Handler, secrets []string) slog.Handler {
+	var pairs []string
+	for _, s := range secrets {
+		if s != "" {
+			pairs = append(pairs, s, redacted)
+		}
+	}
+	if len(pairs) == 0 {
+		return h
+	}
+	return &redactor{Handler: h, replacer: strings.NewReplacer(pairs...)}
+}
+
+func (h *redactor) Handle(ctx context.Context, r slog.Record) error {
+	clean := slog.NewRecord(r.Time, r.Level, h.replacer.Replace(r.Message), r.PC)
+	r.Attrs(func(a slog.Attr) bool {
+		clean.AddAttrs(h.redact(a))
+		return true
+	})
+	return h.Handler.Handle(ctx, clean)
+}
+
+func (h *redactor) redact(a slog.Attr) slog.Attr {
+	v := a.Value.Resolve()
+	switch v.Kind() {
+	case slog.KindString:
+		return slog.String(a.Key, h.replacer.Replace(v.String()))
+	case slog.KindGroup:
+		attrs := v.Group()
+		clean := make([]any, len(attrs))
+		for i, ga := range attrs {
+			clean[i] = h.redact(ga)
+		}
+		return slog.Group(a.Key, clean...)
+	case slog.KindAny:
+		if err, ok := v.Any().(error); ok {
+			return slog.String(a.Key, h.replacer.Replace(err.Error()))
+		}
+		return slog.String(a.Key, h.replacer.Replace(fmt.Sprint(v.Any())))
+	}
+	return a
+}
+
+func (h *redactor) WithAttrs(attrs []slog.Attr) slog.Handler {
+	clean := make([]slog.Attr, len(attrs))
+	for i, a := range attrs {
+		clean[i] = h.redact(a)
+	}
+	return &redactor{Handler: h.Handler.WithAttrs(clean), replacer: h.replacer}
+}
+
+func (h *redactor) WithGroup(name string) slog.Handler {
+	return &redactor{Handler: h.Handler.WithGroup(name), replacer: h.replacer}
+}
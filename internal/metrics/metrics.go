@@ -0,0 +1,127 @@
+package metrics
+
+import (
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "tgchanpost"
+
+// Провайдеры внешних API
+const (
+	ProviderLLM = "llm"
+	ProviderArt = "art"
+)
+
+var (
+	// UpdatesHandled количество обработанных обновлений Telegram по типу
+	UpdatesHandled = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "updates_handled_total",
+		Help:      "Обработанные обновления Telegram по типу и результату.",
+	}, []string{"type", "result"})
+
+	// ProviderDuration длительность вызовов YandexGPT и YandexART
+	ProviderDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "provider_request_duration_seconds",
+		Help:      "Длительность вызовов LLM и генерации изображений.",
+		Buckets:   []float64{0.25, 0.5, 1, 2, 5, 10, 20, 30, 60, 120},
+	}, []string{"provider"})
+
+	// ProviderErrors ошибки вызовов по провайдеру и коду ответа
+	ProviderErrors = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "provider_errors_total",
+		Help:      "Ошибки вызовов LLM и генерации изображений по HTTP-коду или виду ошибки.",
+	}, []string{"provider", "code"})
+
+	// ParseFailures ответы модели, из которых не удалось извлечь цитату
+	ParseFailures = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "quote_parse_failures_total",
+		Help:      "Ответы модели, из которых не удалось извлечь цитату и автора.",
+	})
+
+	// DraftsCreated черновики, отправленные на превью
+	DraftsCreated = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "drafts_created_total",
+		Help:      "Черновики, отправленные модератору на превью.",
+	}, []string{"channel"})
+
+	// PostsPublished посты, опубликованные в каналы
+	PostsPublished = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "posts_published_total",
+		Help:      "Посты, опубликованные в каналы.",
+	}, []string{"channel"})
+
+	// TokensUsed токены YandexGPT по каналу и виду: input или completion
+	TokensUsed = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "tokens_used_total",
+		Help:      "Токены YandexGPT по каналу и виду (input, completion).",
+	}, []string{"channel", "kind"})
+
+	// Spend расходы на вызовы по провайдеру и каналу в валюте из настроек
+	Spend = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "spend_total",
+		Help:      "Стоимость вызовов LLM и генерации изображений по ценам из конфигурации.",
+	}, []string{"provider", "channel"})
+
+	// RateLimited запросы, отклоненные ограничением частоты
+	RateLimited = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "rate_limited_total",
+		Help:      "Запросы на генерацию, отклоненные ограничением частоты (user, chat).",
+	}, []string{"scope"})
+
+	// ImagesInFlight генерации изображений, выполняющиеся сейчас
+	ImagesInFlight = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "image_generations_in_flight",
+		Help:      "Генерации YandexART, выполняющиеся в данный момент.",
+	})
+
+	// QueueDepth количество обновлений, ожидающих обработки
+	QueueDepth = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "update_queue_depth",
+		Help:      "Обновления Telegram, полученные, но еще не обработанные.",
+	})
+)
+
+// ObserveProvider записывает длительность вызова провайдера
+func ObserveProvider(provider string, start time.Time) {
+	ProviderDuration.WithLabelValues(provider).Observe(time.Since(start).Seconds())
+}
+
+// Serve запускает HTTP-сервер с эндпоинтом /metrics в фоне.
+// Пустой адрес отключает метрики.
+func Serve(addr string) {
+	if addr == "" {
+		return
+	}
+
+	mux := http.NewServeMux()
+	Register(mux)
+
+	go func() {
+		slog.Info("Метрики доступны", "addr", addr, "path", "/metrics")
+		if err := http.ListenAndServe(addr, mux); err != nil {
+			slog.Error("Ошибка сервера метрик", "err", err)
+		}
+	}()
+}
+
+// Register добавляет обработчик /metrics, например на общий админский сервер
+func Register(mux *http.ServeMux) {
+	mux.Handle("/metrics", promhttp.Handler())
+}
@@ -8,11 +8,117 @@ type PromtReq struct {
 	UserQuery string    `json:"user_query"`
 	Quote     string    `json:"quote"`
 	Author    string    `json:"author"`
+	FileID    string    `json:"file_id,omitempty"`
 	Timestamp time.Time `json:"timestamp"`
 }
 
 // FormattedResponse structure for YandexGPT response format
 type FormattedResponse struct {
-	Response string `json:"response"`
-	Status   string `json:"status"`
+	Response     string `json:"response"`
+	Status       string `json:"status"`
+	Usage        Usage  `json:"usage"`
+	ModelVersion string `json:"model_version,omitempty"`
+}
+
+// Usage расход токенов на запрос к модели
+type Usage struct {
+	InputTokens      int64 `json:"input_tokens"`
+	CompletionTokens int64 `json:"completion_tokens"`
+	TotalTokens      int64 `json:"total_tokens"`
+}
+
+// Draft черновик поста, отправленный модератору на превью
+type Draft struct {
+	ChatID    int64  `json:"chat_id"`
+	MessageID int    `json:"message_id"` // ID сообщения с превью
+	ChannelID string `json:"channel_id"` // Канал, для которого подготовлен пост
+	Quote     string `json:"quote"`
+	Author    string `json:"author"`
+	Caption   string `json:"caption"`
+	ParseMode string `json:"parse_mode"`
+	FileID    string `json:"file_id"`            // Telegram file_id загруженного изображения
+	ArtFile   string `json:"art_file,omitempty"` // Оригинальное изображение без оформления
+	Topic     string `json:"topic,omitempty"`    // Тема запроса, по которой подобрана цитата
+	Style     string `json:"style,omitempty"`    // Оформление изображения: caption или overlay
+	Original  string `json:"original,omitempty"` // Цитата на языке оригинала, если Quote - перевод
+	Language  string `json:"language,omitempty"` // Язык оригинала по определению бота
+	// Verification результат сверки с корпусом цитат: verified, author_mismatch или unverified
+	Verification string    `json:"verification,omitempty"`
+	CorpusAuthor string    `json:"corpus_author,omitempty"` // Автор цитаты по данным корпуса
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+// UsageEntry расход за день в разрезе чата, пользователя и канала
+type UsageEntry struct {
+	Date             string  `json:"date"` // День в формате 2006-01-02 по местному времени
+	ChatID           int64   `json:"chat_id"`
+	UserID           int64   `json:"user_id"`
+	ChannelID        string  `json:"channel_id"`
+	InputTokens      int64   `json:"input_tokens"`
+	CompletionTokens int64   `json:"completion_tokens"`
+	Images           int64   `json:"images"`
+	Cost             float64 `json:"cost"` // Стоимость по ценам на момент вызова
+}
+
+// Статусы элемента очереди публикации
+const (
+	QueueQueued    = "queued"    // Ожидает своего слота
+	QueuePublished = "published" // Опубликован в канал
+	QueueFailed    = "failed"    // Не удалось опубликовать после нескольких попыток
+)
+
+// QueueItem черновик в очереди публикации канала
+type QueueItem struct {
+	ID          int64     `json:"id"`
+	Draft       Draft     `json:"draft"`
+	Status      string    `json:"status"`
+	PublishAt   time.Time `json:"publish_at"` // Слот публикации
+	QueuedAt    time.Time `json:"queued_at"`
+	QueuedBy    int64     `json:"queued_by,omitempty"` // Telegram ID редактора
+	Attempts    int       `json:"attempts,omitempty"`
+	LastError   string    `json:"last_error,omitempty"`
+	MessageID   int       `json:"message_id,omitempty"` // ID сообщения в канале после публикации
+	PublishedAt time.Time `json:"published_at,omitempty"`
+}
+
+// Post пост, опубликованный в канал
+type Post struct {
+	ID               int64     `json:"id"`
+	ChannelID        string    `json:"channel_id"`
+	MessageID        int       `json:"message_id"`                   // Сообщение с изображением в канале
+	CaptionMessageID int       `json:"caption_message_id,omitempty"` // Текст отдельным сообщением, если подпись не поместилась
+	Quote            string    `json:"quote"`
+	Author           string    `json:"author"`
+	Caption          string    `json:"caption"`
+	ParseMode        string    `json:"parse_mode"`
+	FileID           string    `json:"file_id"`
+	ArtFile          string    `json:"art_file,omitempty"`
+	DraftChatID      int64     `json:"draft_chat_id"` // Чат, из которого пост был опубликован
+	PublishedBy      int64     `json:"published_by,omitempty"`
+	PublishedAt      time.Time `json:"published_at"`
+	EditedAt         time.Time `json:"edited_at,omitempty"`
+	Pinned           bool      `json:"pinned,omitempty"`
+	Deleted          bool      `json:"deleted,omitempty"`
+	Topic            string    `json:"topic,omitempty"`
+	Style            string    `json:"style,omitempty"`
+	Original         string    `json:"original,omitempty"`
+	Language         string    `json:"language,omitempty"`
+	Stats            PostStats `json:"stats"`
+}
+
+// PostStats статистика поста по обновлениям, которые получает бот.
+// Просмотры Bot API не передает, поэтому их здесь нет.
+type PostStats struct {
+	Reactions map[string]int `json:"reactions,omitempty"` // Число реакций по эмодзи
+	Forwards  int            `json:"forwards,omitempty"`  // Пересылки в чаты, где есть бот
+	UpdatedAt time.Time      `json:"updated_at,omitempty"`
+}
+
+// TotalReactions общее число реакций
+func (s PostStats) TotalReactions() int {
+	total := 0
+	for _, n := range s.Reactions {
+		total += n
+	}
+	return total
 }
@@ -0,0 +1,232 @@
+package quotes
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/d1mk9/tgChanPost/internal/authors"
+	"github.com/d1mk9/tgChanPost/internal/storage"
+)
+
+// Status результат проверки атрибуции цитаты
+type Status string
+
+const (
+	StatusVerified       Status = "verified"        // Цитата найдена, автор совпадает
+	StatusAuthorMismatch Status = "author_mismatch" // Цитата найдена, но у другого автора
+	StatusUnverified     Status = "unverified"      // Цитаты нет в корпусе
+)
+
+// minFragmentLength минимальная длина фрагмента для поиска по вхождению,
+// чтобы короткие фразы не совпадали со случайными цитатами
+const minFragmentLength = 20
+
+// Quote известная цитата из корпуса
+type Quote struct {
+	Text   string `json:"text"`
+	Author string `json:"author"`
+	Source string `json:"source,omitempty"` // Произведение, необязательно
+}
+
+// Result результат проверки цитаты
+type Result struct {
+	Status Status
+	Match  Quote // Найденная цитата для статусов verified и author_mismatch
+}
+
+// Corpus локальный корпус известных цитат
+type Corpus struct {
+	mu       sync.RWMutex
+	path     string
+	registry *authors.Registry
+	quotes   []Quote
+	byText   map[string]int
+}
+
+// LoadCorpus читает корпус из JSON-файла, в который сохраняются импортированные цитаты.
+// Если файла нет, корпус пустой.
+func LoadCorpus(path string, registry *authors.Registry) (*Corpus, error) {
+	c := &Corpus{path: path, registry: registry, byText: make(map[string]int)}
+
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return c, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("ошибка чтения корпуса цитат: %w", err)
+	}
+
+	quotes, err := ParseJSON(data)
+	if err != nil {
+		return nil, err
+	}
+	c.add(quotes)
+
+	return c, nil
+}
+
+// Len возвращает количество цитат в корпусе
+func (c *Corpus) Len() int {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return len(c.quotes)
+}
+
+// Import добавляет новые цитаты в корпус и сохраняет его.
+// Возвращает количество добавленных цитат без учета дублей.
+func (c *Corpus) Import(quotes []Quote) (int, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	added := c.add(quotes)
+	if added == 0 {
+		return 0, nil
+	}
+
+	if err := storage.WriteJSON(c.path, c.quotes); err != nil {
+		return added, fmt.Errorf("ошибка записи корпуса цитат: %w", err)
+	}
+
+	return added, nil
+}
+
+// add добавляет цитаты без дублей; вызывается под мьютексом или при загрузке
+func (c *Corpus) add(quotes []Quote) int {
+	var added int
+	for _, q := range quotes {
+		q.Text = strings.TrimSpace(q.Text)
+		q.Author = c.registry.Canonical(q.Author)
+		key := textKey(q.Text)
+		if key == "" {
+			continue
+		}
+		if _, ok := c.byText[key]; ok {
+			continue
+		}
+		c.byText[key] = len(c.quotes)
+		c.quotes = append(c.quotes, q)
+		added++
+	}
+	return added
+}
+
+// Verify сверяет цитату и автора с корпусом. Цитата ищется по точному
+// совпадению текста без учета регистра и пунктуации, а затем как фрагмент
+// известной цитаты: модели часто обрезают или дополняют текст.
+func (c *Corpus) Verify(text, author string) Result {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	key := textKey(text)
+	if key == "" {
+		return Result{Status: StatusUnverified}
+	}
+
+	var candidates []Quote
+	if i, ok := c.byText[key]; ok {
+		candidates = append(candidates, c.quotes[i])
+	} else if utf8.RuneCountInString(key) >= minFragmentLength {
+		for _, q := range c.quotes {
+			known := textKey(q.Text)
+			if strings.Contains(known, key) || (utf8.RuneCountInString(known) >= minFragmentLength && strings.Contains(key, known)) {
+				candidates = append(candidates, q)
+			}
+		}
+	}
+
+	if len(candidates) == 0 {
+		return Result{Status: StatusUnverified}
+	}
+
+	authorKey := c.registry.CanonicalKey(author)
+	for _, q := range candidates {
+		if c.registry.CanonicalKey(q.Author) == authorKey {
+			return Result{Status: StatusVerified, Match: q}
+		}
+	}
+	return Result{Status: StatusAuthorMismatch, Match: candidates[0]}
+}
+
+// Key приводит текст цитаты к виду для сравнения: без регистра,
+// пунктуации и различия е/ё. Используется для поиска повторов.
+func Key(text string) string {
+	return textKey(text)
+}
+
+// textKey приводит текст цитаты к виду для сравнения
+func textKey(text string) string {
+	var sb strings.Builder
+	for _, r := range strings.ToLower(text) {
+		switch {
+		case r == 'ё':
+			sb.WriteRune('е')
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			sb.WriteRune(r)
+		default:
+			sb.WriteRune(' ')
+		}
+	}
+	return strings.Join(strings.Fields(sb.String()), " ")
+}
+
+// Parse разбирает файл с цитатами по расширению: .json или .csv
+func Parse(name string, data []byte) ([]Quote, error) {
+	switch strings.ToLower(filepath.Ext(name)) {
+	case ".json":
+		return ParseJSON(data)
+	case ".csv":
+		return ParseCSV(data)
+	default:
+		return nil, fmt.Errorf("неподдерживаемый формат файла цитат: %s", name)
+	}
+}
+
+// ParseJSON разбирает массив объектов {"text", "author", "source"}
+func ParseJSON(data []byte) ([]Quote, error) {
+	var quotes []Quote
+	if err := json.Unmarshal(data, &quotes); err != nil {
+		return nil, fmt.Errorf("ошибка декодирования цитат: %w", err)
+	}
+	return quotes, nil
+}
+
+// ParseCSV разбирает строки «цитата,автор[,источник]».
+// Заголовок с колонками text и author пропускается.
+func ParseCSV(data []byte) ([]Quote, error) {
+	data = bytes.TrimPrefix(data, []byte("\xef\xbb\xbf")) // BOM из Excel
+	r := csv.NewReader(bytes.NewReader(data))
+	r.FieldsPerRecord = -1
+
+	var quotes []Quote
+	for line := 1; ; line++ {
+		record, err := r.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("ошибка чтения CSV: %w", err)
+		}
+		if len(record) < 2 {
+			return nil, fmt.Errorf("строка %d: ожидается цитата и автор", line)
+		}
+		if line == 1 && strings.EqualFold(strings.TrimSpace(record[0]), "text") {
+			continue
+		}
+
+		q := Quote{Text: record[0], Author: record[1]}
+		if len(record) > 2 {
+			q.Source = record[2]
+		}
+		quotes = append(quotes, q)
+	}
+	return quotes, nil
+}
@@ -0,0 +1,193 @@
+// Package ratelimit содержит ограничители частоты запросов (token bucket по ключу)
+// и числа одновременных операций. Лимиты передаются при каждом вызове,
+// поэтому их можно менять без пересоздания ограничителей.
+package ratelimit
+
+import (
+	"context"
+	"math"
+	"sync"
+	"time"
+)
+
+// pruneInterval период удаления полностью восстановившихся корзин
+const pruneInterval = 10 * time.Minute
+
+// Limit скорость пополнения и емкость корзины. Нулевой PerMinute - без ограничений.
+type Limit struct {
+	PerMinute float64
+	Burst     int
+}
+
+// Unlimited сообщает, что ограничение не задано
+func (l Limit) Unlimited() bool {
+	return l.PerMinute <= 0
+}
+
+// Capacity емкость корзины: сколько запросов проходит подряд
+func (l Limit) Capacity() int {
+	if l.Burst < 1 {
+		return 1
+	}
+	return l.Burst
+}
+
+func (l Limit) capacity() float64 {
+	return float64(l.Capacity())
+}
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+	limit  Limit
+}
+
+// refill пополняет корзину к моменту now
+func (b *bucket) refill(now time.Time, limit Limit) {
+	elapsed := now.Sub(b.last).Minutes()
+	if elapsed > 0 {
+		b.tokens = math.Min(limit.capacity(), b.tokens+elapsed*limit.PerMinute)
+		b.last = now
+	}
+	if b.tokens > limit.capacity() {
+		b.tokens = limit.capacity()
+	}
+	b.limit = limit
+}
+
+// Limiter набор корзин по ключам, например user:42 и chat:42
+type Limiter struct {
+	mu        sync.Mutex
+	buckets   map[string]*bucket
+	lastPrune time.Time
+}
+
+// New создает пустой Limiter
+func New() *Limiter {
+	return &Limiter{buckets: make(map[string]*bucket), lastPrune: time.Now()}
+}
+
+// Reservation ключ и лимит для Allow
+type Reservation struct {
+	Key   string
+	Limit Limit
+}
+
+// Allow списывает по одному токену из каждой корзины, только если токены есть
+// во всех; иначе ничего не списывает и возвращает ключ первой пустой корзины
+// и время до появления в ней токена
+func (l *Limiter) Allow(now time.Time, reservations ...Reservation) (ok bool, key string, retryAfter time.Duration) {
+	return l.AllowN(now, 1, reservations...)
+}
+
+// AllowN работает как Allow, но списывает n токенов из каждой корзины.
+// n больше емкости корзины не пройдет никогда, это проверяет вызывающий.
+func (l *Limiter) AllowN(now time.Time, n int, reservations ...Reservation) (ok bool, key string, retryAfter time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.prune(now)
+
+	buckets := make([]*bucket, len(reservations))
+	for i, r := range reservations {
+		if r.Limit.Unlimited() {
+			continue
+		}
+		b, exists := l.buckets[r.Key]
+		if !exists {
+			b = &bucket{tokens: r.Limit.capacity(), last: now}
+			l.buckets[r.Key] = b
+		}
+		b.refill(now, r.Limit)
+		if b.tokens < float64(n) {
+			wait := time.Duration((float64(n) - b.tokens) / r.Limit.PerMinute * float64(time.Minute))
+			return false, r.Key, wait
+		}
+		buckets[i] = b
+	}
+
+	for _, b := range buckets {
+		if b != nil {
+			b.tokens -= float64(n)
+		}
+	}
+	return true, "", 0
+}
+
+// prune удаляет корзины, которые успели заполниться: их состояние
+// совпадает с новой корзиной. Вызывается под мьютексом.
+func (l *Limiter) prune(now time.Time) {
+	if now.Sub(l.lastPrune) < pruneInterval {
+		return
+	}
+	l.lastPrune = now
+	for key, b := range l.buckets {
+		b.refill(now, b.limit)
+		if b.tokens >= b.limit.capacity() {
+			delete(l.buckets, key)
+		}
+	}
+}
+
+// Semaphore ограничивает число одновременных операций.
+// Лимит меняется на ходу; 0 - без ограничений.
+type Semaphore struct {
+	mu    sync.Mutex
+	limit int
+	busy  int
+	wake  chan struct{} // Закрывается при освобождении места или смене лимита
+}
+
+// NewSemaphore создает Semaphore с лимитом
+func NewSemaphore(limit int) *Semaphore {
+	return &Semaphore{limit: limit, wake: make(chan struct{})}
+}
+
+// Acquire занимает место, ожидая его освобождения до отмены ctx
+func (s *Semaphore) Acquire(ctx context.Context) error {
+	for {
+		s.mu.Lock()
+		if s.limit <= 0 || s.busy < s.limit {
+			s.busy++
+			s.mu.Unlock()
+			return nil
+		}
+		wake := s.wake
+		s.mu.Unlock()
+
+		select {
+		case <-wake:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// Release освобождает место, занятое Acquire
+func (s *Semaphore) Release() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.busy--
+	s.notify()
+}
+
+// SetLimit меняет лимит; уже начатые операции не прерываются
+func (s *Semaphore) SetLimit(limit int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.limit = limit
+	s.notify()
+}
+
+// InFlight количество занятых мест
+func (s *Semaphore) InFlight() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.busy
+}
+
+// notify будит ожидающих; вызывается под мьютексом
+func (s *Semaphore) notify() {
+	close(s.wake)
+	s.wake = make(chan struct{})
+}
@@ -0,0 +1,171 @@
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestLimiterRefill(t *testing.T) {
+	t0 := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	limit := Limit{PerMinute: 6, Burst: 2} // Токен раз в 10 секунд, до двух подряд
+
+	type step struct {
+		at    time.Duration
+		ok    bool
+		retry time.Duration
+	}
+	tests := []struct {
+		name  string
+		limit Limit
+		steps []step
+	}{
+		{
+			name:  "burst и пополнение",
+			limit: limit,
+			steps: []step{
+				{at: 0, ok: true},
+				{at: 0, ok: true},
+				{at: 0, ok: false, retry: 10 * time.Second},
+				{at: 5 * time.Second, ok: false, retry: 5 * time.Second},
+				{at: 10 * time.Second, ok: true},
+				{at: 10 * time.Second, ok: false, retry: 10 * time.Second},
+			},
+		},
+		{
+			name:  "корзина не переполняется",
+			limit: limit,
+			steps: []step{
+				{at: 0, ok: true},
+				{at: 0, ok: true},
+				{at: time.Hour, ok: true},
+				{at: time.Hour, ok: true},
+				{at: time.Hour, ok: false, retry: 10 * time.Second},
+			},
+		},
+		{
+			name:  "нулевой burst - один запрос",
+			limit: Limit{PerMinute: 1},
+			steps: []step{
+				{at: 0, ok: true},
+				{at: 30 * time.Second, ok: false, retry: 30 * time.Second},
+				{at: time.Minute, ok: true},
+			},
+		},
+		{
+			name:  "без ограничений",
+			limit: Limit{},
+			steps: []step{
+				{at: 0, ok: true},
+				{at: 0, ok: true},
+				{at: 0, ok: true},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l := New()
+			for i, s := range tt.steps {
+				ok, key, retry := l.Allow(t0.Add(s.at), Reservation{Key: "user:1", Limit: tt.limit})
+				if ok != s.ok || retry != s.retry {
+					t.Fatalf("шаг %d (+%s): ok=%v retry=%s, ожидалось ok=%v retry=%s", i+1, s.at, ok, retry, s.ok, s.retry)
+				}
+				if !ok && key != "user:1" {
+					t.Fatalf("шаг %d: ключ %q", i+1, key)
+				}
+			}
+		})
+	}
+}
+
+func TestLimiterAllOrNothing(t *testing.T) {
+	now := time.Now()
+	l := New()
+	user := Reservation{Key: "user:1", Limit: Limit{PerMinute: 1, Burst: 2}}
+	chat := Reservation{Key: "chat:1", Limit: Limit{PerMinute: 1, Burst: 1}}
+
+	if ok, _, _ := l.Allow(now, user, chat); !ok {
+		t.Fatal("первый запрос должен пройти")
+	}
+	// Корзина чата пуста, поэтому токен пользователя не списывается
+	if ok, key, _ := l.Allow(now, user, chat); ok || key != "chat:1" {
+		t.Fatalf("ожидался отказ по чату, получено ok=%v key=%q", ok, key)
+	}
+	if ok, _, _ := l.Allow(now, user); !ok {
+		t.Fatal("у пользователя должен остаться токен")
+	}
+}
+
+func TestLimiterAllowN(t *testing.T) {
+	now := time.Now()
+	l := New()
+	user := Reservation{Key: "user:1", Limit: Limit{PerMinute: 6, Burst: 5}}
+
+	if ok, _, _ := l.AllowN(now, 3, user); !ok {
+		t.Fatal("пакет из 3 запросов должен пройти")
+	}
+	// Осталось 2 токена: пакет из 3 ждет еще одного, токен раз в 10 секунд
+	ok, _, retry := l.AllowN(now, 3, user)
+	if ok || retry != 10*time.Second {
+		t.Fatalf("ожидался отказ с ожиданием 10s, получено ok=%v retry=%s", ok, retry)
+	}
+	if ok, _, _ := l.AllowN(now, 2, user); !ok {
+		t.Fatal("оставшиеся 2 токена должны списываться")
+	}
+	if ok, _, _ := l.Allow(now, user); ok {
+		t.Fatal("корзина должна быть пуста")
+	}
+	if capacity := (Limit{PerMinute: 1}).Capacity(); capacity != 1 {
+		t.Fatalf("емкость без burst %d, ожидалась 1", capacity)
+	}
+}
+
+func TestLimiterLimitChange(t *testing.T) {
+	now := time.Now()
+	l := New()
+
+	l.Allow(now, Reservation{Key: "user:1", Limit: Limit{PerMinute: 60, Burst: 10}})
+	// После уменьшения burst в корзине остается не больше новой емкости
+	small := Reservation{Key: "user:1", Limit: Limit{PerMinute: 1, Burst: 1}}
+	if ok, _, _ := l.Allow(now, small); !ok {
+		t.Fatal("первый запрос с новым лимитом должен пройти")
+	}
+	if ok, _, _ := l.Allow(now, small); ok {
+		t.Fatal("запас старого лимита не должен сохраняться")
+	}
+}
+
+func TestSemaphore(t *testing.T) {
+	s := NewSemaphore(1)
+	if err := s.Acquire(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if err := s.Acquire(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("занятый семафор: ожидалось истечение контекста, получено %v", err)
+	}
+
+	acquired := make(chan error)
+	go func() { acquired <- s.Acquire(context.Background()) }()
+	s.Release()
+	if err := <-acquired; err != nil {
+		t.Fatal(err)
+	}
+	if n := s.InFlight(); n != 1 {
+		t.Fatalf("занято %d мест, ожидалось 1", n)
+	}
+
+	// 0 - без ограничений: ожидающие проходят сразу
+	go func() { acquired <- s.Acquire(context.Background()) }()
+	s.SetLimit(0)
+	if err := <-acquired; err != nil {
+		t.Fatal(err)
+	}
+	if n := s.InFlight(); n != 2 {
+		t.Fatalf("занято %d мест, ожидалось 2", n)
+	}
+}
@@ -0,0 +1,76 @@
+package storage
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// ArtStore хранит оригинальные сгенерированные изображения без оформления
+type ArtStore struct {
+	dir string
+}
+
+// NewArtStore создает каталог для изображений, если его нет
+func NewArtStore(dir string) (*ArtStore, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("ошибка создания каталога изображений: %w", err)
+	}
+	return &ArtStore{dir: dir}, nil
+}
+
+// Dir возвращает каталог с изображениями
+func (s *ArtStore) Dir() string {
+	return s.dir
+}
+
+// Save записывает изображение и возвращает путь к файлу
+func (s *ArtStore) Save(image []byte) (string, error) {
+	// Изображения пакета сохраняются параллельно, поэтому при совпадении
+	// времени к имени добавляется номер
+	base := time.Now().Format("2006-01-02_15-04-05.000000000")
+	for i := 0; ; i++ {
+		name := base + ".jpeg"
+		if i > 0 {
+			name = fmt.Sprintf("%s-%d.jpeg", base, i)
+		}
+		path := filepath.Join(s.dir, name)
+
+		f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+		if os.IsExist(err) {
+			continue
+		}
+		if err != nil {
+			return "", fmt.Errorf("ошибка записи изображения: %w", err)
+		}
+		if _, err := f.Write(image); err != nil {
+			f.Close()
+			return "", fmt.Errorf("ошибка записи изображения: %w", err)
+		}
+		if err := f.Close(); err != nil {
+			return "", fmt.Errorf("ошибка записи изображения: %w", err)
+		}
+		return path, nil
+	}
+}
+
+// Load читает сохраненное изображение
+func (s *ArtStore) Load(path string) ([]byte, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка чтения изображения: %w", err)
+	}
+	return data, nil
+}
+
+// CheckWritable проверяет, что в каталог можно записывать файлы
+func CheckWritable(dir string) error {
+	f, err := os.CreateTemp(dir, ".writecheck-*")
+	if err != nil {
+		return fmt.Errorf("каталог %s недоступен для записи: %w", dir, err)
+	}
+	name := f.Name()
+	f.Close()
+	return os.Remove(name)
+}
@@ -0,0 +1,138 @@
+package storage
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/d1mk9/tgChanPost/internal/models"
+)
+
+// Черновики старше draftTTL удаляются; из оставшихся хранятся maxDrafts самых новых.
+// Поставленные в очередь черновики хранятся в очереди отдельно и не теряются.
+const (
+	draftTTL  = 30 * 24 * time.Hour
+	maxDrafts = 1000
+)
+
+// draftKey однозначно определяет черновик по сообщению с превью
+type draftKey struct {
+	ChatID    int64
+	MessageID int
+}
+
+// DraftStore хранит черновики в памяти и сохраняет их в JSON-файл,
+// чтобы публикация из старого превью работала и после перезапуска
+type DraftStore struct {
+	mu     sync.Mutex
+	path   string
+	drafts map[draftKey]models.Draft
+}
+
+// NewDraftStore загружает черновики из файла, если он существует
+func NewDraftStore(path string) (*DraftStore, error) {
+	s := &DraftStore{
+		path:   path,
+		drafts: make(map[draftKey]models.Draft),
+	}
+
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("ошибка чтения файла черновиков: %w", err)
+	}
+	if len(data) == 0 {
+		return s, nil
+	}
+
+	var drafts []models.Draft
+	if err := json.Unmarshal(data, &drafts); err != nil {
+		return nil, fmt.Errorf("ошибка декодирования черновиков: %w", err)
+	}
+	for _, d := range drafts {
+		s.drafts[draftKey{d.ChatID, d.MessageID}] = d
+	}
+	s.prune(time.Now())
+
+	return s, nil
+}
+
+// Save добавляет или обновляет черновик
+func (s *DraftStore) Save(draft models.Draft) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.drafts[draftKey{draft.ChatID, draft.MessageID}] = draft
+	s.prune(time.Now())
+	return s.flush()
+}
+
+// Get возвращает черновик по сообщению с превью
+func (s *DraftStore) Get(chatID int64, messageID int) (models.Draft, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	d, ok := s.drafts[draftKey{chatID, messageID}]
+	return d, ok
+}
+
+// prune удаляет устаревшие черновики и самые старые сверх maxDrafts;
+// вызывается под мьютексом
+func (s *DraftStore) prune(now time.Time) {
+	for key, d := range s.drafts {
+		if now.Sub(d.CreatedAt) > draftTTL {
+			delete(s.drafts, key)
+		}
+	}
+	if len(s.drafts) <= maxDrafts {
+		return
+	}
+
+	keys := make([]draftKey, 0, len(s.drafts))
+	for key := range s.drafts {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		return s.drafts[keys[i]].CreatedAt.Before(s.drafts[keys[j]].CreatedAt)
+	})
+	for _, key := range keys[:len(keys)-maxDrafts] {
+		delete(s.drafts, key)
+	}
+}
+
+// flush перезаписывает файл целиком; вызывается под мьютексом
+func (s *DraftStore) flush() error {
+	drafts := make([]models.Draft, 0, len(s.drafts))
+	for _, d := range s.drafts {
+		drafts = append(drafts, d)
+	}
+	sort.Slice(drafts, func(i, j int) bool {
+		return drafts[i].CreatedAt.Before(drafts[j].CreatedAt)
+	})
+
+	return WriteJSON(s.path, drafts)
+}
+
+// WriteJSON атомарно записывает значение в файл через временный файл,
+// чтобы сбой во время записи не портил файл
+func WriteJSON(path string, v interface{}) error {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return fmt.Errorf("ошибка кодирования данных: %w", err)
+	}
+
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return fmt.Errorf("ошибка записи данных в файл: %w", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return fmt.Errorf("ошибка замены файла: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,128 @@
+package storage
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"sync"
+
+	"github.com/d1mk9/tgChanPost/internal/models"
+)
+
+// PostStore реестр опубликованных постов в JSON-файле.
+// Удаленные из канала посты остаются в реестре с отметкой Deleted.
+type PostStore struct {
+	mu     sync.Mutex
+	path   string
+	posts  map[int64]*models.Post
+	nextID int64
+}
+
+// NewPostStore загружает реестр из файла, если он существует
+func NewPostStore(path string) (*PostStore, error) {
+	s := &PostStore{
+		path:  path,
+		posts: make(map[int64]*models.Post),
+	}
+
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("ошибка чтения реестра постов: %w", err)
+	}
+	if len(data) == 0 {
+		return s, nil
+	}
+
+	var posts []models.Post
+	if err := json.Unmarshal(data, &posts); err != nil {
+		return nil, fmt.Errorf("ошибка декодирования реестра постов: %w", err)
+	}
+	for i := range posts {
+		p := posts[i]
+		s.posts[p.ID] = &p
+		if p.ID > s.nextID {
+			s.nextID = p.ID
+		}
+	}
+
+	return s, nil
+}
+
+// Add добавляет пост и присваивает ему ID
+func (s *PostStore) Add(post models.Post) (models.Post, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.nextID++
+	post.ID = s.nextID
+	s.posts[post.ID] = &post
+	return post, s.flush()
+}
+
+// Get возвращает пост по ID
+func (s *PostStore) Get(id int64) (models.Post, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	p, ok := s.posts[id]
+	if !ok {
+		return models.Post{}, false
+	}
+	return *p, true
+}
+
+// FindByMessage возвращает пост по сообщению в канале
+func (s *PostStore) FindByMessage(channelID string, messageID int) (models.Post, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, p := range s.posts {
+		if p.ChannelID == channelID && p.MessageID == messageID {
+			return *p, true
+		}
+	}
+	return models.Post{}, false
+}
+
+// List возвращает неудаленные посты канала, новые первыми
+func (s *PostStore) List(channelID string) []models.Post {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var posts []models.Post
+	for _, p := range s.posts {
+		if p.ChannelID == channelID && !p.Deleted {
+			posts = append(posts, *p)
+		}
+	}
+	sort.Slice(posts, func(i, j int) bool { return posts[i].ID > posts[j].ID })
+	return posts
+}
+
+// Update изменяет пост функцией fn и сохраняет реестр
+func (s *PostStore) Update(id int64, fn func(p *models.Post)) (models.Post, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	p, ok := s.posts[id]
+	if !ok {
+		return models.Post{}, fmt.Errorf("пост %d не найден", id)
+	}
+	fn(p)
+	return *p, s.flush()
+}
+
+// flush перезаписывает файл целиком; вызывается под мьютексом
+func (s *PostStore) flush() error {
+	posts := make([]models.Post, 0, len(s.posts))
+	for _, p := range s.posts {
+		posts = append(posts, *p)
+	}
+	sort.Slice(posts, func(i, j int) bool { return posts[i].ID < posts[j].ID })
+
+	return WriteJSON(s.path, posts)
+}
@@ -0,0 +1,213 @@
+package storage
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/d1mk9/tgChanPost/internal/models"
+)
+
+// QueueStore хранит очередь публикации всех каналов и сохраняет ее в JSON-файл.
+// Опубликованные и неудавшиеся элементы остаются в файле как история.
+type QueueStore struct {
+	mu     sync.Mutex
+	path   string
+	items  map[int64]*models.QueueItem
+	nextID int64
+}
+
+// NewQueueStore загружает очередь из файла, если он существует
+func NewQueueStore(path string) (*QueueStore, error) {
+	s := &QueueStore{
+		path:  path,
+		items: make(map[int64]*models.QueueItem),
+	}
+
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("ошибка чтения файла очереди: %w", err)
+	}
+	if len(data) == 0 {
+		return s, nil
+	}
+
+	var items []models.QueueItem
+	if err := json.Unmarshal(data, &items); err != nil {
+		return nil, fmt.Errorf("ошибка декодирования очереди: %w", err)
+	}
+	for i := range items {
+		item := items[i]
+		s.items[item.ID] = &item
+		if item.ID > s.nextID {
+			s.nextID = item.ID
+		}
+	}
+
+	return s, nil
+}
+
+// Add ставит элемент в очередь и присваивает ему ID
+func (s *QueueStore) Add(item models.QueueItem) (models.QueueItem, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.nextID++
+	item.ID = s.nextID
+	item.Status = models.QueueQueued
+	s.items[item.ID] = &item
+	return item, s.flush()
+}
+
+// Get возвращает элемент по ID
+func (s *QueueStore) Get(id int64) (models.QueueItem, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	item, ok := s.items[id]
+	if !ok {
+		return models.QueueItem{}, false
+	}
+	return *item, true
+}
+
+// Queued возвращает ожидающие элементы канала в порядке публикации
+func (s *QueueStore) Queued(channelID string) []models.QueueItem {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var items []models.QueueItem
+	for _, item := range s.items {
+		if item.Status == models.QueueQueued && item.Draft.ChannelID == channelID {
+			items = append(items, *item)
+		}
+	}
+	sortQueue(items)
+	return items
+}
+
+// FindDraft возвращает ожидающий элемент с черновиком из сообщения превью
+func (s *QueueStore) FindDraft(chatID int64, messageID int) (models.QueueItem, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, item := range s.items {
+		if item.Status == models.QueueQueued && item.Draft.ChatID == chatID && item.Draft.MessageID == messageID {
+			return *item, true
+		}
+	}
+	return models.QueueItem{}, false
+}
+
+// Due возвращает ожидающие элементы всех каналов, время публикации которых наступило.
+// Элементы без назначенного слота не публикуются.
+func (s *QueueStore) Due(now time.Time) []models.QueueItem {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var items []models.QueueItem
+	for _, item := range s.items {
+		if item.Status == models.QueueQueued && !item.PublishAt.IsZero() && !item.PublishAt.After(now) {
+			items = append(items, *item)
+		}
+	}
+	sortQueue(items)
+	return items
+}
+
+// Schedule назначает элементам время публикации: ids[i] получает times[i]
+func (s *QueueStore) Schedule(ids []int64, times []time.Time) error {
+	if len(ids) != len(times) {
+		return fmt.Errorf("слотов %d, а элементов %d", len(times), len(ids))
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i, id := range ids {
+		if item, ok := s.items[id]; ok {
+			item.PublishAt = times[i]
+		}
+	}
+	return s.flush()
+}
+
+// Remove удаляет ожидающий элемент из очереди
+func (s *QueueStore) Remove(id int64) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	item, ok := s.items[id]
+	if !ok || item.Status != models.QueueQueued {
+		return false, nil
+	}
+	delete(s.items, id)
+	return true, s.flush()
+}
+
+// MarkPublished отмечает публикацию и сохраняет ID сообщения в канале
+func (s *QueueStore) MarkPublished(id int64, messageID int, at time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	item, ok := s.items[id]
+	if !ok {
+		return fmt.Errorf("элемент очереди %d не найден", id)
+	}
+	item.Status = models.QueuePublished
+	item.MessageID = messageID
+	item.PublishedAt = at
+	item.Attempts++
+	item.LastError = ""
+	return s.flush()
+}
+
+// MarkAttemptFailed учитывает неудачную попытку публикации. После maxAttempts
+// элемент получает статус failed; возвращается true, если это произошло.
+func (s *QueueStore) MarkAttemptFailed(id int64, err error, maxAttempts int) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	item, ok := s.items[id]
+	if !ok {
+		return false, fmt.Errorf("элемент очереди %d не найден", id)
+	}
+	item.Attempts++
+	item.LastError = err.Error()
+	failed := item.Attempts >= maxAttempts
+	if failed {
+		item.Status = models.QueueFailed
+	}
+	return failed, s.flush()
+}
+
+// sortQueue упорядочивает элементы по времени публикации, затем по ID.
+// Элементы без назначенного слота идут последними.
+func sortQueue(items []models.QueueItem) {
+	sort.Slice(items, func(i, j int) bool {
+		if zi, zj := items[i].PublishAt.IsZero(), items[j].PublishAt.IsZero(); zi != zj {
+			return zj
+		}
+		if !items[i].PublishAt.Equal(items[j].PublishAt) {
+			return items[i].PublishAt.Before(items[j].PublishAt)
+		}
+		return items[i].ID < items[j].ID
+	})
+}
+
+// flush перезаписывает файл целиком; вызывается под мьютексом
+func (s *QueueStore) flush() error {
+	items := make([]models.QueueItem, 0, len(s.items))
+	for _, item := range s.items {
+		items = append(items, *item)
+	}
+	sort.Slice(items, func(i, j int) bool { return items[i].ID < items[j].ID })
+
+	return WriteJSON(s.path, items)
+}
@@ -0,0 +1,183 @@
+package storage
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/d1mk9/tgChanPost/internal/models"
+)
+
+// dateLayout формат дня в записях расхода
+const dateLayout = "2006-01-02"
+
+// usageKey день, чат, пользователь и канал, за которые суммируется расход
+type usageKey struct {
+	Date      string
+	ChatID    int64
+	UserID    int64
+	ChannelID string
+}
+
+// UsageFilter условия выборки расхода; нулевые поля не ограничивают выборку
+type UsageFilter struct {
+	From      time.Time // Первый день периода включительно
+	ChatID    int64
+	UserID    int64
+	ChannelID string
+}
+
+// UsageTotals суммарный расход
+type UsageTotals struct {
+	InputTokens      int64
+	CompletionTokens int64
+	Images           int64
+	Cost             float64
+}
+
+// Tokens общее количество токенов
+func (t UsageTotals) Tokens() int64 {
+	return t.InputTokens + t.CompletionTokens
+}
+
+func (t *UsageTotals) add(e models.UsageEntry) {
+	t.InputTokens += e.InputTokens
+	t.CompletionTokens += e.CompletionTokens
+	t.Images += e.Images
+	t.Cost += e.Cost
+}
+
+// UsageStore хранит расход токенов и генераций изображений по дням
+// и сохраняет его в JSON-файл. Записи одного дня, чата, пользователя
+// и канала суммируются, поэтому файл растет по дням, а не по вызовам.
+type UsageStore struct {
+	mu      sync.Mutex
+	path    string
+	entries map[usageKey]*models.UsageEntry
+}
+
+// NewUsageStore загружает расход из файла, если он существует
+func NewUsageStore(path string) (*UsageStore, error) {
+	s := &UsageStore{
+		path:    path,
+		entries: make(map[usageKey]*models.UsageEntry),
+	}
+
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("ошибка чтения файла расхода: %w", err)
+	}
+	if len(data) == 0 {
+		return s, nil
+	}
+
+	var entries []models.UsageEntry
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return nil, fmt.Errorf("ошибка декодирования расхода: %w", err)
+	}
+	for i := range entries {
+		e := entries[i]
+		s.entries[usageKey{e.Date, e.ChatID, e.UserID, e.ChannelID}] = &e
+	}
+
+	return s, nil
+}
+
+// Record добавляет расход к записи за день вызова
+func (s *UsageStore) Record(at time.Time, e models.UsageEntry) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	e.Date = at.Format(dateLayout)
+	key := usageKey{e.Date, e.ChatID, e.UserID, e.ChannelID}
+	cur, ok := s.entries[key]
+	if !ok {
+		s.entries[key] = &e
+		return s.flush()
+	}
+
+	cur.InputTokens += e.InputTokens
+	cur.CompletionTokens += e.CompletionTokens
+	cur.Images += e.Images
+	cur.Cost += e.Cost
+	return s.flush()
+}
+
+// Totals суммирует расход, подходящий под фильтр
+func (s *UsageStore) Totals(f UsageFilter) UsageTotals {
+	var totals UsageTotals
+	s.each(f, func(e models.UsageEntry) { totals.add(e) })
+	return totals
+}
+
+// ByChannel суммирует расход, подходящий под фильтр, по каналам
+func (s *UsageStore) ByChannel(f UsageFilter) map[string]UsageTotals {
+	byChannel := make(map[string]UsageTotals)
+	s.each(f, func(e models.UsageEntry) {
+		t := byChannel[e.ChannelID]
+		t.add(e)
+		byChannel[e.ChannelID] = t
+	})
+	return byChannel
+}
+
+func (s *UsageStore) each(f UsageFilter, fn func(models.UsageEntry)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var from string
+	if !f.From.IsZero() {
+		from = f.From.Format(dateLayout)
+	}
+	for _, e := range s.entries {
+		switch {
+		case e.Date < from:
+		case f.ChatID != 0 && e.ChatID != f.ChatID:
+		case f.UserID != 0 && e.UserID != f.UserID:
+		case f.ChannelID != "" && e.ChannelID != f.ChannelID:
+		default:
+			fn(*e)
+		}
+	}
+}
+
+// flush перезаписывает файл целиком; вызывается под мьютексом
+func (s *UsageStore) flush() error {
+	entries := make([]models.UsageEntry, 0, len(s.entries))
+	for _, e := range s.entries {
+		entries = append(entries, *e)
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		a, b := entries[i], entries[j]
+		if a.Date != b.Date {
+			return a.Date < b.Date
+		}
+		if a.ChatID != b.ChatID {
+			return a.ChatID < b.ChatID
+		}
+		if a.UserID != b.UserID {
+			return a.UserID < b.UserID
+		}
+		return a.ChannelID < b.ChannelID
+	})
+
+	return WriteJSON(s.path, entries)
+}
+
+// StartOfDay начало дня t по местному времени
+func StartOfDay(t time.Time) time.Time {
+	y, m, d := t.Date()
+	return time.Date(y, m, d, 0, 0, 0, 0, t.Location())
+}
+
+// StartOfMonth начало месяца t по местному времени
+func StartOfMonth(t time.Time) time.Time {
+	y, m, _ := t.Date()
+	return time.Date(y, m, 1, 0, 0, 0, 0, t.Location())
+}
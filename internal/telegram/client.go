@@ -0,0 +1,58 @@
+package telegram
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// MaxDownloadSize ограничение Bot API на размер скачиваемого файла
+const MaxDownloadSize = 20 << 20
+
+// Client клиент Bot API с загрузкой файлов и счетчиками реакций.
+// Остальные методы берутся из tgbotapi.BotAPI без изменений.
+type Client struct {
+	*tgbotapi.BotAPI
+	http      *http.Client
+	reactions chan ReactionCount
+	stop      chan struct{}
+}
+
+// New авторизуется в Telegram по токену бота
+func New(token string) (*Client, error) {
+	api, err := tgbotapi.NewBotAPI(token)
+	if err != nil {
+		return nil, err
+	}
+	return &Client{
+		BotAPI:    api,
+		http:      &http.Client{Timeout: 30 * time.Second},
+		reactions: make(chan ReactionCount, api.Buffer),
+		stop:      make(chan struct{}),
+	}, nil
+}
+
+// Download скачивает файл, полученный через GetFile
+func (c *Client) Download(file tgbotapi.File) ([]byte, error) {
+	resp, err := c.http.Get(file.Link(c.Token))
+	if err != nil {
+		// Ошибка содержит URL с токеном, поэтому возвращаем только ее суть
+		var uerr *url.Error
+		if errors.As(err, &uerr) {
+			err = uerr.Err
+		}
+		return nil, fmt.Errorf("ошибка загрузки файла: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("ошибка загрузки файла: %s", resp.Status)
+	}
+
+	return io.ReadAll(io.LimitReader(resp.Body, MaxDownloadSize))
+}
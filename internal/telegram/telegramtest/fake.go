@@ -0,0 +1,337 @@
+// Package telegramtest содержит Telegram в памяти для сценарных проверок бота
+// без сети: генерация → превью → публикация.
+package telegramtest
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/d1mk9/tgChanPost/internal/telegram"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// Sent сообщение, отправленное ботом
+type Sent struct {
+	Chattable tgbotapi.Chattable
+	Message   tgbotapi.Message // Ответ, который получил бот
+}
+
+// Fake реализует методы Bot API, которые использует бот: запоминает
+// отправленные сообщения и запросы и выдает обновления, добавленные через Inject
+type Fake struct {
+	Self tgbotapi.User
+
+	mu        sync.Mutex
+	sent      []Sent
+	requests  []tgbotapi.Chattable
+	files     map[string][]byte
+	sendErr   error
+	failSend  func(tgbotapi.Chattable) error
+	failReq   func(tgbotapi.Chattable) error
+	updates   chan tgbotapi.Update
+	reactions chan telegram.ReactionCount
+	nextMsgID int
+	nextUpdID int
+	closed    bool
+}
+
+// New создает Fake с буфером обновлений
+func New() *Fake {
+	return &Fake{
+		Self:      tgbotapi.User{ID: 1, IsBot: true, UserName: "fake_bot"},
+		files:     make(map[string][]byte),
+		updates:   make(chan tgbotapi.Update, 100),
+		reactions: make(chan telegram.ReactionCount, 100),
+	}
+}
+
+// Send запоминает сообщение и возвращает ответ с новым message_id.
+// Для фото в ответе есть file_id, как у настоящего Telegram.
+func (f *Fake) Send(c tgbotapi.Chattable) (tgbotapi.Message, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.sendErr != nil {
+		return tgbotapi.Message{}, f.sendErr
+	}
+	if f.failSend != nil {
+		if err := f.failSend(c); err != nil {
+			return tgbotapi.Message{}, err
+		}
+	}
+
+	f.nextMsgID++
+	msg := tgbotapi.Message{MessageID: f.nextMsgID, From: &f.Self}
+
+	switch v := c.(type) {
+	case tgbotapi.MessageConfig:
+		msg.Chat = chat(v.BaseChat)
+		msg.Text = v.Text
+	case tgbotapi.PhotoConfig:
+		msg.Chat = chat(v.BaseChat)
+		msg.Caption = v.Caption
+		msg.Photo = []tgbotapi.PhotoSize{
+			{FileID: fmt.Sprintf("photo-%d-small", f.nextMsgID), Width: 320, Height: 320},
+			{FileID: fmt.Sprintf("photo-%d", f.nextMsgID), Width: 1024, Height: 1024},
+		}
+	case tgbotapi.EditMessageTextConfig:
+		msg.MessageID = v.MessageID
+		msg.Chat = &tgbotapi.Chat{ID: v.ChatID}
+		msg.Text = v.Text
+	default:
+		msg.Chat = &tgbotapi.Chat{}
+	}
+
+	f.sent = append(f.sent, Sent{Chattable: c, Message: msg})
+	return msg, nil
+}
+
+func chat(base tgbotapi.BaseChat) *tgbotapi.Chat {
+	return &tgbotapi.Chat{ID: base.ChatID, UserName: base.ChannelUsername}
+}
+
+// Request запоминает запрос, например ответ на callback, и возвращает успех.
+// Медиагруппа, как и в Bot API, возвращает отправленные сообщения; каждое
+// из них также попадает в Sent. Замена изображения возвращает измененное
+// сообщение с новым file_id.
+func (f *Fake) Request(c tgbotapi.Chattable) (*tgbotapi.APIResponse, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.requests = append(f.requests, c)
+	if f.failReq != nil {
+		if err := f.failReq(c); err != nil {
+			return nil, err
+		}
+	}
+
+	if edit, ok := c.(tgbotapi.EditMessageMediaConfig); ok {
+		f.nextMsgID++
+		result, err := json.Marshal(tgbotapi.Message{
+			MessageID: edit.MessageID,
+			From:      &f.Self,
+			Chat:      &tgbotapi.Chat{ID: edit.ChatID, UserName: edit.ChannelUsername},
+			Photo: []tgbotapi.PhotoSize{
+				{FileID: fmt.Sprintf("photo-%d-small", f.nextMsgID), Width: 320, Height: 320},
+				{FileID: fmt.Sprintf("photo-%d", f.nextMsgID), Width: 1024, Height: 1024},
+			},
+		})
+		if err != nil {
+			return nil, err
+		}
+		return &tgbotapi.APIResponse{Ok: true, Result: result}, nil
+	}
+
+	group, ok := c.(tgbotapi.MediaGroupConfig)
+	if !ok {
+		return &tgbotapi.APIResponse{Ok: true, Result: []byte("true")}, nil
+	}
+	if f.sendErr != nil {
+		return nil, f.sendErr
+	}
+
+	msgs := make([]tgbotapi.Message, 0, len(group.Media))
+	for _, media := range group.Media {
+		f.nextMsgID++
+		msg := tgbotapi.Message{
+			MessageID: f.nextMsgID,
+			From:      &f.Self,
+			Chat:      &tgbotapi.Chat{ID: group.ChatID, UserName: group.ChannelUsername},
+			Photo: []tgbotapi.PhotoSize{
+				{FileID: fmt.Sprintf("photo-%d-small", f.nextMsgID), Width: 320, Height: 320},
+				{FileID: fmt.Sprintf("photo-%d", f.nextMsgID), Width: 1024, Height: 1024},
+			},
+		}
+		if photo, ok := media.(tgbotapi.InputMediaPhoto); ok {
+			msg.Caption = photo.Caption
+		}
+		msgs = append(msgs, msg)
+		f.sent = append(f.sent, Sent{Chattable: c, Message: msg})
+	}
+
+	result, err := json.Marshal(msgs)
+	if err != nil {
+		return nil, err
+	}
+	return &tgbotapi.APIResponse{Ok: true, Result: result}, nil
+}
+
+// GetFile возвращает файл, добавленный через AddFile
+func (f *Fake) GetFile(config tgbotapi.FileConfig) (tgbotapi.File, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	data, ok := f.files[config.FileID]
+	if !ok {
+		return tgbotapi.File{}, fmt.Errorf("файл %s не найден", config.FileID)
+	}
+	return tgbotapi.File{FileID: config.FileID, FileSize: len(data), FilePath: config.FileID}, nil
+}
+
+// Download возвращает содержимое файла, добавленного через AddFile
+func (f *Fake) Download(file tgbotapi.File) ([]byte, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	data, ok := f.files[file.FilePath]
+	if !ok {
+		return nil, fmt.Errorf("файл %s не найден", file.FilePath)
+	}
+	return data, nil
+}
+
+// GetMe возвращает пользователя бота
+func (f *Fake) GetMe() (tgbotapi.User, error) {
+	return f.Self, nil
+}
+
+// GetUpdatesChan возвращает канал с обновлениями из Inject
+func (f *Fake) GetUpdatesChan(tgbotapi.UpdateConfig) tgbotapi.UpdatesChannel {
+	return f.updates
+}
+
+// Close закрывает канал обновлений, после чего цикл бота завершается
+func (f *Fake) Close() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if !f.closed {
+		f.closed = true
+		close(f.updates)
+		close(f.reactions)
+	}
+}
+
+// AddFile добавляет файл, который бот сможет скачать по file_id
+func (f *Fake) AddFile(fileID string, data []byte) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.files[fileID] = data
+}
+
+// FailSends заставляет Send возвращать ошибку; nil возвращает обычное поведение
+func (f *Fake) FailSends(err error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.sendErr = err
+}
+
+// FailSendsIf задает ошибку Send для выбранных сообщений, как FailRequests
+// для запросов; nil вместо fail возвращает обычное поведение
+func (f *Fake) FailSendsIf(fail func(tgbotapi.Chattable) error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.failSend = fail
+}
+
+// FailRequests задает ошибку Request для выбранных запросов: fail вызывается
+// для каждого запроса и возвращает ошибку или nil. nil вместо fail
+// возвращает обычное поведение.
+func (f *Fake) FailRequests(fail func(tgbotapi.Chattable) error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.failReq = fail
+}
+
+// Inject добавляет обновление в очередь бота
+func (f *Fake) Inject(update tgbotapi.Update) {
+	f.mu.Lock()
+	f.nextUpdID++
+	update.UpdateID = f.nextUpdID
+	f.mu.Unlock()
+
+	f.updates <- update
+}
+
+// ReactionCounts возвращает канал со счетчиками реакций из InjectReactions
+func (f *Fake) ReactionCounts() <-chan telegram.ReactionCount {
+	return f.reactions
+}
+
+// InjectReactions добавляет обновление счетчиков реакций на сообщение в канале.
+// counts задает число реакций по эмодзи.
+func (f *Fake) InjectReactions(channel string, messageID int, counts map[string]int) {
+	rc := telegram.ReactionCount{
+		Chat:      tgbotapi.Chat{Type: "channel", UserName: strings.TrimPrefix(channel, "@")},
+		MessageID: messageID,
+	}
+	for emoji, n := range counts {
+		rc.Reactions = append(rc.Reactions, telegram.ReactionTotal{
+			Type:       telegram.ReactionType{Type: "emoji", Emoji: emoji},
+			TotalCount: n,
+		})
+	}
+	f.reactions <- rc
+}
+
+// Text создает обновление с текстовым сообщением от пользователя.
+// Команды (текст с /) размечаются как bot_command.
+func Text(chatID int64, text string) tgbotapi.Update {
+	msg := &tgbotapi.Message{
+		Chat: &tgbotapi.Chat{ID: chatID, Type: "private"},
+		From: &tgbotapi.User{ID: chatID, UserName: "moderator"},
+		Text: text,
+	}
+	if len(text) > 0 && text[0] == '/' {
+		length := len([]rune(text))
+		for i, r := range []rune(text) {
+			if r == ' ' {
+				length = i
+				break
+			}
+		}
+		msg.Entities = []tgbotapi.MessageEntity{{Type: "bot_command", Offset: 0, Length: length}}
+	}
+	return tgbotapi.Update{Message: msg}
+}
+
+// Photo создает обновление с фотографией от пользователя; содержимое
+// файла добавляется через AddFile
+func Photo(chatID int64, fileID string) tgbotapi.Update {
+	return tgbotapi.Update{Message: &tgbotapi.Message{
+		Chat:  &tgbotapi.Chat{ID: chatID, Type: "private"},
+		From:  &tgbotapi.User{ID: chatID, UserName: "moderator"},
+		Photo: []tgbotapi.PhotoSize{{FileID: fileID, Width: 64, Height: 64}},
+	}}
+}
+
+// Click создает нажатие кнопки под сообщением бота
+func Click(chatID int64, messageID int, data string) tgbotapi.Update {
+	return tgbotapi.Update{CallbackQuery: &tgbotapi.CallbackQuery{
+		ID:      fmt.Sprintf("cb-%d-%d", chatID, messageID),
+		From:    &tgbotapi.User{ID: chatID, UserName: "moderator"},
+		Message: &tgbotapi.Message{MessageID: messageID, Chat: &tgbotapi.Chat{ID: chatID}},
+		Data:    data,
+	}}
+}
+
+// Sent возвращает копию отправленных сообщений
+func (f *Fake) Sent() []Sent {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]Sent(nil), f.sent...)
+}
+
+// SentTo возвращает сообщения, отправленные в чат или канал (@username)
+func (f *Fake) SentTo(chatID int64, channel string) []Sent {
+	var out []Sent
+	for _, s := range f.Sent() {
+		if s.Message.Chat == nil {
+			continue
+		}
+		if (channel != "" && s.Message.Chat.UserName == channel) || (channel == "" && s.Message.Chat.ID == chatID) {
+			out = append(out, s)
+		}
+	}
+	return out
+}
+
+// Requests возвращает копию запросов, отправленных через Request
+func (f *Fake) Requests() []tgbotapi.Chattable {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]tgbotapi.Chattable(nil), f.requests...)
+}
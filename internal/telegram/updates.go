@@ -0,0 +1,111 @@
+package telegram
+
+import (
+	"encoding/json"
+	"log/slog"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// ReactionCount анонимные счетчики реакций на сообщение в канале
+// (обновление message_reaction_count, tgbotapi его не разбирает)
+type ReactionCount struct {
+	Chat      tgbotapi.Chat   `json:"chat"`
+	MessageID int             `json:"message_id"`
+	Date      int             `json:"date"`
+	Reactions []ReactionTotal `json:"reactions"`
+}
+
+// ReactionTotal число реакций одного вида
+type ReactionTotal struct {
+	Type       ReactionType `json:"type"`
+	TotalCount int          `json:"total_count"`
+}
+
+// ReactionType вид реакции: эмодзи, пользовательский эмодзи или платная звезда
+type ReactionType struct {
+	Type          string `json:"type"`
+	Emoji         string `json:"emoji,omitempty"`
+	CustomEmojiID string `json:"custom_emoji_id,omitempty"`
+}
+
+// Key короткое имя реакции для статистики
+func (r ReactionType) Key() string {
+	switch r.Type {
+	case "emoji":
+		return r.Emoji
+	case "custom_emoji":
+		return "custom:" + r.CustomEmojiID
+	default:
+		return r.Type
+	}
+}
+
+// update обновление с полями, которых нет в tgbotapi.Update
+type update struct {
+	tgbotapi.Update
+	MessageReactionCount *ReactionCount `json:"message_reaction_count,omitempty"`
+}
+
+// GetUpdatesChan получает обновления long polling, как tgbotapi, но дополнительно
+// разбирает счетчики реакций и отдает их через ReactionCounts. Чтобы Telegram
+// присылал счетчики, в config.AllowedUpdates нужен message_reaction_count.
+func (c *Client) GetUpdatesChan(config tgbotapi.UpdateConfig) tgbotapi.UpdatesChannel {
+	ch := make(chan tgbotapi.Update, c.Buffer)
+
+	go func() {
+		defer close(c.reactions)
+		for {
+			select {
+			case <-c.stop:
+				close(ch)
+				return
+			default:
+			}
+
+			updates, err := c.getUpdates(config)
+			if err != nil {
+				slog.Warn("Ошибка получения обновлений, повтор через 3 секунды", "err", err)
+				time.Sleep(3 * time.Second)
+				continue
+			}
+
+			for _, u := range updates {
+				if u.UpdateID < config.Offset {
+					continue
+				}
+				config.Offset = u.UpdateID + 1
+				if u.MessageReactionCount != nil {
+					c.reactions <- *u.MessageReactionCount
+					continue
+				}
+				ch <- u.Update
+			}
+		}
+	}()
+
+	return ch
+}
+
+// ReactionCounts возвращает счетчики реакций, полученные через GetUpdatesChan.
+// Канал закрывается вместе с каналом обновлений.
+func (c *Client) ReactionCounts() <-chan ReactionCount {
+	return c.reactions
+}
+
+// StopReceivingUpdates останавливает получение обновлений
+func (c *Client) StopReceivingUpdates() {
+	close(c.stop)
+}
+
+func (c *Client) getUpdates(config tgbotapi.UpdateConfig) ([]update, error) {
+	resp, err := c.Request(config)
+	if err != nil {
+		return nil, err
+	}
+
+	var updates []update
+	err = json.Unmarshal(resp.Result, &updates)
+	return updates, err
+}
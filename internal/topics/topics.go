@@ -0,0 +1,217 @@
+// Package topics подбирает темы для постов канала: случайно с учетом весов,
+// пропуская темы и авторов, которые публиковались недавно.
+package topics
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"sort"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// DefaultPromptTemplate шаблон запроса к модели по умолчанию: запрос темы
+// без изменений. Шаблоны, перечисляющие авторов на перерыве, зависят
+// от языка канала и задаются в пакете lang.
+const DefaultPromptTemplate = `{{.Prompt}}`
+
+// ErrEmpty нет темы для выбора: каталог пуст или у всех тем идет перерыв
+var ErrEmpty = errors.New("нет тем, доступных для ротации")
+
+// Topic тема каталога
+type Topic struct {
+	Name     string
+	Prompt   string        // Запрос к модели, пусто - название темы
+	Weight   float64       // Относительная частота выбора
+	Cooldown time.Duration // Минимальный перерыв между постами на тему
+}
+
+// Use пост из истории канала
+type Use struct {
+	Topic  string
+	Author string
+	At     time.Time
+}
+
+// State состояние темы с учетом истории
+type State struct {
+	Topic       Topic
+	Uses        int
+	LastUsed    time.Time
+	AvailableAt time.Time // Время окончания перерыва; нулевое, если тема не публиковалась
+}
+
+// Available сообщает, можно ли выбрать тему в момент now
+func (s State) Available(now time.Time) bool {
+	return !s.AvailableAt.After(now)
+}
+
+// Blocked автор, который недавно публиковался
+type Blocked struct {
+	Author string
+	Until  time.Time
+}
+
+// PromptData данные для подстановки в шаблон запроса
+type PromptData struct {
+	Topic        string
+	Prompt       string
+	AvoidAuthors []string
+}
+
+// Options параметры каталога
+type Options struct {
+	Topics         []Topic
+	AuthorCooldown time.Duration // Перерыв между постами одного автора
+	Template       string        // Шаблон запроса, пусто - DefaultPromptTemplate
+}
+
+// Catalog каталог тем канала с шаблоном запроса к модели
+type Catalog struct {
+	topics         []Topic
+	authorCooldown time.Duration
+	tmpl           *template.Template
+}
+
+// New проверяет темы и разбирает шаблон запроса
+func New(opts Options) (*Catalog, error) {
+	seen := make(map[string]bool)
+	for _, t := range opts.Topics {
+		key := strings.ToLower(t.Name)
+		if key == "" {
+			return nil, errors.New("тема без названия")
+		}
+		if seen[key] {
+			return nil, fmt.Errorf("тема %q указана дважды", t.Name)
+		}
+		if t.Weight <= 0 {
+			return nil, fmt.Errorf("тема %q: вес должен быть больше нуля", t.Name)
+		}
+		seen[key] = true
+	}
+
+	text := opts.Template
+	if text == "" {
+		text = DefaultPromptTemplate
+	}
+	tmpl, err := template.New("prompt").
+		Option("missingkey=error").
+		Funcs(template.FuncMap{"join": strings.Join}).
+		Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка разбора шаблона запроса: %w", err)
+	}
+
+	return &Catalog{topics: opts.Topics, authorCooldown: opts.AuthorCooldown, tmpl: tmpl}, nil
+}
+
+// Len возвращает число тем
+func (c *Catalog) Len() int {
+	return len(c.topics)
+}
+
+// AuthorCooldown возвращает перерыв между постами одного автора
+func (c *Catalog) AuthorCooldown() time.Duration {
+	return c.authorCooldown
+}
+
+// States возвращает состояние тем в порядке каталога
+func (c *Catalog) States(now time.Time, history []Use) []State {
+	states := make([]State, len(c.topics))
+	index := make(map[string]int, len(c.topics))
+	for i, t := range c.topics {
+		states[i].Topic = t
+		index[strings.ToLower(t.Name)] = i
+	}
+
+	for _, u := range history {
+		i, ok := index[strings.ToLower(u.Topic)]
+		if !ok || u.At.After(now) {
+			continue
+		}
+		states[i].Uses++
+		if u.At.After(states[i].LastUsed) {
+			states[i].LastUsed = u.At
+		}
+	}
+
+	for i := range states {
+		if !states[i].LastUsed.IsZero() {
+			states[i].AvailableAt = states[i].LastUsed.Add(states[i].Topic.Cooldown)
+		}
+	}
+	return states
+}
+
+// Pick выбирает тему случайно с учетом весов среди тем, перерыв которых истек.
+// Если перерыв идет у всех тем, возвращает ErrEmpty: повтор темы раньше
+// срока нарушил бы настроенный перерыв.
+func (c *Catalog) Pick(now time.Time, history []Use, rnd *rand.Rand) (Topic, error) {
+	var total float64
+	var available []State
+	for _, s := range c.States(now, history) {
+		if s.Available(now) {
+			available = append(available, s)
+			total += s.Topic.Weight
+		}
+	}
+	if len(available) == 0 {
+		return Topic{}, ErrEmpty
+	}
+
+	x := rnd.Float64() * total
+	for _, s := range available {
+		if x < s.Topic.Weight {
+			return s.Topic, nil
+		}
+		x -= s.Topic.Weight
+	}
+	return available[len(available)-1].Topic, nil
+}
+
+// BlockedAuthors возвращает авторов, перерыв которых еще не истек, по алфавиту
+func (c *Catalog) BlockedAuthors(now time.Time, history []Use) []Blocked {
+	if c.authorCooldown <= 0 {
+		return nil
+	}
+
+	latest := make(map[string]Blocked)
+	for _, u := range history {
+		if u.Author == "" || u.At.After(now) {
+			continue
+		}
+		until := u.At.Add(c.authorCooldown)
+		if !until.After(now) {
+			continue
+		}
+		key := strings.ToLower(u.Author)
+		if b, ok := latest[key]; !ok || until.After(b.Until) {
+			latest[key] = Blocked{Author: u.Author, Until: until}
+		}
+	}
+
+	blocked := make([]Blocked, 0, len(latest))
+	for _, b := range latest {
+		blocked = append(blocked, b)
+	}
+	sort.Slice(blocked, func(i, j int) bool { return blocked[i].Author < blocked[j].Author })
+	return blocked
+}
+
+// Prompt формирует запрос к модели по теме; avoid - авторы, которых
+// модель не должна предлагать
+func (c *Catalog) Prompt(t Topic, avoid []string) (string, error) {
+	prompt := t.Prompt
+	if prompt == "" {
+		prompt = t.Name
+	}
+
+	var sb strings.Builder
+	err := c.tmpl.Execute(&sb, PromptData{Topic: t.Name, Prompt: prompt, AvoidAuthors: avoid})
+	if err != nil {
+		return "", fmt.Errorf("ошибка подстановки в шаблон запроса: %w", err)
+	}
+	return sb.String(), nil
+}
@@ -0,0 +1,161 @@
+package topics
+
+import (
+	"errors"
+	"math/rand"
+	"reflect"
+	"testing"
+	"time"
+)
+
+var now = time.Date(2026, 3, 10, 12, 0, 0, 0, time.UTC)
+
+func TestPick(t *testing.T) {
+	day := 24 * time.Hour
+	catalog := []Topic{
+		{Name: "Любовь", Weight: 1, Cooldown: 2 * day},
+		{Name: "Города", Weight: 1, Cooldown: day},
+	}
+
+	tests := []struct {
+		name    string
+		topics  []Topic
+		history []Use
+		want    string // Пусто - ожидается ErrEmpty
+	}{
+		{name: "пустой каталог", want: ""},
+		{
+			name:    "перерыв темы",
+			topics:  catalog,
+			history: []Use{{Topic: "любовь", At: now.Add(-day)}},
+			want:    "Города",
+		},
+		{
+			name:    "перерыв истек",
+			topics:  catalog,
+			history: []Use{{Topic: "Любовь", At: now.Add(-3 * day)}, {Topic: "Города", At: now.Add(-time.Hour)}},
+			want:    "Любовь",
+		},
+		{
+			name:    "будущие посты не учитываются",
+			topics:  catalog,
+			history: []Use{{Topic: "Города", At: now.Add(-time.Hour)}, {Topic: "Любовь", At: now.Add(time.Hour)}},
+			want:    "Любовь",
+		},
+		{
+			name:    "все темы на перерыве",
+			topics:  catalog,
+			history: []Use{{Topic: "Любовь", At: now.Add(-day)}, {Topic: "Города", At: now.Add(-time.Hour)}},
+			want:    "",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, err := New(Options{Topics: tt.topics})
+			if err != nil {
+				t.Fatal(err)
+			}
+			// Результат не зависит от случайности: доступна не больше чем одна тема
+			for seed := int64(0); seed < 20; seed++ {
+				got, err := c.Pick(now, tt.history, rand.New(rand.NewSource(seed)))
+				if tt.want == "" {
+					if !errors.Is(err, ErrEmpty) {
+						t.Fatalf("Pick = %q, %v; ожидалась ErrEmpty", got.Name, err)
+					}
+					continue
+				}
+				if err != nil || got.Name != tt.want {
+					t.Fatalf("Pick = %q, %v; ожидалось %q", got.Name, err, tt.want)
+				}
+			}
+		})
+	}
+}
+
+func TestPickWeights(t *testing.T) {
+	c, err := New(Options{Topics: []Topic{
+		{Name: "Любовь", Weight: 3},
+		{Name: "Города", Weight: 1},
+		{Name: "Страны", Weight: 1, Cooldown: time.Hour},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Тема на перерыве не выбирается и не влияет на доли остальных
+	history := []Use{{Topic: "Страны", At: now.Add(-time.Minute)}}
+
+	const n = 10000
+	rnd := rand.New(rand.NewSource(1))
+	counts := make(map[string]int)
+	for i := 0; i < n; i++ {
+		topic, err := c.Pick(now, history, rnd)
+		if err != nil {
+			t.Fatal(err)
+		}
+		counts[topic.Name]++
+	}
+
+	if counts["Страны"] != 0 {
+		t.Fatalf("тема на перерыве выбрана %d раз", counts["Страны"])
+	}
+	if share := float64(counts["Любовь"]) / n; share < 0.72 || share > 0.78 {
+		t.Fatalf("доля темы с весом 3 из 4: %.3f, выборы %v", share, counts)
+	}
+}
+
+func TestBlockedAuthors(t *testing.T) {
+	day := 24 * time.Hour
+
+	tests := []struct {
+		name     string
+		cooldown time.Duration
+		history  []Use
+		want     []Blocked
+	}{
+		{
+			name:    "перерыв не задан",
+			history: []Use{{Author: "Сенека", At: now.Add(-time.Hour)}},
+		},
+		{
+			name:     "перерыв истек",
+			cooldown: 3 * day,
+			history:  []Use{{Author: "Сенека", At: now.Add(-4 * day)}},
+		},
+		{
+			name:     "по алфавиту",
+			cooldown: 3 * day,
+			history:  []Use{{Author: "Сенека", At: now.Add(-day)}, {Author: "Марк Твен", At: now.Add(-2 * day)}},
+			want: []Blocked{
+				{Author: "Марк Твен", Until: now.Add(day)},
+				{Author: "Сенека", Until: now.Add(2 * day)},
+			},
+		},
+		{
+			name:     "последний пост автора без учета регистра",
+			cooldown: 3 * day,
+			history:  []Use{{Author: "Сенека", At: now.Add(-2 * day)}, {Author: "сенека", At: now.Add(-day)}},
+			want:     []Blocked{{Author: "сенека", Until: now.Add(2 * day)}},
+		},
+		{
+			name:     "без автора и будущие посты",
+			cooldown: 3 * day,
+			history:  []Use{{Topic: "Любовь", At: now.Add(-time.Hour)}, {Author: "Сенека", At: now.Add(time.Hour)}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, err := New(Options{AuthorCooldown: tt.cooldown})
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := c.BlockedAuthors(now, tt.history)
+			if len(got) != 0 || len(tt.want) != 0 {
+				if !reflect.DeepEqual(got, tt.want) {
+					t.Fatalf("BlockedAuthors = %+v, ожидалось %+v", got, tt.want)
+				}
+			}
+		})
+	}
+}
@@ -4,7 +4,6 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
-	"log"
 	"os"
 	"regexp"
 	"strings"
@@ -65,8 +64,6 @@ func CleanQuote(quote string) string {
 
 // ExtractQuoteAndAuthor извлекает цитату и автора из строки
 func ExtractQuoteAndAuthor(response string) (string, string, error) {
-	log.Printf("Response to parse: %s", response)
-
 	// Удаляем пробелы в начале и конце строки
 	response = strings.TrimSpace(response)
 